@@ -0,0 +1,141 @@
+package bgp
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// Aggregate configures an aggregate-address: the node originates the prefix while its Loc-RIB
+// has a more specific route, the contributors
+type Aggregate struct {
+	Prefix      netip.Prefix
+	SummaryOnly bool // Suppress the advertisement of the contributors
+	AsSet       bool // Carry the AS numbers of the contributors in an AS_SET instead of setting ATOMIC_AGGREGATE
+}
+
+// AddAggregate configures an aggregate, replacing any for the same prefix
+func (n *BgpNode) AddAggregate(a Aggregate) {
+	a.Prefix = a.Prefix.Masked()
+	n.aggregates[a.Prefix] = a
+	n.originsChanged = true
+	n.resend = true
+}
+
+// RemoveAggregate removes the aggregate for a prefix, its contributors are advertised again
+func (n *BgpNode) RemoveAggregate(prefix netip.Prefix) {
+	prefix = prefix.Masked()
+	if _, ok := n.aggregates[prefix]; !ok {
+		return
+	}
+	delete(n.aggregates, prefix)
+	n.originsChanged = true
+	n.resend = true
+}
+
+// Aggregates returns the configured aggregates in prefix order
+func (n *BgpNode) Aggregates() []Aggregate {
+	var aggregates []Aggregate
+	for _, prefix := range slices.SortedFunc(maps.Keys(n.aggregates), comparePrefix) {
+		aggregates = append(aggregates, n.aggregates[prefix])
+	}
+	return aggregates
+}
+
+// refreshAggregates recomputes the aggregates covering a changed prefix, or all of them if changed is nil.
+// Prefixes whose aggregate route changed are marked dirty.
+func (n *BgpNode) refreshAggregates(changed map[netip.Prefix]struct{}) {
+	for prefix, a := range n.aggregates {
+		if changed != nil && !coversAny(prefix, changed) {
+			continue
+		}
+		r, ok := n.aggregateRoute(a)
+		old, had := n.aggregated[prefix]
+		switch {
+		case !ok && had:
+			delete(n.aggregated, prefix)
+		case ok && (!had || old.Hash() != r.Hash()):
+			n.aggregated[prefix] = r
+		default:
+			continue
+		}
+		n.dirty[prefix] = struct{}{}
+	}
+	for prefix := range n.aggregated {
+		if _, ok := n.aggregates[prefix]; !ok {
+			delete(n.aggregated, prefix)
+			n.dirty[prefix] = struct{}{}
+		}
+	}
+}
+
+// aggregateRoute builds the route of an aggregate from the best paths of its contributors.
+// Returns false if there are no contributors.
+func (n *BgpNode) aggregateRoute(a Aggregate) (*route.BgpRoute, bool) {
+	var contributors []*route.BgpRoute
+	for p := range n.locIndex.Subnets(a.Prefix) {
+		if p != a.Prefix {
+			contributors = append(contributors, n.locRib[p].BestPath())
+		}
+	}
+	if len(contributors) == 0 {
+		return nil, false
+	}
+
+	origin := route.IGP
+	atomic := false
+	var asns []uint32
+	for _, r := range contributors {
+		atomic = atomic || r.AtomicAggregate()
+		asns = append(append(asns, r.AsPath()...), r.AsSet()...)
+		if a.AsSet {
+			origin = max(origin, r.Origin())
+		}
+	}
+	slices.Sort(asns)
+	asns = slices.Compact(asns)
+
+	opts := []func(*route.BgpRoute){
+		route.WithPrefix(a.Prefix),
+		route.WithOrigin(origin),
+		route.WithReceivedFrom(route.NewRxFrom(route.WithLocal())),
+		route.WithWeight(localWeight),
+		route.WithAggregator(route.Aggregator{Asn: n.asn, RouterID: n.routerID}),
+	}
+	switch {
+	case a.AsSet && len(asns) != 0:
+		opts = append(opts, route.WithAsSet(asns))
+	case !a.AsSet && len(asns) != 0:
+		atomic = true // The AS numbers of the contributors are lost
+	}
+	if atomic {
+		opts = append(opts, route.WithAtomicAggregate(true))
+	}
+	return route.New(opts...), true
+}
+
+// summarized reports whether a prefix contributes to an active summary-only aggregate
+func (n *BgpNode) summarized(prefix netip.Prefix) bool {
+	for p, a := range n.aggregates {
+		if _, active := n.aggregated[p]; active && a.SummaryOnly && contains(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func coversAny(prefix netip.Prefix, prefixes map[netip.Prefix]struct{}) bool {
+	for p := range prefixes {
+		if contains(prefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether sub is strictly more specific than prefix
+func contains(prefix, sub netip.Prefix) bool {
+	return sub.Bits() > prefix.Bits() && prefix.Contains(sub.Addr())
+}
@@ -0,0 +1,64 @@
+package bgp
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+func TestBgpNode_Aggregate(t *testing.T) {
+	topo, a, b, c := makeChain()
+	aggregate := netip.MustParsePrefix("192.0.2.0/24")
+	low := netip.MustParsePrefix("192.0.2.0/25")
+	high := netip.MustParsePrefix("192.0.2.128/25")
+	a.Originate(route.New(route.WithPrefix(low)))
+	a.Originate(route.New(route.WithPrefix(high), route.WithOrigin(route.Incomplete)))
+	b.AddAggregate(Aggregate{Prefix: aggregate, SummaryOnly: true, AsSet: true})
+
+	sim := NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	rs, ok := c.LocRib(aggregate)
+	if !ok {
+		t.Fatal("Expected c to learn the aggregate")
+	}
+	best := rs.BestPath()
+	if !slices.Equal(best.AsPath(), []uint32{65002}) || !slices.Equal(best.AsSet(), []uint32{65001}) {
+		t.Errorf("Unexpected AS path %v and AS_SET %v", best.AsPath(), best.AsSet())
+	}
+	if best.AtomicAggregate() || best.Aggregator().Asn != 65002 || best.Origin() != route.Incomplete {
+		t.Errorf("Unexpected aggregate attributes %+v", best)
+	}
+	if got := c.LocRibPrefixes(); len(got) != 1 {
+		t.Errorf("Expected the more-specifics to be suppressed, c has %v", got)
+	}
+	if _, ok := b.LocRib(low); !ok {
+		t.Error("Expected b to keep the contributors")
+	}
+
+	// Without AS_SET the AS numbers of the contributors are lost
+	b.AddAggregate(Aggregate{Prefix: aggregate})
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	rs, _ = c.LocRib(aggregate)
+	if best := rs.BestPath(); len(best.AsSet()) != 0 || !best.AtomicAggregate() || best.Origin() != route.IGP {
+		t.Errorf("Expected ATOMIC_AGGREGATE without AS_SET, got %+v", best)
+	}
+	if got := c.LocRibPrefixes(); len(got) != 3 {
+		t.Errorf("Expected the more-specifics to be advertised, c has %v", got)
+	}
+
+	// The aggregate goes with its last contributor
+	a.WithdrawOrigin(low)
+	a.WithdrawOrigin(high)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if got := c.LocRibPrefixes(); len(got) != 0 {
+		t.Errorf("Expected the aggregate to be withdrawn, c has %v", got)
+	}
+}
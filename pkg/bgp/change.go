@@ -0,0 +1,162 @@
+package bgp
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+const (
+	reasonSessionDown = "session down"
+)
+
+// Topology changes are applied to converged state; the next Run only processes what they affect.
+
+// RemoveLink tears down the session on a link, identified by either end.
+// Both ends withdraw the routes learned over it. Returns false if no such link exists.
+func (s *Simulation) RemoveLink(id LinkID) bool {
+	p, ok := s.topology.removePeer(id.Node, id.Iface)
+	if !ok {
+		return false
+	}
+	delete(p.LocalNode.shutdown, p.LocalIface)
+	delete(p.RemoteNode.shutdown, p.RemoteIface)
+	p.LocalNode.teardown(p.remoteKey())
+	p.RemoteNode.teardown(p.localKey())
+	return true
+}
+
+// shutdownSession takes the session on a link administratively down, keeping the link.
+// Both ends withdraw the routes learned over it. Returns false if no such session is up.
+func (s *Simulation) shutdownSession(id LinkID) bool {
+	p, ok := s.topology.GetPeerByIface(id.Node, id.Iface)
+	if !ok || !p.LocalNode.SessionUp(p.LocalIface) {
+		return false
+	}
+	p.LocalNode.shutdown[p.LocalIface] = struct{}{}
+	p.RemoteNode.shutdown[p.RemoteIface] = struct{}{}
+	p.LocalNode.teardown(p.remoteKey())
+	p.RemoteNode.teardown(p.localKey())
+	return true
+}
+
+// startSession brings an administratively down session back up, both ends advertise their Loc-RIB over it.
+// Returns false if no such session is down.
+func (s *Simulation) startSession(id LinkID) bool {
+	p, ok := s.topology.GetPeerByIface(id.Node, id.Iface)
+	if !ok || p.LocalNode.SessionUp(p.LocalIface) {
+		return false
+	}
+	delete(p.LocalNode.shutdown, p.LocalIface)
+	delete(p.RemoteNode.shutdown, p.RemoteIface)
+	rev := p.reverse()
+	for _, q := range []*BgpPeer{&p, &rev} {
+		if _, ok := q.LocalNode.closing[q.remoteKey()]; ok {
+			q.LocalNode.closeSession(q.remoteKey())
+		}
+		q.LocalNode.queue.PopAll(q.remoteKey()) // Advertisements that arrived while down
+	}
+	p.LocalNode.refreshPeer(s, &p)
+	rev.LocalNode.refreshPeer(s, &rev)
+	return true
+}
+
+// SessionUp reports whether the session on an interface is not administratively down
+func (n *BgpNode) SessionUp(iface string) bool {
+	_, down := n.shutdown[iface]
+	return !down
+}
+
+// established returns the peers whose session is not administratively down
+func (n *BgpNode) established(peers []BgpPeer) []BgpPeer {
+	if len(n.shutdown) == 0 {
+		return peers
+	}
+	var up []BgpPeer
+	for _, p := range peers {
+		if n.SessionUp(p.LocalIface) {
+			up = append(up, p)
+		}
+	}
+	return up
+}
+
+// AddLink brings up a session, both ends advertise their Loc-RIB over it
+func (s *Simulation) AddLink(peer BgpPeer) {
+	s.topology.addPeer(peer)
+	rev := peer.reverse()
+	for _, p := range []*BgpPeer{&peer, &rev} {
+		// Withdrawals of a previous session on the same interfaces go first
+		if _, ok := p.LocalNode.closing[p.remoteKey()]; ok {
+			p.LocalNode.closeSession(p.remoteKey())
+		}
+	}
+	peer.LocalNode.refreshPeer(s, &peer)
+	rev.LocalNode.refreshPeer(s, &rev)
+}
+
+// RemoveNode removes a node and all its links. Returns false if no such node exists.
+func (s *Simulation) RemoveNode(name string) bool {
+	if _, ok := s.topology.nodes[name]; !ok {
+		return false
+	}
+	for _, p := range slices.Clone(s.topology.GetPeers(name)) {
+		s.RemoveLink(p.ID())
+	}
+	delete(s.topology.nodes, name)
+	delete(s.topology.peerMap, name)
+	return true
+}
+
+// teardown enqueues withdrawals for every route learned over a session and discards its Adj-RIB-Out.
+// The withdrawals are processed on the next step.
+func (n *BgpNode) teardown(key string) {
+	n.queue.PopAll(key) // In-flight advertisements are lost with the session
+
+	tx := n.queue.BeginTx()
+	in := n.adjRibIn[key]
+	for _, prefix := range slices.SortedFunc(maps.Keys(in), comparePrefix) {
+		paths := in[prefix]
+		for _, id := range slices.Sorted(maps.Keys(paths)) {
+			tx.Push(key, ra.RouteAdv[*route.BgpRoute]{
+				Route:  paths[id],
+				Action: ra.Remove,
+				Reason: reasonSessionDown,
+			})
+		}
+	}
+	tx.Commit()
+
+	delete(n.adjRibOut, key)
+	n.closing[key] = struct{}{}
+}
+
+// closeSession processes the pending withdrawals of a torn down session and drops its state
+func (n *BgpNode) closeSession(key string) {
+	in := n.adjRibIn[key]
+	for _, adv := range n.queue.PopAll(key) {
+		if adv.Action != ra.Remove {
+			continue
+		}
+		n.dirty[adv.Route.Prefix()] = struct{}{}
+		in.remove(adv.Route)
+	}
+	delete(n.adjRibPre, key)
+	delete(n.adjRibIn, key)
+	delete(n.closing, key)
+}
+
+// refreshPeer advertises the whole Loc-RIB to a new session
+func (n *BgpNode) refreshPeer(sim *Simulation, peer *BgpPeer) {
+	tx := peer.RemoteNode.queue.BeginTx()
+	for _, prefix := range n.LocRibPrefixes() {
+		best := n.locRib[prefix].BestPath()
+		n.advertise(sim, peer, prefix, best, tx)
+	}
+	if prefix := defaultPrefix(peer.LocalPrefix.Addr().Is4()); n.locRib[prefix] == nil {
+		n.advertise(sim, peer, prefix, nil, tx) // Originated default, if any
+	}
+	tx.Commit()
+}
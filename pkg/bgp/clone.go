@@ -0,0 +1,219 @@
+package bgp
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+// LinkID identifies a link by one of its ends
+type LinkID struct {
+	Node  string
+	Iface string
+}
+
+// ID returns the identifier of the local end of a peer
+func (p *BgpPeer) ID() LinkID {
+	return LinkID{Node: p.LocalNode.name, Iface: p.LocalIface}
+}
+
+// Links returns every link of the topology once, seen from the end with the lower LinkID
+func (t *BgpTopology) Links() []BgpPeer {
+	var links []BgpPeer
+	for _, name := range slices.Sorted(maps.Keys(t.peerMap)) {
+		for _, p := range t.peerMap[name] {
+			if p.localKey() < p.remoteKey() {
+				links = append(links, p)
+			}
+		}
+	}
+	return links
+}
+
+// CloneConfig returns a new node with the same configuration and no routing state.
+// Non-BGP routes are copied, except connected routes derived from peers.
+func (n *BgpNode) CloneConfig() *BgpNode {
+	c := NewBgpNode(n.name, n.asn, WithRouterID(n.routerID), WithMultipath(n.multipath), WithDeterministic(n.deterministic), WithLeaker(n.leaker), WithExternal(n.external), WithDampening(n.dampening))
+	maps.Copy(c.networks, n.networks)
+	maps.Copy(c.redistributions, n.redistributions)
+	maps.Copy(c.aggregates, n.aggregates)
+	maps.Copy(c.imports, n.imports)
+	maps.Copy(c.exports, n.exports)
+	for iface, cond := range n.conditionals {
+		cond.watch = cond.watch.reset()
+		c.conditionals[iface] = cond
+	}
+	for iface, d := range n.defaults {
+		d.watch = d.watch.reset()
+		c.defaults[iface] = d
+	}
+	maps.Copy(c.feeds, n.feeds)
+	maps.Copy(c.local, n.local)
+	for prefix := range n.local {
+		c.dirty[prefix] = struct{}{}
+	}
+	for _, r := range n.rib.GetRoutes() {
+		if _, ok := n.connected[r.Hash()]; ok || r.Protocol == rib.BGP {
+			continue
+		}
+		c.AddRoute(r)
+	}
+	c.originsChanged = true
+	return c
+}
+
+// CloneConfig returns a topology of fresh node copies without routing state.
+// Links with an end in failedLinks and nodes in failedNodes are left out.
+func (t *BgpTopology) CloneConfig(failedLinks []LinkID, failedNodes []string) *BgpTopology {
+	clones := make(map[string]*BgpNode)
+	builder := &BgpTopologyBuilder{}
+	for _, n := range t.Nodes() {
+		if slices.Contains(failedNodes, n.name) {
+			continue
+		}
+		c := n.CloneConfig()
+		clones[n.name] = c
+		builder.AddNode(c)
+	}
+
+	for _, p := range t.Links() {
+		local, lok := clones[p.LocalNode.name]
+		remote, rok := clones[p.RemoteNode.name]
+		if !lok || !rok {
+			continue
+		}
+		if slices.Contains(failedLinks, p.ID()) || slices.Contains(failedLinks, LinkID{Node: p.RemoteNode.name, Iface: p.RemoteIface}) {
+			continue
+		}
+		p.LocalNode, p.RemoteNode = local, remote
+		builder.AddPeer(p)
+	}
+	return builder.Build()
+}
+
+// Fork returns a new Simulation with the same settings over another topology
+func (s *Simulation) Fork(topology *BgpTopology) *Simulation {
+	f := &Simulation{
+		topology:  topology,
+		maxRounds: s.maxRounds,
+	}
+	if s.events != nil {
+		f.events = newEngine(s.events.timing)
+		maps.Copy(f.events.delays, s.events.delays)
+		maps.Copy(f.events.mrais, s.events.mrais)
+	}
+	return f
+}
+
+// Clone returns a deep copy of the node, including its routing state and pending advertisements.
+// Routes are immutable once stored and are shared with the copy.
+func (n *BgpNode) Clone() *BgpNode {
+	c := &BgpNode{
+		name:            n.name,
+		asn:             n.asn,
+		routerID:        n.routerID,
+		multipath:       n.multipath,
+		deterministic:   n.deterministic,
+		leaker:          n.leaker,
+		external:        n.external,
+		dampening:       n.dampening,
+		networks:        maps.Clone(n.networks),
+		redistributions: maps.Clone(n.redistributions),
+		aggregates:      maps.Clone(n.aggregates),
+		imports:         maps.Clone(n.imports),
+		exports:         maps.Clone(n.exports),
+		conditionals:    make(map[string]conditional, len(n.conditionals)),
+		defaults:        make(map[string]defaultOriginate, len(n.defaults)),
+		queue:           n.queue.Clone(),
+		local:           maps.Clone(n.local),
+		injected:        maps.Clone(n.injected),
+		aggregated:      maps.Clone(n.aggregated),
+		connected:       maps.Clone(n.connected),
+		adjRibPre:       make(map[string]adjRib, len(n.adjRibPre)),
+		adjRibIn:        make(map[string]adjRib, len(n.adjRibIn)),
+		adjRibOut:       make(map[string]map[netip.Prefix]*route.BgpRoute, len(n.adjRibOut)),
+		closing:         maps.Clone(n.closing),
+		feeds:           maps.Clone(n.feeds),
+		locRib:          maps.Clone(n.locRib),
+		locIndex:        n.locIndex.Clone(),
+		installed:       make(map[netip.Prefix][]rib.Route, len(n.installed)),
+		rib:             n.rib.Clone(),
+		nht:             n.nht.clone(),
+		dirty:           maps.Clone(n.dirty),
+		resend:          n.resend,
+		reimport:        maps.Clone(n.reimport),
+		ribChanged:      n.ribChanged,
+		originsChanged:  n.originsChanged,
+		flaps:           maps.Clone(n.flaps),
+		suppressed:      maps.Clone(n.suppressed),
+		prefixLevels:    maps.Clone(n.prefixLevels),
+		shutdown:        maps.Clone(n.shutdown),
+	}
+	for iface, cond := range n.conditionals {
+		cond.watch = cond.watch.clone()
+		c.conditionals[iface] = cond
+	}
+	for iface, d := range n.defaults {
+		d.watch = d.watch.clone()
+		c.defaults[iface] = d
+	}
+	for key, pre := range n.adjRibPre {
+		c.adjRibPre[key] = pre.clone()
+	}
+	for key, in := range n.adjRibIn {
+		c.adjRibIn[key] = in.clone()
+	}
+	for key, out := range n.adjRibOut {
+		c.adjRibOut[key] = maps.Clone(out)
+	}
+	for prefix, routes := range n.installed {
+		c.installed[prefix] = slices.Clone(routes)
+	}
+	return c
+}
+
+// Clone returns a topology of deep node copies with the same links
+func (t *BgpTopology) Clone() *BgpTopology {
+	c := &BgpTopology{
+		peerMap: make(map[string][]BgpPeer, len(t.peerMap)),
+		nodes:   make(map[string]*BgpNode, len(t.nodes)),
+	}
+	for name, n := range t.nodes {
+		c.nodes[name] = n.Clone()
+	}
+	for name, peers := range t.peerMap {
+		cp := make([]BgpPeer, len(peers))
+		for i, p := range peers {
+			p.LocalNode, p.RemoteNode = c.nodes[p.LocalNode.name], c.nodes[p.RemoteNode.name]
+			cp[i] = p
+		}
+		c.peerMap[name] = cp
+	}
+	return c
+}
+
+// Clone returns a deep copy of the simulation, changes to it leave the original untouched
+func (s *Simulation) Clone() *Simulation {
+	c := &Simulation{
+		topology:  s.topology.Clone(),
+		clock:     s.clock,
+		maxRounds: s.maxRounds,
+		elapsed:   s.elapsed,
+		stats:     s.stats,
+	}
+	if s.events != nil {
+		c.events = s.events.clone()
+	}
+	for _, r := range s.restarts {
+		local, lok := c.topology.Node(r.peer.LocalNode.name)
+		remote, rok := c.topology.Node(r.peer.RemoteNode.name)
+		if lok && rok {
+			r.peer.LocalNode, r.peer.RemoteNode = local, remote
+			c.restarts = append(c.restarts, r)
+		}
+	}
+	return c
+}
@@ -0,0 +1,178 @@
+package bgp
+
+import (
+	"maps"
+	"net/netip"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+// ConditionalAdvertisement advertises the routes permitted by AdvertiseMap to a peer only while the
+// Loc-RIB has a best path permitted by ExistMap, or none permitted by NonExistMap.
+// At most one of ExistMap and NonExistMap may be set, with neither the routes are always advertised.
+type ConditionalAdvertisement struct {
+	AdvertiseMap *policy.RouteMap
+	ExistMap     *policy.RouteMap
+	NonExistMap  *policy.RouteMap
+}
+
+// DefaultOriginate sends a default route to a peer, whether or not the Loc-RIB has one.
+// The default route bypasses the export policy of the session.
+type DefaultOriginate struct {
+	Condition *policy.RouteMap // Only while the Loc-RIB has a best path it permits, nil for always
+}
+
+// watch tracks the Loc-RIB prefixes whose best path a route map permits
+type watch struct {
+	rm      *policy.RouteMap
+	absent  bool // Met while no best path is permitted
+	matched map[netip.Prefix]struct{}
+}
+
+func newWatch(n *BgpNode, rm *policy.RouteMap, absent bool) watch {
+	w := watch{rm: rm, absent: absent, matched: make(map[netip.Prefix]struct{})}
+	if rm != nil {
+		for prefix := range n.locRib {
+			w.check(n, prefix)
+		}
+	}
+	return w
+}
+
+func (w watch) clone() watch {
+	w.matched = maps.Clone(w.matched)
+	return w
+}
+
+// reset returns the watch of an empty Loc-RIB
+func (w watch) reset() watch {
+	w.matched = make(map[netip.Prefix]struct{})
+	return w
+}
+
+func (w watch) met() bool {
+	if w.rm == nil {
+		return true
+	}
+	return (len(w.matched) != 0) != w.absent
+}
+
+func (w watch) check(n *BgpNode, prefix netip.Prefix) {
+	if rs, ok := n.locRib[prefix]; ok && w.rm.Permits(rs.BestPath()) {
+		w.matched[prefix] = struct{}{}
+		return
+	}
+	delete(w.matched, prefix)
+}
+
+// update rechecks changed prefixes, returns true if the condition flipped
+func (w watch) update(n *BgpNode, changed map[netip.Prefix]struct{}) bool {
+	if w.rm == nil {
+		return false
+	}
+	before := w.met()
+	for prefix := range changed {
+		w.check(n, prefix)
+	}
+	return w.met() != before
+}
+
+type conditional struct {
+	ca    ConditionalAdvertisement
+	watch watch
+}
+
+type defaultOriginate struct {
+	d     DefaultOriginate
+	watch watch
+}
+
+// SetConditionalAdvertisement sets the conditional advertisement on a local interface, nil removes it.
+// The whole Loc-RIB is exported again. Returns false if both ExistMap and NonExistMap are set.
+func (n *BgpNode) SetConditionalAdvertisement(iface string, ca *ConditionalAdvertisement) bool {
+	if ca != nil && ca.ExistMap != nil && ca.NonExistMap != nil {
+		return false
+	}
+	if ca == nil {
+		delete(n.conditionals, iface)
+	} else {
+		w := newWatch(n, ca.ExistMap, false)
+		if ca.ExistMap == nil && ca.NonExistMap != nil {
+			w = newWatch(n, ca.NonExistMap, true)
+		}
+		n.conditionals[iface] = conditional{ca: *ca, watch: w}
+	}
+	n.resend = true
+	return true
+}
+
+// SetDefaultOriginate sets default route origination on a local interface, nil removes it
+func (n *BgpNode) SetDefaultOriginate(iface string, d *DefaultOriginate) {
+	if d == nil {
+		delete(n.defaults, iface)
+	} else {
+		n.defaults[iface] = defaultOriginate{d: *d, watch: newWatch(n, d.Condition, false)}
+	}
+	n.resend = true
+}
+
+// evaluateConditions rechecks the conditions against the prefixes whose selected routes changed.
+// When a condition flips, the prefixes it controls are added to changed to be exported again.
+func (n *BgpNode) evaluateConditions(changed map[netip.Prefix]struct{}) {
+	if len(changed) == 0 {
+		return
+	}
+	var advertise []netip.Prefix
+	for _, c := range n.conditionals {
+		if !c.watch.update(n, changed) {
+			continue
+		}
+		for prefix, rs := range n.locRib {
+			if c.ca.AdvertiseMap.Permits(rs.BestPath()) {
+				advertise = append(advertise, prefix)
+			}
+		}
+	}
+	for _, d := range n.defaults {
+		if d.watch.update(n, changed) {
+			advertise = append(advertise, defaultPrefix(true), defaultPrefix(false))
+		}
+	}
+	for _, prefix := range advertise {
+		changed[prefix] = struct{}{}
+	}
+}
+
+// withheld reports whether a conditional advertisement holds a route back from a peer
+func (n *BgpNode) withheld(peer *BgpPeer, best *route.BgpRoute) bool {
+	c, ok := n.conditionals[peer.LocalIface]
+	return ok && !c.watch.met() && c.ca.AdvertiseMap.Permits(best)
+}
+
+// originatedDefault returns the default route originated to a peer, if prefix is its default prefix
+// and the origination condition is met
+func (n *BgpNode) originatedDefault(peer *BgpPeer, prefix netip.Prefix) (*route.BgpRoute, bool) {
+	d, ok := n.defaults[peer.LocalIface]
+	if !ok || !d.watch.met() || prefix != defaultPrefix(peer.LocalPrefix.Addr().Is4()) {
+		return nil, false
+	}
+	opts := []func(*route.BgpRoute){
+		route.WithPrefix(prefix),
+		route.WithOrigin(route.IGP),
+		route.WithNextHop(nexthop.New(nexthop.WithIP(peer.LocalPrefix.Addr()))),
+		route.WithLocalPreference(defaultLocalPreference),
+	}
+	if !peer.ibgp() {
+		opts = append(opts, route.WithAsPath([]uint32{n.asn}), route.WithLocalPreference(0))
+	}
+	return route.New(opts...), true
+}
+
+func defaultPrefix(v4 bool) netip.Prefix {
+	if v4 {
+		return netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+	}
+	return netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+}
@@ -0,0 +1,106 @@
+package bgp
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+func TestBgpNode_ConditionalAdvertisement(t *testing.T) {
+	primary := netip.MustParsePrefix("198.51.100.0/24")
+	backup := netip.MustParsePrefix("203.0.113.0/24")
+
+	topo, a, b, c := makeChain()
+	a.Originate(route.New(route.WithPrefix(primary)))
+	a.Originate(route.New(route.WithPrefix(backup)))
+	both := policy.New(policy.Clause{Match: []policy.Match{policy.MatchAny()}})
+	if b.SetConditionalAdvertisement("eth1", &ConditionalAdvertisement{ExistMap: both, NonExistMap: both}) {
+		t.Error("Expected both an exist-map and a non-exist-map to be rejected")
+	}
+	b.SetConditionalAdvertisement("eth1", &ConditionalAdvertisement{
+		AdvertiseMap: policy.New(policy.Clause{Match: []policy.Match{policy.MatchPrefix(backup)}}),
+		NonExistMap:  policy.New(policy.Clause{Match: []policy.Match{policy.MatchPrefix(primary)}}),
+	})
+	sim := NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(backup); ok {
+		t.Error("Expected the backup withheld from c while the primary exists")
+	}
+	if _, ok := c.LocRib(primary); !ok {
+		t.Error("Expected the primary advertised to c")
+	}
+
+	// Losing the primary releases the backup
+	a.WithdrawOrigin(primary)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(backup); !ok {
+		t.Fatal("Expected the backup advertised to c after the primary was withdrawn")
+	}
+
+	// And its return withdraws the backup again
+	a.Originate(route.New(route.WithPrefix(primary)))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(backup); ok {
+		t.Error("Expected the backup withdrawn from c after the primary returned")
+	}
+}
+
+func TestBgpNode_DefaultOriginate(t *testing.T) {
+	primary := netip.MustParsePrefix("198.51.100.0/24")
+	def := netip.MustParsePrefix("0.0.0.0/0")
+
+	topo, a, b, c := makeChain()
+	a.SetDefaultOriginate("eth0", &DefaultOriginate{})
+	sim := NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	rs, ok := c.LocRib(def)
+	if !ok {
+		t.Fatal("Expected c to learn the default originated by a")
+	}
+	if !slices.Equal(rs.BestPath().AsPath(), []uint32{65002, 65001}) {
+		t.Errorf("Expected AS path [65002 65001], got %v", rs.BestPath().AsPath())
+	}
+	if _, ok := a.LocRib(def); ok {
+		t.Error("Expected no default in the Loc-RIB of a")
+	}
+
+	// Conditional on the primary at b
+	b.SetDefaultOriginate("eth1", &DefaultOriginate{
+		Condition: policy.New(policy.Clause{Match: []policy.Match{policy.MatchPrefix(primary)}}),
+	})
+	a.SetDefaultOriginate("eth0", nil)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(def); ok {
+		t.Fatal("Expected no default at c without the primary")
+	}
+
+	a.Originate(route.New(route.WithPrefix(primary)))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	rs, ok = c.LocRib(def)
+	if !ok || !slices.Equal(rs.BestPath().AsPath(), []uint32{65002}) {
+		t.Fatal("Expected the default originated by b once the primary exists")
+	}
+
+	a.WithdrawOrigin(primary)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(def); ok {
+		t.Error("Expected the default withdrawn from c with the primary")
+	}
+}
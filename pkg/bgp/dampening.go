@@ -0,0 +1,193 @@
+package bgp
+
+import (
+	"maps"
+	"math"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Dampening configures route flap dampening (RFC 2439) of routes received over eBGP sessions and feeds.
+// Penalties decay on the simulated clock of the Simulation, see Simulation.Now. The zero value disables it.
+type Dampening struct {
+	HalfLife          time.Duration // Time for a penalty to decay by half
+	ReuseThreshold    float64       // Suppressed routes are reused once their penalty decays below it
+	SuppressThreshold float64       // Routes are suppressed once their penalty reaches it
+	MaxSuppressTime   time.Duration // Longest a route stays suppressed after its last flap
+	WithdrawalPenalty float64       // Added when a route is withdrawn
+	AttributePenalty  float64       // Added when a route is replaced with different attributes
+}
+
+// DefaultDampening returns the RFC 2439 parameters with the suppress threshold of 6000 recommended by RFC 7196
+func DefaultDampening() Dampening {
+	return Dampening{
+		HalfLife:          15 * time.Minute,
+		ReuseThreshold:    750,
+		SuppressThreshold: 6000,
+		MaxSuppressTime:   time.Hour,
+		WithdrawalPenalty: 1000,
+		AttributePenalty:  500,
+	}
+}
+
+func (d *Dampening) enabled() bool {
+	return d.HalfLife > 0
+}
+
+// ceiling bounds penalties so that a route is reused at most MaxSuppressTime after its last flap
+func (d *Dampening) ceiling() float64 {
+	return d.ReuseThreshold * math.Exp2(float64(d.MaxSuppressTime)/float64(d.HalfLife))
+}
+
+// flapKey identifies the routes of a prefix received over a session
+type flapKey struct {
+	session string
+	prefix  netip.Prefix
+}
+
+// flapState is the figure of merit of a prefix received over a session.
+// It only exists from the first flap until the penalty decays below half the reuse threshold.
+type flapState struct {
+	penalty    float64
+	updated    time.Duration // When penalty was last charged
+	reuseAt    time.Duration // When a suppressed route becomes eligible again
+	suppressed bool
+}
+
+// decay returns the penalty at now
+func (f *flapState) decay(d *Dampening, now time.Duration) float64 {
+	return f.penalty * math.Exp2(-float64(now-f.updated)/float64(d.HalfLife))
+}
+
+func (n *BgpNode) Dampening() Dampening {
+	return n.dampening
+}
+
+// SetDampening changes the dampening parameters. Penalties are kept, disabling dampening reuses every suppressed route.
+func (n *BgpNode) SetDampening(d Dampening) {
+	n.dampening = d
+	if d.enabled() {
+		return
+	}
+	for key := range n.suppressed {
+		f := n.flaps[key]
+		f.suppressed = false
+		n.flaps[key] = f
+		n.dirty[key.prefix] = struct{}{}
+	}
+	clear(n.suppressed)
+}
+
+// Dampened returns the received routes of a prefix suppressed by flap dampening
+func (n *BgpNode) Dampened(prefix netip.Prefix) []*route.BgpRoute {
+	var routes []*route.BgpRoute
+	for _, key := range slices.Sorted(maps.Keys(n.adjRibIn)) {
+		if _, ok := n.suppressed[flapKey{session: key, prefix: prefix}]; !ok {
+			continue
+		}
+		paths := n.adjRibIn[key][prefix]
+		for _, id := range slices.Sorted(maps.Keys(paths)) {
+			routes = append(routes, paths[id])
+		}
+	}
+	return routes
+}
+
+// penalize charges a flap of a received advertisement before it is applied to the Adj-RIB-In.
+// Returns true if the routes of the prefix became suppressed.
+func (n *BgpNode) penalize(s session, adv ra.RouteAdv[*route.BgpRoute], now time.Duration) bool {
+	d := &n.dampening
+	if !d.enabled() || s.ibgp {
+		return false
+	}
+	r := adv.Route
+	key := flapKey{session: s.key, prefix: r.Prefix()}
+	prev, present := n.adjRibPre[s.key][r.Prefix()][r.PathID()]
+
+	var charge float64
+	switch {
+	case adv.Action == ra.Remove && present:
+		charge = d.WithdrawalPenalty
+	case adv.Action == ra.Add && present && prev.Hash() != received(s, r).Hash():
+		charge = d.AttributePenalty
+	}
+	if charge == 0 {
+		return false
+	}
+
+	f := n.flaps[key]
+	f.penalty = min(f.decay(d, now)+charge, d.ceiling())
+	f.updated = now
+	suppress := !f.suppressed && f.penalty >= d.SuppressThreshold
+	if suppress {
+		f.suppressed = true
+		n.suppressed[key] = struct{}{}
+	}
+	if f.suppressed {
+		f.reuseAt = now + time.Duration(math.Ceil(float64(d.HalfLife)*math.Log2(f.penalty/d.ReuseThreshold)))
+	}
+	n.flaps[key] = f
+	return suppress
+}
+
+// reuse lifts the suppression of routes whose penalty decayed below the reuse threshold.
+// Returns true if any route became eligible again.
+func (n *BgpNode) reuse(now time.Duration) bool {
+	n.forget(now)
+	reused := false
+	for key := range n.suppressed {
+		f := n.flaps[key]
+		if now < f.reuseAt {
+			continue
+		}
+		f.suppressed = false
+		n.flaps[key] = f
+		delete(n.suppressed, key)
+		n.dirty[key.prefix] = struct{}{}
+		reused = true
+	}
+	return reused
+}
+
+// forget drops the state of routes that are not suppressed once their penalty decayed below half
+// the reuse threshold, as RFC 2439 allows
+func (n *BgpNode) forget(now time.Duration) {
+	d := &n.dampening
+	for key, f := range n.flaps {
+		if !f.suppressed && (!d.enabled() || f.decay(d, now) < d.ReuseThreshold/2) {
+			delete(n.flaps, key)
+		}
+	}
+}
+
+// nextReuse returns the earliest time a suppressed route becomes eligible again.
+// Suppressed prefixes without received routes are reused lazily on a later step.
+func (n *BgpNode) nextReuse() (time.Duration, bool) {
+	var at time.Duration
+	found := false
+	for key := range n.suppressed {
+		if len(n.adjRibIn[key.session][key.prefix]) == 0 {
+			continue
+		}
+		if f := n.flaps[key]; !found || f.reuseAt < at {
+			at, found = f.reuseAt, true
+		}
+	}
+	return at, found
+}
+
+// dampedRoutes returns the received routes of a prefix excluded from best path selection
+func (n *BgpNode) dampedRoutes(prefix netip.Prefix) map[*route.BgpRoute]struct{} {
+	if len(n.suppressed) == 0 {
+		return nil
+	}
+	damped := make(map[*route.BgpRoute]struct{})
+	for _, r := range n.Dampened(prefix) {
+		damped[r] = struct{}{}
+	}
+	return damped
+}
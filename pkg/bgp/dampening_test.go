@@ -0,0 +1,113 @@
+package bgp
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// flap announces and withdraws a fed route every minute, ending with an announcement
+func flap(t *testing.T, sim *Simulation, b *BgpNode, r *route.BgpRoute, flaps int) {
+	t.Helper()
+	for i := range 2*flaps + 1 {
+		action := ra.Add
+		if i%2 == 1 {
+			action = ra.Remove
+		}
+		sim.AdvanceTo(time.Duration(i) * time.Minute)
+		b.Feed("collector", ra.RouteAdv[*route.BgpRoute]{Route: r, Action: action})
+		if err := sim.Run(); err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+	}
+}
+
+func TestBgpNode_Dampening(t *testing.T) {
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	r := route.New(route.WithPrefix(prefix), route.WithAsPath([]uint32{64500}))
+	d := Dampening{
+		HalfLife:          15 * time.Minute,
+		ReuseThreshold:    750,
+		SuppressThreshold: 2000,
+		MaxSuppressTime:   time.Hour,
+		WithdrawalPenalty: 1000,
+	}
+
+	topo, _, b, c := makeChain()
+	b.SetDampening(d)
+	b.AddFeed("collector", 64500)
+	sim := NewSimulation(topo)
+
+	// The third withdrawal at 5m pushes the penalty to about 2743, the last announcement is suppressed
+	flap(t, sim, b, r, 3)
+	if _, ok := b.LocRib(prefix); ok || len(b.Dampened(prefix)) != 1 {
+		t.Fatalf("Expected the route suppressed at b, dampened %v", b.Dampened(prefix))
+	}
+	if _, ok := c.LocRib(prefix); ok {
+		t.Error("Expected no route at c while suppressed")
+	}
+	if got := sim.Stats().Suppressions; got != 1 {
+		t.Errorf("Suppressions = %d, expected 1", got)
+	}
+
+	// Reused about 28 minutes after the last flap
+	sim.AdvanceTo(30 * time.Minute)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := b.LocRib(prefix); ok {
+		t.Fatal("Expected the route still suppressed at 30m")
+	}
+	sim.AdvanceTo(35 * time.Minute)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(prefix); !ok || len(b.Dampened(prefix)) != 0 {
+		t.Fatal("Expected the route reused and propagated to c at 35m")
+	}
+
+	// The state is dropped once the penalty decays below half the reuse threshold
+	sim.AdvanceTo(time.Hour)
+	b.Feed("collector", ra.RouteAdv[*route.BgpRoute]{Route: r, Action: ra.Add})
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if len(b.flaps) != 0 {
+		t.Errorf("Expected no flap state left, got %v", b.flaps)
+	}
+
+	// Two flaps stay below the suppress threshold
+	topo, _, b, c = makeChain()
+	b.SetDampening(d)
+	b.AddFeed("collector", 64500)
+	sim = NewSimulation(topo)
+	flap(t, sim, b, r, 2)
+	if _, ok := c.LocRib(prefix); !ok {
+		t.Error("Expected the route propagated after two flaps")
+	}
+}
+
+func TestBgpNode_DampeningTimed(t *testing.T) {
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	r := route.New(route.WithPrefix(prefix), route.WithAsPath([]uint32{64500}))
+	d := DefaultDampening()
+	d.SuppressThreshold = 2000
+
+	topo, _, b, c := makeChain()
+	b.SetDampening(d)
+	b.AddFeed("collector", 64500)
+	sim := NewSimulation(topo, WithTiming(Timing{Delay: time.Millisecond}))
+
+	// The run after the last announcement lasts until the route is reused
+	flap(t, sim, b, r, 3)
+	if _, ok := c.LocRib(prefix); !ok {
+		t.Fatal("Expected the route reused within the run")
+	}
+	stats := sim.Stats()
+	if stats.Suppressions != 1 || stats.Time < 33*time.Minute || stats.Time > 34*time.Minute {
+		t.Errorf("Expected reuse about 28 minutes after the last flap, got %+v", stats)
+	}
+}
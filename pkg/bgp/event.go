@@ -0,0 +1,386 @@
+package bgp
+
+import (
+	"container/heap"
+	"maps"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Timing configures the discrete-event model of Run
+type Timing struct {
+	Mrai       time.Duration // Minimum Route Advertisement Interval of eBGP sessions
+	IbgpMrai   time.Duration // Minimum Route Advertisement Interval of iBGP sessions
+	Delay      time.Duration // Propagation delay of links
+	Processing time.Duration // Delay before a node processes received updates
+	Jitter     float64       // MRAI and delays are scaled by a random factor in [1-Jitter, 1]
+	Seed       int64         // Seed of the jitter RNG
+}
+
+// DefaultTiming returns the RFC 4271 timers with a 10ms link delay and a 1ms processing delay
+func DefaultTiming() Timing {
+	return Timing{
+		Mrai:       30 * time.Second,
+		IbgpMrai:   5 * time.Second,
+		Delay:      10 * time.Millisecond,
+		Processing: time.Millisecond,
+		Jitter:     0.25,
+		Seed:       1,
+	}
+}
+
+// Stats counts the work done by a simulation
+type Stats struct {
+	Updates      int           // Announcements sent over sessions
+	Withdrawals  int           // Withdrawals sent over sessions
+	Suppressions int           // Received routes suppressed by flap dampening
+	Time         time.Duration // Simulated time, see Now
+	Convergence  time.Duration // Time from the start of the last event-driven run until its last update was processed
+}
+
+// WithTiming makes Run a discrete-event simulation: updates take time to cross links and be processed,
+// and announcements are paced by MRAI timers per session. Withdrawals are not delayed by MRAI.
+func WithTiming(t Timing) func(*Simulation) {
+	return func(s *Simulation) {
+		s.events = newEngine(t)
+	}
+}
+
+// SetLinkDelay overrides the propagation delay of a link, identified by either end.
+// Returns false if the simulation has no timing model or no such link exists.
+func (s *Simulation) SetLinkDelay(id LinkID, d time.Duration) bool {
+	p, ok := s.topology.GetPeerByIface(id.Node, id.Iface)
+	if !ok || s.events == nil {
+		return false
+	}
+	s.events.delays[p.localKey()] = d
+	s.events.delays[p.remoteKey()] = d
+	return true
+}
+
+// SetMrai overrides the MRAI a node applies to the session on one of its interfaces.
+// Returns false if the simulation has no timing model or no such session exists.
+func (s *Simulation) SetMrai(id LinkID, d time.Duration) bool {
+	p, ok := s.topology.GetPeerByIface(id.Node, id.Iface)
+	if !ok || s.events == nil {
+		return false
+	}
+	s.events.mrais[p.localKey()] = d
+	return true
+}
+
+// Stats returns the counters accumulated over all runs
+func (s *Simulation) Stats() Stats {
+	st := s.stats
+	st.Time = s.Now()
+	return st
+}
+
+type eventKind int
+
+const (
+	deliverEvent eventKind = iota
+	stepEvent
+	mraiEvent
+	reuseEvent
+	restartEvent
+)
+
+type event struct {
+	at   time.Duration
+	seq  uint64 // Orders events due at the same time
+	kind eventKind
+	node *BgpNode // Receiving, stepping or reusing node
+	key  string   // Session key of the sender: queue key on delivery, MRAI timer on expiry
+	advs []ra.RouteAdv[*route.BgpRoute]
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+// outgoing is an advertisement waiting for the end of the step that produced it
+type outgoing struct {
+	node  string
+	iface string
+	adv   ra.RouteAdv[*route.BgpRoute]
+}
+
+// mraiTimer paces the announcements of one session
+type mraiTimer struct {
+	running bool
+	pending map[netip.Prefix]ra.RouteAdv[*route.BgpRoute] // Latest held announcement per prefix
+}
+
+// engine holds the state of the discrete-event model.
+// Between runs its queue is empty and every timer has expired, only sends made by topology changes are waiting.
+type engine struct {
+	timing Timing
+	delays map[string]time.Duration // Link delay overrides by session key of either end
+	mrais  map[string]time.Duration // MRAI overrides by session key of the sender
+
+	rng      *rand.Rand
+	now      time.Duration
+	seq      uint64
+	queue    eventQueue
+	stepping map[*BgpNode]struct{}      // Nodes with a step scheduled
+	linkFree map[string]time.Duration   // Last delivery per sender session key, keeps links FIFO
+	timers   map[string]*mraiTimer      // By sender session key
+	reusing  map[*BgpNode]time.Duration // Earliest scheduled reuse of dampened routes per node
+	outbox   []outgoing                 // Produced by the current step
+	lastWork time.Duration
+}
+
+func newEngine(t Timing) *engine {
+	return &engine{
+		timing:   t,
+		delays:   make(map[string]time.Duration),
+		mrais:    make(map[string]time.Duration),
+		rng:      rand.New(rand.NewSource(t.Seed)),
+		stepping: make(map[*BgpNode]struct{}),
+		linkFree: make(map[string]time.Duration),
+		timers:   make(map[string]*mraiTimer),
+		reusing:  make(map[*BgpNode]time.Duration),
+	}
+}
+
+// clone copies the configuration and virtual time, the RNG restarts from the seed
+func (e *engine) clone() *engine {
+	c := newEngine(e.timing)
+	maps.Copy(c.delays, e.delays)
+	maps.Copy(c.mrais, e.mrais)
+	c.now = e.now
+	c.outbox = slices.Clone(e.outbox)
+	return c
+}
+
+func (e *engine) schedule(ev *event) {
+	e.seq++
+	ev.seq = e.seq
+	heap.Push(&e.queue, ev)
+}
+
+// jitter scales a duration by a random factor in [1-Jitter, 1]
+func (e *engine) jitter(d time.Duration) time.Duration {
+	if e.timing.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 - e.timing.Jitter*e.rng.Float64()))
+}
+
+func (e *engine) scheduleStep(n *BgpNode, at time.Duration) {
+	if _, ok := e.stepping[n]; ok || n.external {
+		return
+	}
+	e.stepping[n] = struct{}{}
+	e.schedule(&event{at: at, kind: stepEvent, node: n})
+}
+
+// scheduleReuse wakes a node up when its next dampened route becomes eligible again
+func (e *engine) scheduleReuse(n *BgpNode) {
+	at, ok := n.nextReuse()
+	if !ok {
+		return
+	}
+	if prev, ok := e.reusing[n]; ok && prev <= at {
+		return
+	}
+	e.reusing[n] = at
+	e.schedule(&event{at: at, kind: reuseEvent, node: n})
+}
+
+// flush sends the advertisements produced since the last flush.
+// Withdrawals leave at once, announcements wait for the MRAI timer of their session.
+func (e *engine) flush(s *Simulation) {
+	outbox := e.outbox
+	e.outbox = nil
+	sessions := make(map[string][]outgoing)
+	peers := make(map[string]BgpPeer)
+	for _, o := range outbox {
+		p, ok := s.topology.GetPeerByIface(o.node, o.iface)
+		if !ok {
+			continue // Link removed since
+		}
+		sessions[p.localKey()] = append(sessions[p.localKey()], o)
+		peers[p.localKey()] = p
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(sessions)) {
+		p := peers[key]
+		t, ok := e.timers[key]
+		if !ok {
+			t = &mraiTimer{pending: make(map[netip.Prefix]ra.RouteAdv[*route.BgpRoute])}
+			e.timers[key] = t
+		}
+		var now []ra.RouteAdv[*route.BgpRoute]
+		for _, o := range sessions[key] {
+			prefix := o.adv.Route.Prefix()
+			switch {
+			case o.adv.Action == ra.Remove:
+				delete(t.pending, prefix)
+				now = append(now, o.adv)
+			case t.running:
+				t.pending[prefix] = o.adv
+			default:
+				now = append(now, o.adv)
+			}
+		}
+		e.transmit(s, &p, now)
+		if !t.running && slices.ContainsFunc(now, func(adv ra.RouteAdv[*route.BgpRoute]) bool { return adv.Action == ra.Add }) {
+			e.startTimer(&p, t)
+		}
+	}
+}
+
+func (e *engine) mrai(p *BgpPeer) time.Duration {
+	if d, ok := e.mrais[p.localKey()]; ok {
+		return d
+	}
+	if p.ibgp() {
+		return e.timing.IbgpMrai
+	}
+	return e.timing.Mrai
+}
+
+func (e *engine) startTimer(p *BgpPeer, t *mraiTimer) {
+	d := e.jitter(e.mrai(p))
+	if d <= 0 {
+		return
+	}
+	t.running = true
+	e.schedule(&event{at: e.now + d, kind: mraiEvent, node: p.LocalNode, key: p.localKey()})
+}
+
+// expire releases the announcements held by an MRAI timer and restarts it if any were sent
+func (e *engine) expire(s *Simulation, ev *event) {
+	t := e.timers[ev.key]
+	t.running = false
+	if len(t.pending) == 0 {
+		return
+	}
+	held := t.pending
+	t.pending = make(map[netip.Prefix]ra.RouteAdv[*route.BgpRoute])
+	for _, p := range s.topology.GetPeers(ev.node.name) {
+		if p.localKey() != ev.key || !ev.node.SessionUp(p.LocalIface) {
+			continue
+		}
+		var advs []ra.RouteAdv[*route.BgpRoute]
+		for _, prefix := range slices.SortedFunc(maps.Keys(held), comparePrefix) {
+			advs = append(advs, held[prefix])
+		}
+		e.transmit(s, &p, advs)
+		e.startTimer(&p, t)
+	}
+}
+
+// transmit puts advertisements on the wire, they reach the remote node after the link delay
+func (e *engine) transmit(s *Simulation, p *BgpPeer, advs []ra.RouteAdv[*route.BgpRoute]) {
+	if len(advs) == 0 {
+		return
+	}
+	for _, adv := range advs {
+		s.count(adv)
+		s.observe(p, adv)
+	}
+	delay, ok := e.delays[p.localKey()]
+	if !ok {
+		delay = e.timing.Delay
+	}
+	at := max(e.now+e.jitter(delay), e.linkFree[p.localKey()])
+	e.linkFree[p.localKey()] = at
+	e.schedule(&event{at: at, kind: deliverEvent, node: p.RemoteNode, key: p.localKey(), advs: advs})
+}
+
+// trip tears down the sessions past their maximum-prefix limit, their ends process the withdrawals
+// after the processing delay and the sessions restart after their timer
+func (e *engine) trip(s *Simulation) {
+	for _, p := range s.applyTrips() {
+		e.scheduleStep(p.LocalNode, e.now+e.jitter(e.timing.Processing))
+		e.scheduleStep(p.RemoteNode, e.now+e.jitter(e.timing.Processing))
+		if p.MaxPrefix.Restart > 0 {
+			e.schedule(&event{at: e.now + p.MaxPrefix.Restart, kind: restartEvent})
+		}
+	}
+}
+
+// run processes events until none are left
+func (e *engine) run(s *Simulation) error {
+	nodes := s.topology.Nodes()
+	start := e.now
+	e.lastWork = start
+	e.flush(s)
+	for _, n := range nodes {
+		e.scheduleStep(n, e.now)
+	}
+
+	steps, limit := 0, s.maxRounds*max(len(nodes), 1)
+	for e.queue.Len() != 0 {
+		ev := heap.Pop(&e.queue).(*event)
+		e.now = ev.at
+		switch ev.kind {
+		case deliverEvent:
+			tx := ev.node.queue.BeginTx()
+			for _, adv := range ev.advs {
+				tx.Push(ev.key, adv)
+			}
+			tx.Commit()
+			e.scheduleStep(ev.node, e.now+e.jitter(e.timing.Processing))
+		case stepEvent:
+			delete(e.stepping, ev.node)
+			if steps++; steps > limit {
+				e.reset()
+				return ErrNoConvergence
+			}
+			if ev.node.step(s) {
+				e.lastWork = e.now
+			}
+			e.flush(s)
+			e.scheduleReuse(ev.node)
+			e.trip(s)
+		case mraiEvent:
+			e.expire(s, ev)
+		case reuseEvent:
+			if e.reusing[ev.node] == ev.at {
+				delete(e.reusing, ev.node)
+			}
+			e.scheduleStep(ev.node, e.now)
+		case restartEvent:
+			s.restartDue()
+			e.flush(s)
+		}
+	}
+	s.stats.Convergence = e.lastWork - start
+	return nil
+}
+
+// reset drops pending events and timers after an aborted run
+func (e *engine) reset() {
+	e.queue = nil
+	e.outbox = nil
+	clear(e.stepping)
+	clear(e.timers)
+	clear(e.reusing)
+}
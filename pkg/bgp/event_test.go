@@ -0,0 +1,89 @@
+package bgp
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// a - b - x - o1 and b - o2 over a slow link, o1 and o2 originate the same prefix.
+// b first learns the longer path through x, the shorter one from o2 arrives later.
+func makeRace(timing Timing) (*Simulation, *BgpNode) {
+	nodes := make(map[string]*BgpNode)
+	for i, name := range []string{"a", "b", "x", "o1", "o2"} {
+		nodes[name] = NewBgpNode(name, uint32(65001+i))
+	}
+	builder := &BgpTopologyBuilder{}
+	for i, pair := range [][2]string{{"a", "b"}, {"b", "x"}, {"x", "o1"}, {"b", "o2"}} {
+		builder.AddPeer(BgpPeer{
+			LocalNode:    nodes[pair[0]],
+			RemoteNode:   nodes[pair[1]],
+			LocalPrefix:  netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 1}), 30),
+			RemotePrefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 2}), 30),
+			LocalIface:   fmt.Sprintf("to-%s", pair[1]),
+			RemoteIface:  fmt.Sprintf("to-%s", pair[0]),
+		})
+	}
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	nodes["o1"].Originate(route.New(route.WithPrefix(prefix)))
+	nodes["o2"].Originate(route.New(route.WithPrefix(prefix)))
+
+	sim := NewSimulation(builder.Build(), WithTiming(timing))
+	sim.SetLinkDelay(LinkID{Node: "o2", Iface: "to-b"}, 100*time.Millisecond)
+	return sim, nodes["a"]
+}
+
+func TestSimulation_Timing(t *testing.T) {
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	timing := Timing{Mrai: 30 * time.Second, Delay: 10 * time.Millisecond, Processing: time.Millisecond}
+
+	sim, a := makeRace(timing)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	rs, ok := a.LocRib(prefix)
+	if !ok || len(rs.BestPath().AsPath()) != 2 {
+		t.Fatalf("Expected a to converge on the path through o2, got %v", rs)
+	}
+	// The better path is held by the MRAI timer of b towards a
+	stats := sim.Stats()
+	if stats.Convergence < timing.Mrai || stats.Convergence > timing.Mrai+time.Second {
+		t.Errorf("Convergence = %v, expected about the MRAI", stats.Convergence)
+	}
+
+	// Without MRAI the last update is the withdrawal of the path through x sent by b to o2,
+	// after the better path crossed the slow link: 101ms, 100ms back and 1ms processing
+	timing.Mrai = 0
+	sim, _ = makeRace(timing)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if got, want := sim.Stats().Convergence, 202*time.Millisecond; got != want {
+		t.Errorf("Convergence = %v, expected %v", got, want)
+	}
+	if got := sim.Stats(); got.Updates != stats.Updates || got.Withdrawals != 1 {
+		t.Errorf("Expected %d updates and 1 withdrawal, got %+v", stats.Updates, got)
+	}
+}
+
+func TestSimulation_TimingJitter(t *testing.T) {
+	timing := DefaultTiming()
+	timing.Seed = 42
+	var runs []Stats
+	for range 2 {
+		sim, _ := makeRace(timing)
+		if err := sim.Run(); err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+		runs = append(runs, sim.Stats())
+	}
+	if runs[0] != runs[1] {
+		t.Errorf("Runs with the same seed differ: %+v and %+v", runs[0], runs[1])
+	}
+	if runs[0].Convergence >= timing.Mrai {
+		t.Errorf("Convergence = %v, expected MRAI to be jittered below %v", runs[0].Convergence, timing.Mrai)
+	}
+}
@@ -0,0 +1,39 @@
+package bgp
+
+import (
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Inject queues advertisements sent by an external node over the session on one of its interfaces.
+// Returns false if the node is not external or has no such session.
+func (s *Simulation) Inject(id LinkID, advs ...ra.RouteAdv[*route.BgpRoute]) bool {
+	p, ok := s.externalPeer(id)
+	if !ok {
+		return false
+	}
+	tx := p.RemoteNode.queue.BeginTx()
+	for _, adv := range advs {
+		tx.Push(p.localKey(), adv)
+	}
+	tx.Commit()
+	return true
+}
+
+// Drain returns the advertisements an external node received over the session on one of its interfaces
+// since the last call, in the order they were sent
+func (s *Simulation) Drain(id LinkID) []ra.RouteAdv[*route.BgpRoute] {
+	p, ok := s.externalPeer(id)
+	if !ok {
+		return nil
+	}
+	return p.LocalNode.queue.PopAll(p.remoteKey())
+}
+
+func (s *Simulation) externalPeer(id LinkID) (BgpPeer, bool) {
+	n, ok := s.topology.Node(id.Node)
+	if !ok || !n.external {
+		return BgpPeer{}, false
+	}
+	return s.topology.GetPeerByIface(id.Node, id.Iface)
+}
@@ -0,0 +1,70 @@
+package bgp
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+const (
+	feedKeyPrefix = "feed:"
+)
+
+// session describes the receiving side of a BGP session
+type session struct {
+	key   string // Queue and Adj-RIB-In key
+	iface string // Policy key
+	rx    route.RxFrom
+	ibgp  bool
+}
+
+// feedSession returns the receiving side of a feed
+func (n *BgpNode) feedSession(name string) session {
+	return session{
+		key:   feedKeyPrefix + name,
+		iface: name,
+		rx:    route.NewRxFrom(route.WithInterface(name)),
+		ibgp:  n.feeds[name] == n.asn,
+	}
+}
+
+// AddFeed registers a session to a peer outside the topology, such as a route collector peer.
+// Fed routes are assumed reachable and are not subject to next hop resolution.
+// The feed name also keys its import policy.
+func (n *BgpNode) AddFeed(name string, peerAsn uint32) {
+	key := feedKeyPrefix + name
+	if _, ok := n.closing[key]; ok {
+		n.closeSession(key)
+	}
+	n.feeds[name] = peerAsn
+}
+
+// RemoveFeed tears down a feed, withdrawing its routes on the next step
+func (n *BgpNode) RemoveFeed(name string) {
+	if _, ok := n.feeds[name]; !ok {
+		return
+	}
+	delete(n.feeds, name)
+	n.teardown(feedKeyPrefix + name)
+}
+
+// Feeds returns the names of all feeds in sorted order
+func (n *BgpNode) Feeds() []string {
+	return slices.Sorted(maps.Keys(n.feeds))
+}
+
+// Feed queues advertisements received over a feed, they are applied on the next step.
+// Returns false if the feed does not exist.
+func (n *BgpNode) Feed(name string, advs ...ra.RouteAdv[*route.BgpRoute]) bool {
+	if _, ok := n.feeds[name]; !ok {
+		return false
+	}
+	tx := n.queue.BeginTx()
+	for _, adv := range advs {
+		tx.Push(feedKeyPrefix+name, adv)
+	}
+	tx.Commit()
+	return true
+}
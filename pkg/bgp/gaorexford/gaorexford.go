@@ -0,0 +1,83 @@
+package gaorexford
+
+import (
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// Route tags marking the relationship a route was learned over.
+// Tags are kept on iBGP sessions, so border routers of the same AS agree.
+const (
+	CustomerTag = 65001
+	PeerTag     = 65002
+	ProviderTag = 65003
+	SiblingTag  = 65004
+)
+
+// Local preferences per relationship
+const (
+	CustomerPreference = 200
+	SiblingPreference  = 200
+	PeerPreference     = 100
+	ProviderPreference = 50
+)
+
+// ImportPolicy returns the import route map for a session with the given relationship.
+// Returns nil for Unspecified sessions, leaving them unfiltered.
+func ImportPolicy(rel bgp.Relationship) *policy.RouteMap {
+	var tag int
+	var pref uint32
+	switch rel {
+	case bgp.Customer:
+		tag, pref = CustomerTag, CustomerPreference
+	case bgp.Peer:
+		tag, pref = PeerTag, PeerPreference
+	case bgp.Provider:
+		tag, pref = ProviderTag, ProviderPreference
+	case bgp.Sibling:
+		tag, pref = SiblingTag, SiblingPreference
+	default:
+		return nil
+	}
+	return policy.New(policy.Clause{
+		Match:  []policy.Match{policy.MatchAny()},
+		Set:    []func(*route.BgpRoute){route.WithTag(tag), route.WithLocalPreference(pref)},
+		Action: policy.Permit,
+	})
+}
+
+// ExportPolicy returns the export route map for a session with the given relationship.
+// Routes tagged as learned from peers and providers are denied towards peers and providers,
+// which is what keeps paths valley-free. Returns nil for sessions that receive every route.
+func ExportPolicy(rel bgp.Relationship) *policy.RouteMap {
+	if rel != bgp.Peer && rel != bgp.Provider {
+		return nil
+	}
+	return policy.New(
+		policy.Clause{
+			Match:      []policy.Match{policy.MatchTag(PeerTag, ProviderTag)},
+			Action:     policy.Deny,
+			ValleyFree: true,
+		},
+		policy.Clause{
+			Match:  []policy.Match{policy.MatchAny()},
+			Action: policy.Permit,
+		},
+	)
+}
+
+// Apply installs the generated policies on every session of a topology with a relationship.
+// Routes are then preferred customer > peer > provider and only customer, sibling and own routes
+// are exported to peers and providers.
+func Apply(topology *bgp.BgpTopology) {
+	for _, n := range topology.Nodes() {
+		for _, p := range topology.GetPeers(n.Name()) {
+			if p.Relationship == bgp.Unspecified {
+				continue
+			}
+			n.SetImportPolicy(p.LocalIface, ImportPolicy(p.Relationship))
+			n.SetExportPolicy(p.LocalIface, ExportPolicy(p.Relationship))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package gaorexford
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+var target = netip.MustParsePrefix("203.0.113.0/24")
+
+// x buys transit from p and sells it to c, o reaches p directly and c through d.
+// y peers with x.
+func makeTopology() (*bgp.BgpTopology, map[string]*bgp.BgpNode) {
+	nodes := make(map[string]*bgp.BgpNode)
+	for i, name := range []string{"c", "d", "o", "p", "x", "y"} {
+		nodes[name] = bgp.NewBgpNode(name, uint32(65001+i))
+	}
+
+	builder := &bgp.BgpTopologyBuilder{}
+	subnet := netip.MustParseAddr("10.0.0.0")
+	link := func(local, remote string, rel bgp.Relationship) {
+		builder.AddPeer(bgp.BgpPeer{
+			LocalNode:    nodes[local],
+			RemoteNode:   nodes[remote],
+			LocalPrefix:  netip.PrefixFrom(subnet.Next(), 30),
+			RemotePrefix: netip.PrefixFrom(subnet.Next().Next(), 30),
+			LocalIface:   "to-" + remote,
+			RemoteIface:  "to-" + local,
+			Relationship: rel,
+		})
+		subnet = subnet.Next().Next().Next().Next()
+	}
+	link("x", "p", bgp.Provider)
+	link("x", "c", bgp.Customer)
+	link("x", "y", bgp.Peer)
+	link("p", "o", bgp.Customer)
+	link("c", "d", bgp.Customer)
+	link("d", "o", bgp.Customer)
+
+	nodes["o"].Originate(route.New(route.WithPrefix(target)))
+	return builder.Build(), nodes
+}
+
+func nextAs(t *testing.T, n *bgp.BgpNode) uint32 {
+	t.Helper()
+	rs, ok := n.LocRib(target)
+	if !ok {
+		t.Fatalf("Expected %s to have a route", n.Name())
+	}
+	return rs.BestPath().AsPath()[0]
+}
+
+func TestApply(t *testing.T) {
+	topo, nodes := makeTopology()
+	sim := bgp.NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	// Shortest path through the provider
+	if asn := nextAs(t, nodes["x"]); asn != nodes["p"].Asn() {
+		t.Fatalf("Expected x to route through p before policies, got AS %d", asn)
+	}
+
+	Apply(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	rs, _ := nodes["x"].LocRib(target)
+	best := rs.BestPath()
+	if best.AsPath()[0] != nodes["c"].Asn() {
+		t.Errorf("Expected x to prefer its customer c, got AS path %v", best.AsPath())
+	}
+	if best.LocalPreference() != CustomerPreference || best.Tag() != CustomerTag {
+		t.Errorf("Expected customer preference and tag, got %d and %d", best.LocalPreference(), best.Tag())
+	}
+
+	// y learns the customer route from x over the peering, tags are not carried over
+	rs, ok := nodes["y"].LocRib(target)
+	if !ok {
+		t.Fatal("Expected y to learn the customer route of x")
+	}
+	if best := rs.BestPath(); best.Tag() != PeerTag || best.LocalPreference() != PeerPreference {
+		t.Errorf("Expected y to tag the route as learned from a peer, got %d", best.Tag())
+	}
+}
+
+func TestApply_Export(t *testing.T) {
+	topo, nodes := makeTopology()
+	Apply(topo)
+	// x only keeps the provider route
+	nodes["x"].SetImportPolicy("to-c", policy.New(policy.Clause{
+		Match:  []policy.Match{policy.MatchPrefix(target)},
+		Action: policy.Deny,
+	}))
+	if err := bgp.NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if asn := nextAs(t, nodes["x"]); asn != nodes["p"].Asn() {
+		t.Fatalf("Expected x to route through p, got AS %d", asn)
+	}
+	if _, ok := nodes["y"].LocRib(target); ok {
+		t.Error("Expected the provider route of x not to be exported to its peer y")
+	}
+	if asn := nextAs(t, nodes["c"]); asn != nodes["d"].Asn() {
+		t.Errorf("Expected c to keep its customer route, got AS %d", asn)
+	}
+}
+
+func TestExportPolicy(t *testing.T) {
+	rels := []bgp.Relationship{bgp.Customer, bgp.Sibling, bgp.Peer, bgp.Provider}
+	for _, tag := range []int{0, CustomerTag, SiblingTag, PeerTag, ProviderTag} {
+		r := route.New(route.WithPrefix(target), route.WithTag(tag))
+		for _, rel := range rels {
+			_, permitted := ExportPolicy(rel).Apply(r)
+			expected := rel == bgp.Customer || rel == bgp.Sibling || (tag != PeerTag && tag != ProviderTag)
+			if permitted != expected {
+				t.Errorf("Export of a route tagged %d to a %s = %t, expected %t", tag, rel, permitted, expected)
+			}
+			// Leakers skip the valley-free rule
+			if _, leaked := ExportPolicy(rel).ApplyLeaking(r); !leaked {
+				t.Errorf("Expected a leaker to export a route tagged %d to a %s", tag, rel)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package bgp
+
+import (
+	"slices"
+	"time"
+)
+
+// MaxPrefix limits the number of prefixes a node accepts over a session, counted after import policy.
+// A session that keeps exceeding its limit is torn down again after every restart, so an event-driven
+// run does not converge.
+type MaxPrefix struct {
+	Limit       int           // Prefixes accepted before the session is torn down, zero disables the limit
+	Warning     int           // Prefixes at which a warning is recorded, zero for none
+	WarningOnly bool          // Record exceeding the limit without tearing the session down
+	Restart     time.Duration // Simulated time after which a torn down session is re-established, zero keeps it down
+}
+
+// MaxPrefixAction is what a maximum-prefix limit did
+type MaxPrefixAction int
+
+const (
+	MaxPrefixWarning  MaxPrefixAction = iota // Warning threshold reached
+	MaxPrefixExceeded                        // Limit exceeded on a warning-only session
+	MaxPrefixTeardown                        // Limit exceeded, the session was torn down
+	MaxPrefixRestart                         // Session re-established after its restart timer
+)
+
+func (a MaxPrefixAction) String() string {
+	switch a {
+	case MaxPrefixWarning:
+		return "warning"
+	case MaxPrefixExceeded:
+		return "exceeded"
+	case MaxPrefixTeardown:
+		return "teardown"
+	case MaxPrefixRestart:
+		return "restart"
+	}
+	return "unknown"
+}
+
+// MaxPrefixEvent records a maximum-prefix limit firing on a session
+type MaxPrefixEvent struct {
+	Peer     BgpPeer // Session, from the side enforcing the limit
+	Action   MaxPrefixAction
+	Prefixes int           // Prefixes accepted over the session when the limit fired
+	Clock    int64         // Logical time
+	Time     time.Duration // Simulated time
+}
+
+// prefixLevel is how far a session got towards its limit
+type prefixLevel int
+
+const (
+	belowWarning prefixLevel = iota
+	pastWarning
+	pastLimit
+)
+
+// restart is a session torn down by its limit waiting to be re-established
+type restart struct {
+	peer BgpPeer
+	at   time.Duration
+}
+
+// SetMaxPrefix sets the limit a node applies to the session on one of its interfaces.
+// It is checked on the next run. Returns false if no such session exists.
+func (s *Simulation) SetMaxPrefix(id LinkID, m MaxPrefix) bool {
+	peers := s.topology.peerMap[id.Node]
+	i := slices.IndexFunc(peers, func(p BgpPeer) bool { return p.LocalIface == id.Iface })
+	if i < 0 {
+		return false
+	}
+	peers[i].MaxPrefix = m
+	p := peers[i]
+	for j, q := range s.topology.peerMap[p.RemoteNode.name] {
+		if q.localKey() == p.remoteKey() {
+			s.topology.peerMap[p.RemoteNode.name][j].RemoteMaxPrefix = m
+		}
+	}
+	return true
+}
+
+// MaxPrefixEvents returns the maximum-prefix events of all runs in the order they fired.
+// Clones of the simulation start without events.
+func (s *Simulation) MaxPrefixEvents() []MaxPrefixEvent {
+	return slices.Clone(s.maxPrefixEvents)
+}
+
+// checkMaxPrefix compares the prefixes accepted over each session with its limit.
+// Sessions past their limit are torn down once the step is over.
+func (n *BgpNode) checkMaxPrefix(sim *Simulation, peers []BgpPeer) {
+	for _, p := range peers {
+		m := p.MaxPrefix
+		key := p.remoteKey()
+		if m.Limit <= 0 {
+			delete(n.prefixLevels, key)
+			continue
+		}
+
+		count := len(n.adjRibIn[key])
+		level := belowWarning
+		switch {
+		case count > m.Limit:
+			level = pastLimit
+		case m.Warning > 0 && count >= m.Warning:
+			level = pastWarning
+		}
+		if level <= n.prefixLevels[key] {
+			if level == belowWarning {
+				delete(n.prefixLevels, key)
+			} else {
+				n.prefixLevels[key] = level
+			}
+			continue
+		}
+
+		n.prefixLevels[key] = level
+		switch {
+		case level == pastWarning:
+			sim.recordMaxPrefix(p, MaxPrefixWarning, count)
+		case m.WarningOnly:
+			sim.recordMaxPrefix(p, MaxPrefixExceeded, count)
+		default:
+			sim.recordMaxPrefix(p, MaxPrefixTeardown, count)
+			sim.trips = append(sim.trips, p)
+			delete(n.prefixLevels, key)
+		}
+	}
+}
+
+func (s *Simulation) recordMaxPrefix(p BgpPeer, action MaxPrefixAction, count int) {
+	s.maxPrefixEvents = append(s.maxPrefixEvents, MaxPrefixEvent{
+		Peer:     p,
+		Action:   action,
+		Prefixes: count,
+		Clock:    s.clock,
+		Time:     s.Now(),
+	})
+}
+
+// applyTrips takes the sessions that exceeded their limit administratively down, both ends withdraw the
+// routes learned over them. The links stay up. Returns the torn down sessions.
+func (s *Simulation) applyTrips() []BgpPeer {
+	trips := s.trips
+	s.trips = nil
+	var down []BgpPeer
+	for _, p := range trips {
+		if !s.shutdownSession(p.ID()) {
+			continue
+		}
+		down = append(down, p)
+		if p.MaxPrefix.Restart > 0 {
+			s.restarts = append(s.restarts, restart{peer: p, at: s.Now() + p.MaxPrefix.Restart})
+		}
+	}
+	return down
+}
+
+// restartDue re-establishes the torn down sessions whose restart timer expired.
+// Sessions whose link was removed in the meantime are dropped.
+func (s *Simulation) restartDue() {
+	now := s.Now()
+	s.restarts = slices.DeleteFunc(s.restarts, func(r restart) bool {
+		if r.at > now {
+			return false
+		}
+		if s.startSession(r.peer.ID()) {
+			s.recordMaxPrefix(r.peer, MaxPrefixRestart, 0)
+		}
+		return true
+	})
+}
@@ -0,0 +1,97 @@
+package bgp
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+func TestSimulation_MaxPrefix(t *testing.T) {
+	topo, a, b, c := makeChain()
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+	}
+	for _, p := range prefixes {
+		a.Originate(route.New(route.WithPrefix(p)))
+	}
+	sim := NewSimulation(topo)
+	id := LinkID{Node: "b", Iface: "eth0"}
+	if !sim.SetMaxPrefix(id, MaxPrefix{Limit: 2, Warning: 2, Restart: 10 * time.Minute}) {
+		t.Fatal("SetMaxPrefix() = false")
+	}
+
+	actions := func() []MaxPrefixAction {
+		var actions []MaxPrefixAction
+		for _, ev := range sim.MaxPrefixEvents() {
+			actions = append(actions, ev.Action)
+		}
+		return actions
+	}
+
+	// The session is torn down, its routes are withdrawn downstream
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if got := actions(); !slices.Equal(got, []MaxPrefixAction{MaxPrefixTeardown}) {
+		t.Fatalf("Expected a teardown, got %v", got)
+	}
+	if ev := sim.MaxPrefixEvents()[0]; ev.Peer.ID() != id || ev.Prefixes != 3 {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+	if len(b.LocRibPrefixes()) != 0 || len(c.LocRibPrefixes()) != 0 {
+		t.Fatal("Expected b and c to lose all routes")
+	}
+	if b.SessionUp("eth0") || a.SessionUp("eth0") {
+		t.Fatal("Expected the session to be down at both ends")
+	}
+	if _, ok := topo.GetPeerByIface("b", "eth0"); !ok {
+		t.Fatal("Expected the link to stay up")
+	}
+
+	// Not restarted before the timer expires
+	a.WithdrawOrigin(prefixes[2])
+	sim.AdvanceTo(5 * time.Minute)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if b.SessionUp("eth0") {
+		t.Fatal("Expected the session to stay down for the restart time")
+	}
+
+	// Restarted with two prefixes, which only warns
+	sim.AdvanceTo(10 * time.Minute)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	want := []MaxPrefixAction{MaxPrefixTeardown, MaxPrefixRestart, MaxPrefixWarning}
+	if got := actions(); !slices.Equal(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if got := c.LocRibPrefixes(); len(got) != 2 {
+		t.Errorf("Expected c to learn two prefixes after the restart, got %v", got)
+	}
+}
+
+func TestSimulation_MaxPrefixWarningOnly(t *testing.T) {
+	topo, a, _, c := makeChain()
+	a.Originate(route.New(route.WithPrefix(netip.MustParsePrefix("192.0.2.0/24"))))
+	a.Originate(route.New(route.WithPrefix(netip.MustParsePrefix("198.51.100.0/24"))))
+
+	sim := NewSimulation(topo, WithTiming(DefaultTiming()))
+	sim.SetMaxPrefix(LinkID{Node: "b", Iface: "eth0"}, MaxPrefix{Limit: 1, WarningOnly: true})
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	events := sim.MaxPrefixEvents()
+	if len(events) != 1 || events[0].Action != MaxPrefixExceeded || events[0].Time == 0 {
+		t.Fatalf("Expected one exceeded event, got %v", events)
+	}
+	if got := c.LocRibPrefixes(); len(got) != 2 {
+		t.Errorf("Expected the session to stay up, c has %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+package bgp
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+// nhKey identifies a tracked next hop.
+// Next hops inside the prefix they serve must not resolve through it, so that prefix is part of the key.
+type nhKey struct {
+	ip      netip.Addr
+	exclude netip.Prefix
+}
+
+func makeNhKey(ip netip.Addr, prefix netip.Prefix) nhKey {
+	k := nhKey{ip: ip}
+	if prefix.Contains(ip) {
+		k.exclude = prefix
+	}
+	return k
+}
+
+// nhEntry holds the resolution state of a tracked next hop
+type nhEntry struct {
+	resolved   []nexthop.NextHop
+	err        error
+	dependents map[netip.Prefix]struct{}
+}
+
+func (e *nhEntry) resolve(k nhKey, r *rib.Rib) {
+	if k.exclude.IsValid() {
+		e.resolved, e.err = r.Resolve(k.ip, k.exclude)
+	} else {
+		e.resolved, e.err = r.Resolve(k.ip)
+	}
+}
+
+// nhTracker tracks BGP next hops and the prefixes depending on them
+type nhTracker struct {
+	entries map[nhKey]*nhEntry
+	deps    map[netip.Prefix][]nhKey // prefix -> next hops used by its candidates
+}
+
+func newNhTracker() *nhTracker {
+	return &nhTracker{
+		entries: make(map[nhKey]*nhEntry),
+		deps:    make(map[netip.Prefix][]nhKey),
+	}
+}
+
+// clone returns a deep copy of the tracker
+func (t *nhTracker) clone() *nhTracker {
+	c := &nhTracker{
+		entries: make(map[nhKey]*nhEntry, len(t.entries)),
+		deps:    make(map[netip.Prefix][]nhKey, len(t.deps)),
+	}
+	for k, e := range t.entries {
+		c.entries[k] = &nhEntry{
+			resolved:   e.resolved,
+			err:        e.err,
+			dependents: maps.Clone(e.dependents),
+		}
+	}
+	for p, keys := range t.deps {
+		c.deps[p] = slices.Clone(keys)
+	}
+	return c
+}
+
+// track replaces the next hops prefix depends on, resolving new ones through r
+func (t *nhTracker) track(prefix netip.Prefix, ips []netip.Addr, r *rib.Rib) {
+	keys := make([]nhKey, 0, len(ips))
+	for _, ip := range ips {
+		k := makeNhKey(ip, prefix)
+		e, ok := t.entries[k]
+		if !ok {
+			e = &nhEntry{
+				dependents: make(map[netip.Prefix]struct{}),
+			}
+			e.resolve(k, r)
+			t.entries[k] = e
+		}
+		e.dependents[prefix] = struct{}{}
+		keys = append(keys, k)
+	}
+
+	for _, k := range t.deps[prefix] {
+		if slices.Contains(keys, k) {
+			continue
+		}
+		e, ok := t.entries[k]
+		if !ok {
+			continue
+		}
+		delete(e.dependents, prefix)
+		if len(e.dependents) == 0 {
+			delete(t.entries, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		delete(t.deps, prefix)
+	} else {
+		t.deps[prefix] = keys
+	}
+}
+
+// resolved reports whether ip resolves when used as a next hop for prefix.
+// The next hop must have been tracked first.
+func (t *nhTracker) resolved(ip netip.Addr, prefix netip.Prefix) bool {
+	e, ok := t.entries[makeNhKey(ip, prefix)]
+	return ok && e.err == nil
+}
+
+// refresh re-resolves every tracked next hop against r.
+// Returns the prefixes depending on next hops whose resolution changed.
+func (t *nhTracker) refresh(r *rib.Rib) []netip.Prefix {
+	var affected []netip.Prefix
+	for k, e := range t.entries {
+		prevResolved, prevErr := e.resolved, e.err
+		e.resolve(k, r)
+		if e.err == prevErr && slices.Equal(e.resolved, prevResolved) {
+			continue
+		}
+		for p := range e.dependents {
+			affected = append(affected, p)
+		}
+	}
+	return affected
+}
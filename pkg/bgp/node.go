@@ -0,0 +1,738 @@
+package bgp
+
+import (
+	"cmp"
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/rset"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+	"github.com/gaissmai/bart"
+)
+
+const (
+	ebgpAdminCost          = 20
+	ibgpAdminCost          = 200
+	defaultLocalPreference = 100
+	localWeight            = 32768
+	maxConvergeIterations  = 16 // Bounds next hop tracking feedback within a single step
+)
+
+// adjRib holds routes per prefix and path ID
+type adjRib map[netip.Prefix]map[int]*route.BgpRoute
+
+// add stores a route by prefix and path ID, replacing any previous one
+func (in adjRib) add(r *route.BgpRoute) {
+	paths, ok := in[r.Prefix()]
+	if !ok {
+		paths = make(map[int]*route.BgpRoute)
+		in[r.Prefix()] = paths
+	}
+	paths[r.PathID()] = r
+}
+
+// clone returns a copy sharing the immutable routes
+func (in adjRib) clone() adjRib {
+	c := make(adjRib, len(in))
+	for prefix, paths := range in {
+		c[prefix] = maps.Clone(paths)
+	}
+	return c
+}
+
+// remove deletes a route by prefix and path ID
+func (in adjRib) remove(r *route.BgpRoute) {
+	paths := in[r.Prefix()]
+	delete(paths, r.PathID())
+	if len(paths) == 0 {
+		delete(in, r.Prefix())
+	}
+}
+
+// BgpNode is a simulated BGP speaker
+type BgpNode struct {
+	name          string
+	asn           uint32
+	routerID      netip.Addr
+	multipath     bool
+	deterministic bool
+	leaker        bool
+	external      bool
+	dampening     Dampening
+
+	// Origination config
+	networks        map[netip.Prefix]Network
+	redistributions map[rib.Protocol]Redistribution
+	aggregates      map[netip.Prefix]Aggregate
+
+	// Per session policies, keyed by local interface
+	imports map[string]*policy.RouteMap
+	exports map[string]*policy.RouteMap
+
+	// Per session advertisement conditions, keyed by local interface
+	conditionals map[string]conditional
+	defaults     map[string]defaultOriginate
+
+	queue      *ra.RaQueue[*route.BgpRoute]                // Incoming advertisements, keyed by session
+	local      map[netip.Prefix]*route.BgpRoute            // Locally originated routes
+	injected   map[netip.Prefix]*route.BgpRoute            // Routes injected by network and redistribute statements
+	aggregated map[netip.Prefix]*route.BgpRoute            // Routes of aggregates with contributors
+	connected  map[uint32]rib.Route                        // Connected routes derived from peers
+	adjRibPre  map[string]adjRib                           // session -> received routes before import policy
+	adjRibIn   map[string]adjRib                           // session -> accepted routes
+	adjRibOut  map[string]map[netip.Prefix]*route.BgpRoute // session -> advertised routes
+	closing    map[string]struct{}                         // Torn down sessions with pending withdrawals
+	feeds      map[string]uint32                           // Feed name -> peer AS
+	locRib     map[netip.Prefix]*rset.RouteSet
+	locIndex   *bart.Table[struct{}]        // Loc-RIB prefixes, finds the contributors of aggregates
+	installed  map[netip.Prefix][]rib.Route // BGP routes installed in the RIB
+	rib        *rib.Rib
+	nht        *nhTracker
+
+	flaps      map[flapKey]flapState // Flap dampening state of received prefixes
+	suppressed map[flapKey]struct{}  // Keys of flaps whose routes are suppressed
+
+	prefixLevels map[string]prefixLevel // session -> progress towards its maximum-prefix limit
+	shutdown     map[string]struct{}    // Interfaces whose session is administratively down
+
+	dirty          map[netip.Prefix]struct{} // Prefixes pending best path selection
+	resend         bool                      // Every Loc-RIB prefix must be exported again
+	reimport       map[string]struct{}       // Interfaces whose import policy changed
+	ribChanged     bool
+	originsChanged bool // Non-BGP routes or origination config changed
+}
+
+// Create new BgpNode
+func NewBgpNode(name string, asn uint32, opts ...func(*BgpNode)) *BgpNode {
+	n := &BgpNode{
+		name:            name,
+		asn:             asn,
+		networks:        make(map[netip.Prefix]Network),
+		redistributions: make(map[rib.Protocol]Redistribution),
+		aggregates:      make(map[netip.Prefix]Aggregate),
+		imports:         make(map[string]*policy.RouteMap),
+		exports:         make(map[string]*policy.RouteMap),
+		conditionals:    make(map[string]conditional),
+		defaults:        make(map[string]defaultOriginate),
+		queue:           ra.NewRaQueue[*route.BgpRoute](),
+		local:           make(map[netip.Prefix]*route.BgpRoute),
+		injected:        make(map[netip.Prefix]*route.BgpRoute),
+		aggregated:      make(map[netip.Prefix]*route.BgpRoute),
+		connected:       make(map[uint32]rib.Route),
+		adjRibPre:       make(map[string]adjRib),
+		adjRibIn:        make(map[string]adjRib),
+		adjRibOut:       make(map[string]map[netip.Prefix]*route.BgpRoute),
+		closing:         make(map[string]struct{}),
+		feeds:           make(map[string]uint32),
+		locRib:          make(map[netip.Prefix]*rset.RouteSet),
+		locIndex:        &bart.Table[struct{}]{},
+		installed:       make(map[netip.Prefix][]rib.Route),
+		rib:             rib.MakeRib(),
+		nht:             newNhTracker(),
+		dirty:           make(map[netip.Prefix]struct{}),
+		reimport:        make(map[string]struct{}),
+		flaps:           make(map[flapKey]flapState),
+		suppressed:      make(map[flapKey]struct{}),
+		prefixLevels:    make(map[string]prefixLevel),
+		shutdown:        make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Options
+
+func WithRouterID(routerID netip.Addr) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.routerID = routerID
+	}
+}
+
+func WithMultipath(m bool) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.multipath = m
+	}
+}
+
+// WithDeterministic breaks best path ties by neighbor instead of route age.
+// Reconverging after a change then yields the same result as a from-scratch run.
+func WithDeterministic(d bool) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.deterministic = d
+	}
+}
+
+// WithLeaker makes the node export routes from providers and peers to other providers and peers,
+// skipping the valley-free clauses of its export policies
+func WithLeaker(l bool) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.leaker = l
+	}
+}
+
+// WithExternal makes the node stand in for a speaker outside the simulation, such as a real router.
+// External nodes are never stepped: what they send is injected with Inject, what they receive is
+// collected with Drain.
+func WithExternal(e bool) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.external = e
+	}
+}
+
+// WithDampening enables route flap dampening of routes received over eBGP sessions and feeds
+func WithDampening(d Dampening) func(*BgpNode) {
+	return func(n *BgpNode) {
+		n.dampening = d
+	}
+}
+
+// Getters
+
+func (n *BgpNode) Name() string {
+	return n.name
+}
+
+func (n *BgpNode) Asn() uint32 {
+	return n.asn
+}
+
+func (n *BgpNode) RouterID() netip.Addr {
+	return n.routerID
+}
+
+func (n *BgpNode) Rib() *rib.Rib {
+	return n.rib
+}
+
+// LocRib returns the selected routes for a prefix
+func (n *BgpNode) LocRib(prefix netip.Prefix) (*rset.RouteSet, bool) {
+	rs, ok := n.locRib[prefix]
+	return rs, ok
+}
+
+// LocRibPrefixes returns all prefixes with selected routes, in sorted order
+func (n *BgpNode) LocRibPrefixes() []netip.Prefix {
+	return slices.SortedFunc(maps.Keys(n.locRib), comparePrefix)
+}
+
+// Routes returns all candidate routes for a prefix.
+// Routes whose next hop does not resolve are marked with a nexthop.Invalid next hop.
+func (n *BgpNode) Routes(prefix netip.Prefix) []*route.BgpRoute {
+	candidates := n.candidates(prefix)
+	for i, r := range candidates {
+		nh := r.NextHop()
+		if !n.needsResolution(r) || n.nht.resolved(nh.IP(), prefix) {
+			continue
+		}
+		marked := r.Clone(route.WithNextHop(nexthop.New(
+			nexthop.WithIP(nh.IP()),
+			nexthop.WithType(nexthop.Invalid),
+		)))
+		marked.SetArrival(r.Arrival())
+		candidates[i] = marked
+	}
+	return candidates
+}
+
+// AdjRibIn returns the routes received from a peer of the node, after import policy unless pre is set.
+// Routes are sorted by prefix and path ID.
+func (n *BgpNode) AdjRibIn(peer BgpPeer, pre bool) []*route.BgpRoute {
+	in := n.adjRibIn[peer.remoteKey()]
+	if pre {
+		in = n.adjRibPre[peer.remoteKey()]
+	}
+	var routes []*route.BgpRoute
+	for _, prefix := range slices.SortedFunc(maps.Keys(in), comparePrefix) {
+		for _, id := range slices.Sorted(maps.Keys(in[prefix])) {
+			routes = append(routes, in[prefix][id])
+		}
+	}
+	return routes
+}
+
+func (n *BgpNode) Deterministic() bool {
+	return n.deterministic
+}
+
+func (n *BgpNode) Leaker() bool {
+	return n.leaker
+}
+
+// SetLeaker changes whether the node leaks routes, re-exporting its whole Loc-RIB
+func (n *BgpNode) SetLeaker(l bool) {
+	if n.leaker == l {
+		return
+	}
+	n.leaker = l
+	n.resend = true
+}
+
+// External reports whether the node stands in for a speaker outside the simulation
+func (n *BgpNode) External() bool {
+	return n.external
+}
+
+// Session policies
+
+// SetImportPolicy sets the route map applied to routes received on a local interface, nil permits all.
+// Routes already received are imported again.
+func (n *BgpNode) SetImportPolicy(iface string, rm *policy.RouteMap) {
+	if rm == nil {
+		delete(n.imports, iface)
+	} else {
+		n.imports[iface] = rm
+	}
+	n.reimport[iface] = struct{}{}
+}
+
+// SetExportPolicy sets the route map applied to routes advertised on a local interface, nil permits all.
+// The whole Loc-RIB is exported again.
+func (n *BgpNode) SetExportPolicy(iface string, rm *policy.RouteMap) {
+	if rm == nil {
+		delete(n.exports, iface)
+	} else {
+		n.exports[iface] = rm
+	}
+	n.resend = true
+}
+
+// Local route injection
+
+// Originate injects a locally originated BGP route
+func (n *BgpNode) Originate(r *route.BgpRoute) {
+	n.local[r.Prefix()] = r.Clone(
+		route.WithReceivedFrom(route.NewRxFrom(route.WithLocal())),
+		route.WithWeight(localWeight),
+	)
+	n.dirty[r.Prefix()] = struct{}{}
+}
+
+// WithdrawOrigin removes a locally originated BGP route
+func (n *BgpNode) WithdrawOrigin(prefix netip.Prefix) {
+	if _, ok := n.local[prefix]; !ok {
+		return
+	}
+	delete(n.local, prefix)
+	n.dirty[prefix] = struct{}{}
+}
+
+// AddRoute adds a non-BGP route to the node's RIB
+func (n *BgpNode) AddRoute(r rib.Route) {
+	if n.rib.AddRoute(r) {
+		n.ribChanged = true
+		n.originsChanged = true
+	}
+}
+
+// RemoveRoute removes a non-BGP route from the node's RIB
+func (n *BgpNode) RemoveRoute(r rib.Route) {
+	if n.rib.RemoveRoute(r) {
+		n.ribChanged = true
+		n.originsChanged = true
+	}
+}
+
+// Processing
+
+// step drains pending advertisements, reruns best path selection and exports the changes.
+// Returns true if any work was done.
+func (n *BgpNode) step(sim *Simulation) bool {
+	peers := n.established(sim.topology.GetPeers(n.name))
+	now := sim.Now()
+	worked := n.reuse(now)
+	for _, key := range slices.Sorted(maps.Keys(n.closing)) {
+		worked = true
+		n.closeSession(key)
+	}
+	sessions := make([]session, 0, len(peers)+len(n.feeds))
+	for i := range peers {
+		sessions = append(sessions, peers[i].session())
+	}
+	for _, name := range slices.Sorted(maps.Keys(n.feeds)) {
+		sessions = append(sessions, n.feedSession(name))
+	}
+	for _, s := range sessions {
+		if _, ok := n.reimport[s.iface]; ok {
+			worked = true
+			n.reapplyImport(s)
+		}
+		for _, adv := range n.queue.PopAll(s.key) {
+			worked = true
+			if n.penalize(s, adv, now) {
+				sim.stats.Suppressions++
+			}
+			n.receive(s, adv, sim.tick())
+		}
+	}
+	clear(n.reimport)
+	n.checkMaxPrefix(sim, peers)
+
+	if len(n.dirty) == 0 && !n.ribChanged && !n.originsChanged && !n.resend {
+		return worked
+	}
+
+	changed := n.converge()
+	n.evaluateConditions(changed)
+	if n.resend {
+		n.resend = false
+		for p := range n.locRib {
+			changed[p] = struct{}{}
+		}
+		// Originated defaults are not in the Loc-RIB
+		changed[defaultPrefix(true)] = struct{}{}
+		changed[defaultPrefix(false)] = struct{}{}
+	}
+	n.export(sim, peers, changed)
+	return true
+}
+
+// receive applies an advertisement to the Adj-RIB-In of a session
+func (n *BgpNode) receive(s session, adv ra.RouteAdv[*route.BgpRoute], arrival int64) {
+	pre, ok := n.adjRibPre[s.key]
+	if !ok {
+		pre = make(adjRib)
+		n.adjRibPre[s.key] = pre
+	}
+
+	r := adv.Route
+	n.dirty[r.Prefix()] = struct{}{}
+
+	// Treat looped routes as withdrawals
+	if adv.Action == ra.Remove || r.InPath(n.asn) {
+		pre.remove(r)
+		n.adjRibIn[s.key].remove(r)
+		return
+	}
+
+	imported := received(s, r)
+	imported.SetArrival(arrival)
+	pre.add(imported)
+	n.importRoute(s, imported)
+}
+
+// received returns a route as stored in the Adj-RIB-Pre of the session it was received over
+func received(s session, r *route.BgpRoute) *route.BgpRoute {
+	opts := []func(*route.BgpRoute){
+		route.WithReceivedFrom(s.rx),
+		route.WithWeight(0),
+	}
+	if s.ibgp {
+		opts = append(opts, route.WithAdminCost(ibgpAdminCost))
+	} else {
+		opts = append(opts,
+			route.WithAdminCost(ebgpAdminCost),
+			route.WithLocalPreference(defaultLocalPreference),
+		)
+	}
+	return r.Clone(opts...)
+}
+
+// importRoute applies the import policy of a session to a received route
+func (n *BgpNode) importRoute(s session, r *route.BgpRoute) {
+	in, ok := n.adjRibIn[s.key]
+	if !ok {
+		in = make(adjRib)
+		n.adjRibIn[s.key] = in
+	}
+	if accepted, ok := n.imports[s.iface].Apply(r); ok {
+		in.add(accepted)
+	} else {
+		in.remove(r)
+	}
+}
+
+// reapplyImport imports every route received on a session again
+func (n *BgpNode) reapplyImport(s session) {
+	for prefix := range n.adjRibIn[s.key] {
+		n.dirty[prefix] = struct{}{}
+	}
+	delete(n.adjRibIn, s.key)
+	for prefix, paths := range n.adjRibPre[s.key] {
+		n.dirty[prefix] = struct{}{}
+		for _, r := range paths {
+			n.importRoute(s, r)
+		}
+	}
+}
+
+// converge reruns selection for dirty prefixes until next hop resolution settles.
+// Returns the prefixes whose selected routes changed.
+func (n *BgpNode) converge() map[netip.Prefix]struct{} {
+	changed := make(map[netip.Prefix]struct{})
+	for i := 0; i < maxConvergeIterations && (len(n.dirty) != 0 || n.ribChanged || n.originsChanged); i++ {
+		if n.originsChanged {
+			n.originsChanged = false
+			n.refreshInjected()
+			n.refreshAggregates(nil)
+		}
+		if n.ribChanged {
+			n.ribChanged = false
+			for _, p := range n.nht.refresh(n.rib) {
+				n.dirty[p] = struct{}{}
+			}
+		}
+
+		dirty := n.dirty
+		n.dirty = make(map[netip.Prefix]struct{})
+		pass := make(map[netip.Prefix]struct{})
+		for _, p := range slices.SortedFunc(maps.Keys(dirty), comparePrefix) {
+			if n.reselect(p) {
+				changed[p] = struct{}{}
+				pass[p] = struct{}{}
+			}
+		}
+		if len(n.aggregates) != 0 && len(pass) != 0 {
+			n.refreshAggregates(pass)
+		}
+	}
+	return changed
+}
+
+// candidates returns the locally originated and received routes for a prefix
+func (n *BgpNode) candidates(prefix netip.Prefix) []*route.BgpRoute {
+	var candidates []*route.BgpRoute
+	if r, ok := n.local[prefix]; ok {
+		candidates = append(candidates, r)
+	}
+	if r, ok := n.injected[prefix]; ok {
+		candidates = append(candidates, r)
+	}
+	if r, ok := n.aggregated[prefix]; ok {
+		candidates = append(candidates, r)
+	}
+	for _, key := range slices.Sorted(maps.Keys(n.adjRibIn)) {
+		paths := n.adjRibIn[key][prefix]
+		for _, id := range slices.Sorted(maps.Keys(paths)) {
+			candidates = append(candidates, paths[id])
+		}
+	}
+	return candidates
+}
+
+// needsResolution reports whether a route's next hop must be resolved through the RIB.
+// Locally originated and fed routes are not resolved.
+func (n *BgpNode) needsResolution(r *route.BgpRoute) bool {
+	rx := r.ReceivedFrom()
+	nh := r.NextHop()
+	return rx.Type() == route.IP && nh.Type() == nexthop.IP
+}
+
+// reselect runs best path selection for a prefix, updating the Loc-RIB and RIB.
+// Returns true if the selected routes changed.
+func (n *BgpNode) reselect(prefix netip.Prefix) bool {
+	candidates := n.candidates(prefix)
+
+	var ips []netip.Addr
+	for _, r := range candidates {
+		if n.needsResolution(r) {
+			nh := r.NextHop()
+			ips = append(ips, nh.IP())
+		}
+	}
+	n.nht.track(prefix, ips, n.rib)
+
+	damped := n.dampedRoutes(prefix)
+	eligible := make([]*route.BgpRoute, 0, len(candidates))
+	for _, r := range candidates {
+		if _, ok := damped[r]; ok {
+			continue
+		}
+		nh := r.NextHop()
+		if nh.Type() == nexthop.Invalid {
+			continue
+		}
+		if n.needsResolution(r) && !n.nht.resolved(nh.IP(), prefix) {
+			continue
+		}
+		eligible = append(eligible, r)
+	}
+
+	old, had := n.locRib[prefix]
+	if len(eligible) == 0 {
+		if !had {
+			return false
+		}
+		delete(n.locRib, prefix)
+		n.locIndex.Delete(prefix)
+		n.install(prefix, nil)
+		return true
+	}
+
+	rs := rset.New(eligible, rset.WithMultipath(n.multipath), rset.WithDeterministic(n.deterministic))
+	if had && old.Eq(rs) {
+		return false
+	}
+	n.locRib[prefix] = rs
+	n.locIndex.Insert(prefix, struct{}{})
+	n.install(prefix, rs)
+	return true
+}
+
+// install replaces the BGP routes in the RIB for a prefix with the selected routes of rs
+func (n *BgpNode) install(prefix netip.Prefix, rs *rset.RouteSet) {
+	for _, r := range n.installed[prefix] {
+		if n.rib.RemoveRoute(r) {
+			n.ribChanged = true
+		}
+	}
+	delete(n.installed, prefix)
+
+	if rs == nil {
+		return
+	}
+
+	var installed []rib.Route
+	for _, br := range selected(rs) {
+		if rx := br.ReceivedFrom(); rx.Type() == route.Local {
+			continue
+		}
+		r := rib.Route{
+			Prefix:    prefix,
+			NextHop:   br.NextHop(),
+			Protocol:  rib.BGP,
+			AdminCost: br.AdminCost(),
+			Metric:    uint64(br.Metric()),
+		}
+		if n.rib.AddRoute(r) {
+			n.ribChanged = true
+		}
+		installed = append(installed, r)
+	}
+	if len(installed) != 0 {
+		n.installed[prefix] = installed
+	}
+}
+
+// export advertises the selected routes of changed prefixes to all peers
+func (n *BgpNode) export(sim *Simulation, peers []BgpPeer, changed map[netip.Prefix]struct{}) {
+	txs := make(map[*BgpNode]*ra.Tx[*route.BgpRoute])
+	for _, prefix := range slices.SortedFunc(maps.Keys(changed), comparePrefix) {
+		var best *route.BgpRoute
+		if rs, ok := n.locRib[prefix]; ok {
+			best = rs.BestPath()
+		}
+		for i := range peers {
+			tx, ok := txs[peers[i].RemoteNode]
+			if !ok {
+				tx = peers[i].RemoteNode.queue.BeginTx()
+				txs[peers[i].RemoteNode] = tx
+			}
+			n.advertise(sim, &peers[i], prefix, best, tx)
+		}
+	}
+	for _, tx := range txs {
+		tx.Commit()
+	}
+}
+
+// advertise updates the Adj-RIB-Out of a session for a prefix, pushing the difference to tx
+func (n *BgpNode) advertise(sim *Simulation, peer *BgpPeer, prefix netip.Prefix, best *route.BgpRoute, tx *ra.Tx[*route.BgpRoute]) {
+	key := peer.remoteKey()
+	out, ok := n.adjRibOut[key]
+	if !ok {
+		out = make(map[netip.Prefix]*route.BgpRoute)
+		n.adjRibOut[key] = out
+	}
+	prev, had := out[prefix]
+
+	adv, ok := n.originatedDefault(peer, prefix)
+	if !ok {
+		adv, ok = n.exportRoute(peer, best)
+	}
+	if !ok {
+		if had {
+			delete(out, prefix)
+			withdrawal := ra.RouteAdv[*route.BgpRoute]{Route: prev, Action: ra.Remove}
+			sim.send(peer, withdrawal, tx)
+		}
+		return
+	}
+
+	if had && prev.Hash() == adv.Hash() {
+		return
+	}
+	out[prefix] = adv
+	update := ra.RouteAdv[*route.BgpRoute]{Route: adv, Action: ra.Add}
+	sim.send(peer, update, tx)
+}
+
+// exportRoute builds the route advertised to a peer, or returns false if nothing should be advertised
+func (n *BgpNode) exportRoute(peer *BgpPeer, best *route.BgpRoute) (*route.BgpRoute, bool) {
+	if best == nil || n.summarized(best.Prefix()) || n.withheld(peer, best) {
+		return nil, false
+	}
+
+	// Export policy, leakers skip its valley-free clauses
+	apply := n.exports[peer.LocalIface].Apply
+	if n.leaker {
+		apply = n.exports[peer.LocalIface].ApplyLeaking
+	}
+	best, ok := apply(best)
+	if !ok {
+		return nil, false
+	}
+
+	rx := best.ReceivedFrom()
+	if rx.Type() == route.IP {
+		// Split horizon
+		if rx.LinkLocalIP() == peer.RemotePrefix.Addr() {
+			return nil, false
+		}
+		// No iBGP to iBGP readvertisement
+		if peer.ibgp() && best.AdminCost() == ibgpAdminCost {
+			return nil, false
+		}
+	}
+
+	// Sender side loop prevention
+	if !peer.ibgp() && best.InPath(peer.RemoteNode.asn) {
+		return nil, false
+	}
+
+	opts := []func(*route.BgpRoute){
+		route.WithNextHop(nexthop.New(nexthop.WithIP(peer.LocalPrefix.Addr()))),
+		route.WithReceivedFrom(route.NewRxFrom()),
+		route.WithAdminCost(0),
+		route.WithWeight(0),
+		route.WithPathID(0),
+	}
+	if !peer.ibgp() {
+		asPath := append([]uint32{n.asn}, best.AsPath()...)
+		opts = append(opts,
+			route.WithAsPath(asPath),
+			route.WithLocalPreference(0),
+			route.WithTag(0), // Tags stay within the AS
+		)
+		// MED is not passed between neighboring ASes
+		if rx.Type() != route.Local {
+			opts = append(opts, route.WithMetric(0))
+		}
+	}
+	return best.Clone(opts...), true
+}
+
+// selected returns the routes used for forwarding, ordered by hash
+func selected(rs *rset.RouteSet) []*route.BgpRoute {
+	routes := rs.MultipathSet()
+	if len(routes) == 0 {
+		return []*route.BgpRoute{rs.BestPath()}
+	}
+	slices.SortFunc(routes, func(a, b *route.BgpRoute) int {
+		return cmp.Compare(a.Hash(), b.Hash())
+	})
+	return routes
+}
+
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Bits(), b.Bits())
+}
@@ -0,0 +1,118 @@
+package bgp
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+// Helper to build a connected route for one side of a link
+func connected(prefix string, iface string) rib.Route {
+	return rib.Route{
+		Prefix:   netip.MustParsePrefix(prefix).Masked(),
+		NextHop:  nexthop.New(nexthop.WithInterface(iface)),
+		Protocol: rib.Connected,
+	}
+}
+
+// Chain a - b - c
+func makeChain() (*BgpTopology, *BgpNode, *BgpNode, *BgpNode) {
+	a := NewBgpNode("a", 65001)
+	b := NewBgpNode("b", 65002)
+	c := NewBgpNode("c", 65003)
+
+	builder := &BgpTopologyBuilder{}
+	builder.AddPeer(BgpPeer{
+		LocalNode:    a,
+		RemoteNode:   b,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	})
+	builder.AddPeer(BgpPeer{
+		LocalNode:    b,
+		RemoteNode:   c,
+		LocalPrefix:  netip.MustParsePrefix("10.0.1.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.1.2/30"),
+		LocalIface:   "eth1",
+		RemoteIface:  "eth0",
+	})
+
+	return builder.Build(), a, b, c
+}
+
+func TestSimulation_Propagation(t *testing.T) {
+	topo, a, _, c := makeChain()
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	a.Originate(route.New(route.WithPrefix(prefix)))
+
+	if err := NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	rs, ok := c.LocRib(prefix)
+	if !ok {
+		t.Fatal("Expected c to select a route")
+	}
+	if !slices.Equal(rs.BestPath().AsPath(), []uint32{65002, 65001}) {
+		t.Errorf("Expected AS path [65002 65001], got %v", rs.BestPath().AsPath())
+	}
+
+	nhs, err := c.Rib().Resolve(netip.MustParseAddr("203.0.113.7"))
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if len(nhs) != 1 || nhs[0].Iface() != "eth0" {
+		t.Errorf("Expected resolution to eth0, got %v", nhs)
+	}
+}
+
+func TestSimulation_NextHopTracking(t *testing.T) {
+	topo, a, _, c := makeChain()
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	a.Originate(route.New(route.WithPrefix(prefix)))
+
+	sim := NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	// Next hop disappears
+	c.RemoveRoute(connected("10.0.1.2/30", "eth0"))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if _, ok := c.LocRib(prefix); ok {
+		t.Error("Expected route with unresolvable next hop to be deselected")
+	}
+	routes := c.Routes(prefix)
+	if len(routes) != 1 {
+		t.Fatalf("Expected 1 candidate route, got %d", len(routes))
+	}
+	if nh := routes[0].NextHop(); nh.Type() != nexthop.Invalid {
+		t.Errorf("Expected next hop to be marked invalid, got type %d", nh.Type())
+	}
+	if len(c.Rib().LongestPrefixMatch(netip.MustParseAddr("203.0.113.7"))) != 0 {
+		t.Error("Expected BGP route to be removed from the RIB")
+	}
+
+	// Next hop reappears through a static route
+	c.AddRoute(rib.Route{
+		Prefix:    netip.MustParsePrefix("10.0.1.0/24"),
+		NextHop:   nexthop.New(nexthop.WithInterface("eth0")),
+		Protocol:  rib.Static,
+		AdminCost: 1,
+	})
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(prefix); !ok {
+		t.Error("Expected route to be selected again once the next hop resolves")
+	}
+}
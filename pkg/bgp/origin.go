@@ -0,0 +1,177 @@
+package bgp
+
+import (
+	"cmp"
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+const (
+	staticAdminCost = 1
+)
+
+// StaticRoute configures a static route to an IP, an interface or nexthop.Discard
+type StaticRoute struct {
+	Prefix    netip.Prefix
+	NextHop   nexthop.NextHop
+	AdminCost optional.Optional[int] // Defaults to 1
+	Metric    uint64
+}
+
+func (sr StaticRoute) ribRoute() rib.Route {
+	return rib.Route{
+		Prefix:    sr.Prefix.Masked(),
+		NextHop:   sr.NextHop,
+		Protocol:  rib.Static,
+		AdminCost: sr.AdminCost.OrElse(staticAdminCost),
+		Metric:    sr.Metric,
+	}
+}
+
+// Network originates a prefix into BGP while the RIB has a non-BGP route for exactly that prefix
+type Network struct {
+	Prefix netip.Prefix
+	Origin route.Origin
+	Metric optional.Optional[int] // Defaults to the RIB route metric
+	Policy *policy.RouteMap
+}
+
+// Redistribution injects every active RIB route of a protocol other than BGP into BGP
+type Redistribution struct {
+	Protocol rib.Protocol
+	Origin   route.Origin           // The zero value is route.IGP, redistributed routes are usually route.Incomplete
+	Metric   optional.Optional[int] // Defaults to the RIB route metric
+	Policy   *policy.RouteMap
+}
+
+// AddStaticRoute installs a static route in the node's RIB
+func (n *BgpNode) AddStaticRoute(sr StaticRoute) {
+	n.AddRoute(sr.ribRoute())
+}
+
+// RemoveStaticRoute removes a static route from the node's RIB
+func (n *BgpNode) RemoveStaticRoute(sr StaticRoute) {
+	n.RemoveRoute(sr.ribRoute())
+}
+
+// AddNetwork configures a network statement, replacing any for the same prefix
+func (n *BgpNode) AddNetwork(nw Network) {
+	nw.Prefix = nw.Prefix.Masked()
+	n.networks[nw.Prefix] = nw
+	n.originsChanged = true
+}
+
+// RemoveNetwork removes the network statement for a prefix
+func (n *BgpNode) RemoveNetwork(prefix netip.Prefix) {
+	delete(n.networks, prefix.Masked())
+	n.originsChanged = true
+}
+
+// AddRedistribution configures redistribution of a protocol, replacing any for the same protocol.
+// Returns false for rib.BGP: injected routes would displace the learned routes they come from.
+func (n *BgpNode) AddRedistribution(rd Redistribution) bool {
+	if rd.Protocol == rib.BGP {
+		return false
+	}
+	n.redistributions[rd.Protocol] = rd
+	n.originsChanged = true
+	return true
+}
+
+// RemoveRedistribution stops redistribution of a protocol
+func (n *BgpNode) RemoveRedistribution(protocol rib.Protocol) {
+	delete(n.redistributions, protocol)
+	n.originsChanged = true
+}
+
+// syncConnected installs a connected route for the local prefix of every peer,
+// removing those of peers that no longer exist
+func (n *BgpNode) syncConnected(peers []BgpPeer) {
+	desired := make(map[uint32]rib.Route)
+	for _, peer := range peers {
+		r := rib.Route{
+			Prefix:   peer.LocalPrefix.Masked(),
+			NextHop:  nexthop.New(nexthop.WithInterface(peer.LocalIface)),
+			Protocol: rib.Connected,
+		}
+		desired[r.Hash()] = r
+	}
+
+	for h, r := range n.connected {
+		if _, ok := desired[h]; !ok {
+			n.RemoveRoute(r)
+		}
+	}
+	for h, r := range desired {
+		if _, ok := n.connected[h]; !ok {
+			n.AddRoute(r)
+		}
+	}
+	n.connected = desired
+}
+
+// refreshInjected recomputes the routes injected into BGP by network and redistribute statements.
+// Prefixes whose injected route changed are marked dirty.
+func (n *BgpNode) refreshInjected() {
+	desired := make(map[netip.Prefix]*route.BgpRoute)
+
+	protocols := slices.SortedFunc(maps.Keys(n.redistributions), cmp.Compare[rib.Protocol])
+	for _, p := range protocols {
+		rd := n.redistributions[p]
+		for _, r := range n.rib.GetActiveRoutes() {
+			if r.Protocol != rd.Protocol {
+				continue
+			}
+			if injected, ok := inject(r, rd.Origin, rd.Metric, rd.Policy); ok {
+				desired[r.Prefix] = injected
+			}
+		}
+	}
+
+	// Network statements take precedence over redistribution
+	for prefix, nw := range n.networks {
+		for _, r := range n.rib.GetActiveRoutesForPrefix(prefix) {
+			if r.Protocol == rib.BGP {
+				continue
+			}
+			if injected, ok := inject(r, nw.Origin, nw.Metric, nw.Policy); ok {
+				desired[prefix] = injected
+			}
+			break
+		}
+	}
+
+	for prefix := range n.injected {
+		if _, ok := desired[prefix]; !ok {
+			n.dirty[prefix] = struct{}{}
+		}
+	}
+	for prefix, d := range desired {
+		if r, ok := n.injected[prefix]; ok && r.Hash() == d.Hash() {
+			desired[prefix] = r // Keep the original arrival
+			continue
+		}
+		n.dirty[prefix] = struct{}{}
+	}
+	n.injected = desired
+}
+
+// inject builds a locally originated BGP route from a RIB route
+func inject(r rib.Route, origin route.Origin, metric optional.Optional[int], rm *policy.RouteMap) (*route.BgpRoute, bool) {
+	br := route.New(
+		route.WithPrefix(r.Prefix),
+		route.WithNextHop(r.NextHop),
+		route.WithOrigin(origin),
+		route.WithMetric(metric.OrElse(int(r.Metric))),
+		route.WithReceivedFrom(route.NewRxFrom(route.WithLocal())),
+		route.WithWeight(localWeight),
+	)
+	return rm.Apply(br)
+}
@@ -0,0 +1,80 @@
+package bgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+func TestOrigin_NetworkAndRedistribute(t *testing.T) {
+	topo, a, _, c := makeChain()
+	network := netip.MustParsePrefix("192.0.2.0/24")
+	denied := netip.MustParsePrefix("198.51.100.0/24")
+
+	sr := StaticRoute{
+		Prefix:  network,
+		NextHop: nexthop.New(nexthop.WithDiscard()),
+	}
+	a.AddStaticRoute(sr)
+	a.AddStaticRoute(StaticRoute{
+		Prefix:  denied,
+		NextHop: nexthop.New(nexthop.WithDiscard()),
+	})
+	a.AddNetwork(Network{
+		Prefix: network,
+		Origin: route.IGP,
+		Metric: optional.Of(50),
+	})
+	a.AddRedistribution(Redistribution{
+		Protocol: rib.Connected,
+		Origin:   route.Incomplete,
+	})
+	if a.AddRedistribution(Redistribution{Protocol: rib.BGP}) {
+		t.Error("Expected redistribution of BGP to be rejected")
+	}
+	a.AddRedistribution(Redistribution{
+		Protocol: rib.Static,
+		Origin:   route.Incomplete,
+		Policy: policy.New(
+			policy.Clause{Match: []policy.Match{policy.MatchPrefix(denied)}, Action: policy.Deny},
+			policy.Clause{Match: []policy.Match{policy.MatchAny()}, Action: policy.Permit},
+		),
+	})
+
+	sim := NewSimulation(topo)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	rs, ok := c.LocRib(network)
+	if !ok {
+		t.Fatal("Expected c to learn the network statement prefix")
+	}
+	if rs.BestPath().Origin() != route.IGP {
+		t.Errorf("Expected network statement to take precedence with ORIGIN IGP, got %d", rs.BestPath().Origin())
+	}
+	if _, ok := c.LocRib(denied); ok {
+		t.Error("Expected redistribution policy to deny 198.51.100.0/24")
+	}
+	rs, ok = c.LocRib(netip.MustParsePrefix("10.0.0.0/30"))
+	if !ok {
+		t.Fatal("Expected c to learn a's redistributed connected prefix")
+	}
+	if rs.BestPath().Origin() != route.Incomplete {
+		t.Errorf("Expected ORIGIN incomplete, got %d", rs.BestPath().Origin())
+	}
+
+	// Removing the static route withdraws the network statement route
+	a.RemoveStaticRoute(sr)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, ok := c.LocRib(network); ok {
+		t.Error("Expected network statement route to be withdrawn")
+	}
+}
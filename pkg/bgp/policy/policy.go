@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+type Action int
+
+const (
+	Permit Action = iota
+	Deny
+)
+
+// Match reports whether a route satisfies a condition
+type Match func(*route.BgpRoute) bool
+
+// Clause is a single route-map entry.
+// All matches must succeed for the clause to apply.
+type Clause struct {
+	Match      []Match
+	Set        []func(*route.BgpRoute) // Route options applied on permit
+	Action     Action
+	ValleyFree bool // Enforces the valley-free export rule, skipped by ApplyLeaking
+}
+
+// RouteMap evaluates clauses in order, the first matching clause decides.
+// Routes matching no clause are denied.
+type RouteMap struct {
+	clauses []Clause
+}
+
+// Create new RouteMap
+func New(clauses ...Clause) *RouteMap {
+	return &RouteMap{
+		clauses: clauses,
+	}
+}
+
+// Apply evaluates the route map against a route.
+// Returns the possibly modified route and whether it is permitted.
+// A nil RouteMap permits every route unchanged.
+func (rm *RouteMap) Apply(r *route.BgpRoute) (*route.BgpRoute, bool) {
+	return rm.apply(r, false)
+}
+
+// ApplyLeaking evaluates the route map like Apply, skipping the clauses enforcing the valley-free rule.
+// It models a route leak: the operator policy still applies, the relationship filtering does not.
+func (rm *RouteMap) ApplyLeaking(r *route.BgpRoute) (*route.BgpRoute, bool) {
+	return rm.apply(r, true)
+}
+
+func (rm *RouteMap) apply(r *route.BgpRoute, leaking bool) (*route.BgpRoute, bool) {
+	if rm == nil {
+		return r, true
+	}
+
+	for _, c := range rm.clauses {
+		if (leaking && c.ValleyFree) || !matchAll(c.Match, r) {
+			continue
+		}
+		if c.Action == Deny {
+			return nil, false
+		}
+		if len(c.Set) == 0 {
+			return r, true
+		}
+		set := r.Clone(c.Set...)
+		set.SetArrival(r.Arrival())
+		return set, true
+	}
+	return nil, false
+}
+
+// Permits reports whether the route map permits a route, without applying set actions.
+// A nil RouteMap permits every route.
+func (rm *RouteMap) Permits(r *route.BgpRoute) bool {
+	if rm == nil {
+		return true
+	}
+	for _, c := range rm.clauses {
+		if matchAll(c.Match, r) {
+			return c.Action == Permit
+		}
+	}
+	return false
+}
+
+func matchAll(matches []Match, r *route.BgpRoute) bool {
+	for _, m := range matches {
+		if !m(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match conditions
+
+// MatchAny always matches
+func MatchAny() Match {
+	return func(*route.BgpRoute) bool {
+		return true
+	}
+}
+
+// MatchPrefix matches routes for exactly one of the given prefixes
+func MatchPrefix(prefixes ...netip.Prefix) Match {
+	return func(r *route.BgpRoute) bool {
+		return slices.Contains(prefixes, r.Prefix())
+	}
+}
+
+// MatchPrefixRange matches routes within prefix with a length between ge and le inclusive
+func MatchPrefixRange(prefix netip.Prefix, ge, le int) Match {
+	return func(r *route.BgpRoute) bool {
+		p := r.Prefix()
+		return prefix.Overlaps(p) && p.Bits() >= prefix.Bits() && p.Bits() >= ge && p.Bits() <= le
+	}
+}
+
+// MatchAsPathContains matches routes whose AS path or AS_SET contains asn
+func MatchAsPathContains(asn uint32) Match {
+	return func(r *route.BgpRoute) bool {
+		return r.InPath(asn)
+	}
+}
+
+// MatchOrigin matches routes with the given ORIGIN
+func MatchOrigin(origin route.Origin) Match {
+	return func(r *route.BgpRoute) bool {
+		return r.Origin() == origin
+	}
+}
+
+// MatchTag matches routes carrying one of the given tags
+func MatchTag(tags ...int) Match {
+	return func(r *route.BgpRoute) bool {
+		return slices.Contains(tags, r.Tag())
+	}
+}
+
+// MatchNot negates a condition
+func MatchNot(m Match) Match {
+	return func(r *route.BgpRoute) bool {
+		return !m(r)
+	}
+}
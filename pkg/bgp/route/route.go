@@ -5,11 +5,38 @@ import (
 	"encoding/binary"
 	"hash/fnv"
 	"net/netip"
+	"slices"
 	"time"
 
 	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
 )
 
+type Origin int
+
+const (
+	IGP Origin = iota
+	EGP
+	Incomplete
+)
+
+func (o Origin) String() string {
+	switch o {
+	case IGP:
+		return "igp"
+	case EGP:
+		return "egp"
+	case Incomplete:
+		return "incomplete"
+	}
+	return "unknown"
+}
+
+// Aggregator is the AS and router ID of the speaker that formed an aggregate route
+type Aggregator struct {
+	Asn      uint32
+	RouterID netip.Addr
+}
+
 // BgpRoute represents a BGP route
 // Inmutable once created
 type BgpRoute struct {
@@ -22,6 +49,7 @@ type BgpRoute struct {
 	nextHop         nexthop.NextHop
 	nonForwarding   bool
 	nonRouting      bool
+	origin          Origin
 	pathID          int
 	prefix          netip.Prefix
 	receivedFrom    RxFrom
@@ -29,6 +57,11 @@ type BgpRoute struct {
 	tag             int
 	weight          int
 
+	// Aggregation, hashed when set
+	asSet           []uint32 // AS_SET segment following asPath
+	atomicAggregate bool
+	aggregator      Aggregator
+
 	// Not hashed fields
 	hash    uint32
 	arrival int64 // Unix ns timestamp, implicitly set on creation
@@ -56,12 +89,16 @@ func (r *BgpRoute) Clone(opts ...func(*BgpRoute)) *BgpRoute {
 		nextHop:         r.nextHop,
 		nonForwarding:   r.nonForwarding,
 		nonRouting:      r.nonRouting,
+		origin:          r.origin,
 		pathID:          r.pathID,
 		prefix:          r.prefix,
 		receivedFrom:    r.receivedFrom,
 		srcPrefixLength: r.srcPrefixLength,
 		tag:             r.tag,
 		weight:          r.weight,
+		asSet:           slices.Clone(r.asSet),
+		atomicAggregate: r.atomicAggregate,
+		aggregator:      r.aggregator,
 		arrival:         time.Now().UnixNano(),
 	}
 
@@ -81,21 +118,29 @@ func (r *BgpRoute) Clone(opts ...func(*BgpRoute)) *BgpRoute {
 func (r *BgpRoute) computeHash() {
 	h := fnv.New32a()
 
-	binary.Write(h, binary.BigEndian, r.adminCost)
+	binary.Write(h, binary.BigEndian, int64(r.adminCost))
 	binary.Write(h, binary.BigEndian, r.asPath)
-	binary.Write(h, binary.BigEndian, r.bgpAdminCost)
+	binary.Write(h, binary.BigEndian, int64(r.bgpAdminCost))
 	binary.Write(h, binary.BigEndian, r.localPreference)
-	binary.Write(h, binary.BigEndian, r.metric)
+	binary.Write(h, binary.BigEndian, int64(r.metric))
 	binary.Write(h, binary.BigEndian, r.nextHop.Hash())
 	binary.Write(h, binary.BigEndian, r.nonForwarding)
 	binary.Write(h, binary.BigEndian, r.nonRouting)
-	binary.Write(h, binary.BigEndian, r.pathID)
+	binary.Write(h, binary.BigEndian, int64(r.origin))
+	binary.Write(h, binary.BigEndian, int64(r.pathID))
 	prefixBytes, _ := r.prefix.MarshalBinary()
 	h.Write(prefixBytes)
 	binary.Write(h, binary.BigEndian, r.receivedFrom.Hash())
-	binary.Write(h, binary.BigEndian, r.srcPrefixLength)
-	binary.Write(h, binary.BigEndian, r.tag)
-	binary.Write(h, binary.BigEndian, r.weight)
+	binary.Write(h, binary.BigEndian, int64(r.srcPrefixLength))
+	binary.Write(h, binary.BigEndian, int64(r.tag))
+	binary.Write(h, binary.BigEndian, int64(r.weight))
+	if len(r.asSet) != 0 || r.atomicAggregate || r.aggregator.Asn != 0 {
+		binary.Write(h, binary.BigEndian, r.asSet)
+		binary.Write(h, binary.BigEndian, r.atomicAggregate)
+		binary.Write(h, binary.BigEndian, r.aggregator.Asn)
+		aggregatorBytes, _ := r.aggregator.RouterID.MarshalBinary()
+		h.Write(aggregatorBytes)
+	}
 
 	r.hash = h.Sum32()
 }
@@ -110,6 +155,18 @@ func (r *BgpRoute) AsPath() []uint32 {
 	return r.asPath
 }
 
+func (r *BgpRoute) AsSet() []uint32 {
+	return r.asSet
+}
+
+func (r *BgpRoute) AtomicAggregate() bool {
+	return r.atomicAggregate
+}
+
+func (r *BgpRoute) Aggregator() Aggregator {
+	return r.aggregator
+}
+
 func (r *BgpRoute) BgpAdminCost() int {
 	return r.bgpAdminCost
 }
@@ -134,6 +191,10 @@ func (r *BgpRoute) NonRouting() bool {
 	return r.nonRouting
 }
 
+func (r *BgpRoute) Origin() Origin {
+	return r.origin
+}
+
 func (r *BgpRoute) PathID() int {
 	return r.pathID
 }
@@ -160,6 +221,19 @@ func (r *BgpRoute) Weight() int {
 
 // Special Getters
 
+// PathLength returns the AS path length used in best path selection, an AS_SET counts as one
+func (r *BgpRoute) PathLength() int {
+	if len(r.asSet) != 0 {
+		return len(r.asPath) + 1
+	}
+	return len(r.asPath)
+}
+
+// InPath reports whether an AS appears in the AS path or AS_SET
+func (r *BgpRoute) InPath(asn uint32) bool {
+	return slices.Contains(r.asPath, asn) || slices.Contains(r.asSet, asn)
+}
+
 func (r *BgpRoute) Hash() uint32 {
 	return r.hash
 }
@@ -182,6 +256,24 @@ func WithAsPath(asPath []uint32) func(*BgpRoute) {
 	}
 }
 
+func WithAsSet(asSet []uint32) func(*BgpRoute) {
+	return func(r *BgpRoute) {
+		r.asSet = asSet
+	}
+}
+
+func WithAtomicAggregate(atomicAggregate bool) func(*BgpRoute) {
+	return func(r *BgpRoute) {
+		r.atomicAggregate = atomicAggregate
+	}
+}
+
+func WithAggregator(aggregator Aggregator) func(*BgpRoute) {
+	return func(r *BgpRoute) {
+		r.aggregator = aggregator
+	}
+}
+
 func WithBgpAdminCost(bgpAdminCost int) func(*BgpRoute) {
 	return func(r *BgpRoute) {
 		r.bgpAdminCost = bgpAdminCost
@@ -218,6 +310,12 @@ func WithNonRouting(nonRouting bool) func(*BgpRoute) {
 	}
 }
 
+func WithOrigin(origin Origin) func(*BgpRoute) {
+	return func(r *BgpRoute) {
+		r.origin = origin
+	}
+}
+
 func WithPathID(pathID int) func(*BgpRoute) {
 	return func(r *BgpRoute) {
 		r.pathID = pathID
@@ -294,8 +392,14 @@ func CompareMultipath(a, b *BgpRoute) int {
 	}
 
 	// Shortest asPath
-	if len(a.asPath) != len(b.asPath) {
-		return cmp.Compare(len(a.asPath), len(b.asPath)) // Prefer lower
+	if a.PathLength() != b.PathLength() {
+		return cmp.Compare(a.PathLength(), b.PathLength()) // Prefer lower
+	}
+
+	// Lowest origin
+	// IGP < EGP < Incomplete
+	if a.origin != b.origin {
+		return cmp.Compare(a.origin, b.origin) // Prefer lower
 	}
 
 	// Lowest metric
@@ -326,3 +430,20 @@ func CompareTieBreak(a, b *BgpRoute) int {
 	// Compare RxFrom
 	return compareRxFrom(a.receivedFrom, b.receivedFrom)
 }
+
+// CompareNeighbor breaks ties by the neighbor a route was received from only,
+// making the result independent of arrival order.
+func CompareNeighbor(a, b *BgpRoute) int {
+	// Safe to pass in nil
+	if a == nil {
+		if b == nil {
+			return 0
+		} else {
+			return 1
+		}
+	} else if b == nil {
+		return -1
+	}
+
+	return compareRxFrom(a.receivedFrom, b.receivedFrom)
+}
@@ -102,6 +102,24 @@ func TestCompareMultipath(t *testing.T) {
 			b:        New(WithAdminCost(20), WithAsPath([]uint32{1})),
 			expected: -1,
 		},
+		{
+			name:     "prefer lower origin - a wins",
+			a:        New(WithOrigin(IGP)),
+			b:        New(WithOrigin(Incomplete)),
+			expected: -1,
+		},
+		{
+			name:     "prefer lower origin - b wins",
+			a:        New(WithOrigin(Incomplete)),
+			b:        New(WithOrigin(EGP)),
+			expected: 1,
+		},
+		{
+			name:     "origin takes precedence over metric",
+			a:        New(WithOrigin(EGP), WithMetric(5)),
+			b:        New(WithOrigin(IGP), WithMetric(100)),
+			expected: 1,
+		},
 		{
 			name:     "prefer lower metric - a wins",
 			a:        New(WithMetric(10)),
@@ -62,7 +62,7 @@ func WithType(t RxFromType) func(*RxFrom) {
 
 func (rxf *RxFrom) Hash() uint32 {
 	h := fnv.New32a()
-	binary.Write(h, binary.BigEndian, rxf.t)
+	binary.Write(h, binary.BigEndian, int64(rxf.t))
 
 	switch rxf.t {
 	case Local:
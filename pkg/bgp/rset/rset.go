@@ -15,7 +15,8 @@ type RouteSet struct {
 	multipathSet map[uint32]*route.BgpRoute
 
 	// Config
-	multipath bool
+	multipath     bool
+	deterministic bool
 }
 
 // Build a RouteSet from multiple BgpRoutes
@@ -27,12 +28,17 @@ func New(routes []*route.BgpRoute, opts ...func(*RouteSet)) *RouteSet {
 		opt(rs)
 	}
 
+	tieBreak := route.CompareTieBreak
+	if rs.deterministic {
+		tieBreak = route.CompareNeighbor
+	}
+
 	if !rs.multipath {
 		// Get best
 		compFunc := func(a, b *route.BgpRoute) int {
 			comp := route.CompareMultipath(a, b)
 			if comp == 0 {
-				comp = route.CompareTieBreak(a, b)
+				comp = tieBreak(a, b)
 			}
 			return comp
 		}
@@ -74,7 +80,7 @@ func New(routes []*route.BgpRoute, opts ...func(*RouteSet)) *RouteSet {
 		}
 
 		for _, r := range rs.multipathSet {
-			if route.CompareTieBreak(best, r) > 0 {
+			if tieBreak(best, r) > 0 {
 				best = r
 			}
 		}
@@ -93,6 +99,14 @@ func WithMultipath(m bool) func(*RouteSet) {
 	}
 }
 
+// WithDeterministic breaks ties by neighbor instead of oldest route,
+// so the selection does not depend on the order routes arrived in
+func WithDeterministic(d bool) func(*RouteSet) {
+	return func(rs *RouteSet) {
+		rs.deterministic = d
+	}
+}
+
 // Compare equality of two RouteSets
 func (rs *RouteSet) Eq(other *RouteSet) bool {
 	if rs.multipath != other.multipath {
@@ -100,6 +100,20 @@ func TestNew_WithoutMultipath_TieBreak(t *testing.T) {
 	}
 }
 
+func TestNew_WithDeterministic_TieBreak(t *testing.T) {
+	r1 := makeRoute(100, 2, 10, 0).Clone(route.WithReceivedFrom(route.NewRxFrom(route.WithIP(netip.MustParseAddr("10.0.0.2")))))
+	r1.SetArrival(1000) // Earlier arrival
+
+	r2 := makeRoute(100, 2, 10, 0).Clone(route.WithReceivedFrom(route.NewRxFrom(route.WithIP(netip.MustParseAddr("10.0.0.1")))))
+	r2.SetArrival(2000) // Later arrival, lower neighbor
+
+	rs := New([]*route.BgpRoute{r1, r2}, WithDeterministic(true))
+
+	if rs.BestPath() != r2 {
+		t.Errorf("Expected best path from the lowest neighbor regardless of arrival, got arrival %d", rs.BestPath().Arrival())
+	}
+}
+
 // RouteSet creation with multipath
 func TestNew_WithMultipath(t *testing.T) {
 	r1 := makeRouteWithNexthop(100, 2, 10, 0, "192.168.1.1")
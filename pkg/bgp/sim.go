@@ -0,0 +1,168 @@
+package bgp
+
+import (
+	"errors"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+const (
+	defaultMaxRounds = 1000
+)
+
+var (
+	ErrNoConvergence = errors.New("simulation did not converge")
+)
+
+// Simulation propagates routes between the nodes of a BgpTopology until convergence
+type Simulation struct {
+	topology  *BgpTopology
+	clock     int64 // Logical clock, stamps route arrival
+	maxRounds int
+	observer  func(Advertisement)
+	events    *engine       // Discrete-event model, nil when Run works in rounds
+	elapsed   time.Duration // Simulated time of round-based runs, moved by AdvanceTo
+	stats     Stats
+
+	trips           []BgpPeer // Sessions past their maximum-prefix limit, torn down after the current step
+	restarts        []restart // Torn down sessions waiting for their restart timer
+	maxPrefixEvents []MaxPrefixEvent
+}
+
+// Advertisement is an update sent over a session, as seen by an observer
+type Advertisement struct {
+	Clock int64         // Logical time it was sent at
+	Time  time.Duration // Virtual time it was sent at, event-driven runs only
+	Timed bool          // Sent under a timing model, Time is set
+	Peer  BgpPeer       // Session, from the sending side
+	Adv   ra.RouteAdv[*route.BgpRoute]
+}
+
+// Create new Simulation
+func NewSimulation(topology *BgpTopology, opts ...func(*Simulation)) *Simulation {
+	s := &Simulation{
+		topology:  topology,
+		maxRounds: defaultMaxRounds,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Options
+
+func WithMaxRounds(maxRounds int) func(*Simulation) {
+	return func(s *Simulation) {
+		s.maxRounds = maxRounds
+	}
+}
+
+// WithObserver calls fn for every advertisement sent between nodes.
+// Forks and clones of the simulation do not inherit the observer.
+func WithObserver(fn func(Advertisement)) func(*Simulation) {
+	return func(s *Simulation) {
+		s.observer = fn
+	}
+}
+
+// Getters
+
+func (s *Simulation) Topology() *BgpTopology {
+	return s.topology
+}
+
+func (s *Simulation) Clock() int64 {
+	return s.clock
+}
+
+// Timed reports whether the simulation runs under a timing model, see WithTiming
+func (s *Simulation) Timed() bool {
+	return s.events != nil
+}
+
+// Now returns the simulated time: the virtual time of the timing model, or the time set by AdvanceTo
+// when Run works in rounds
+func (s *Simulation) Now() time.Duration {
+	if s.events != nil {
+		return s.events.now
+	}
+	return s.elapsed
+}
+
+// AdvanceTo moves the simulated clock forward to t between runs, it never goes back.
+// Time-based state such as flap dampening penalties decays accordingly on the next run.
+func (s *Simulation) AdvanceTo(t time.Duration) {
+	if s.events != nil {
+		s.events.now = max(s.events.now, t)
+		return
+	}
+	s.elapsed = max(s.elapsed, t)
+}
+
+// Run steps every node in name order until no node has pending work.
+// With a timing model, nodes are instead stepped as updates reach them in virtual time.
+// Connected routes are synced with the topology first. External nodes are skipped.
+// Sessions torn down by a maximum-prefix limit are re-established once their restart timer expired.
+func (s *Simulation) Run() error {
+	s.restartDue()
+	nodes := s.topology.Nodes()
+	for _, n := range nodes {
+		n.syncConnected(s.topology.GetPeers(n.name))
+	}
+	if s.events != nil {
+		return s.events.run(s)
+	}
+	for range s.maxRounds {
+		worked := false
+		for _, n := range nodes {
+			if !n.external && n.step(s) {
+				worked = true
+			}
+			s.applyTrips()
+		}
+		if !worked {
+			return nil
+		}
+	}
+	return ErrNoConvergence
+}
+
+// send passes an advertisement to the remote end of a session, through the timing model if any
+func (s *Simulation) send(peer *BgpPeer, adv ra.RouteAdv[*route.BgpRoute], tx *ra.Tx[*route.BgpRoute]) {
+	if s.events != nil {
+		s.events.outbox = append(s.events.outbox, outgoing{node: peer.LocalNode.name, iface: peer.LocalIface, adv: adv})
+		return
+	}
+	s.count(adv)
+	tx.Push(peer.localKey(), adv)
+	s.observe(peer, adv)
+}
+
+func (s *Simulation) count(adv ra.RouteAdv[*route.BgpRoute]) {
+	if adv.Action == ra.Remove {
+		s.stats.Withdrawals++
+	} else {
+		s.stats.Updates++
+	}
+}
+
+// observe reports an advertisement to the observer, if any
+func (s *Simulation) observe(peer *BgpPeer, adv ra.RouteAdv[*route.BgpRoute]) {
+	if s.observer == nil {
+		return
+	}
+	a := Advertisement{Clock: s.clock, Peer: *peer, Adv: adv}
+	if s.events != nil {
+		a.Time, a.Timed = s.events.now, true
+	}
+	s.observer(a)
+}
+
+// tick advances the logical clock
+func (s *Simulation) tick() int64 {
+	s.clock++
+	return s.clock
+}
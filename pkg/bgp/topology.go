@@ -1,6 +1,50 @@
 package bgp
 
-import "net/netip"
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// Relationship is the business relationship of the remote AS to the local AS
+type Relationship int
+
+const (
+	Unspecified Relationship = iota
+	Customer                 // Remote AS buys transit from the local AS
+	Provider                 // Remote AS sells transit to the local AS
+	Peer                     // Settlement-free peering
+	Sibling                  // Same organisation, routes are shared as if own
+)
+
+func (r Relationship) String() string {
+	switch r {
+	case Unspecified:
+		return "unspecified"
+	case Customer:
+		return "customer"
+	case Provider:
+		return "provider"
+	case Peer:
+		return "peer"
+	case Sibling:
+		return "sibling"
+	}
+	return "unknown"
+}
+
+// Reverse returns the relationship seen from the other end
+func (r Relationship) Reverse() Relationship {
+	switch r {
+	case Customer:
+		return Provider
+	case Provider:
+		return Customer
+	}
+	return r
+}
 
 type BgpPeer struct {
 	LocalNode    *BgpNode
@@ -9,47 +53,159 @@ type BgpPeer struct {
 	RemotePrefix netip.Prefix
 	LocalIface   string
 	RemoteIface  string
+	Relationship Relationship // Of RemoteNode to LocalNode
+
+	MaxPrefix       MaxPrefix // Limit of LocalNode on routes from RemoteNode
+	RemoteMaxPrefix MaxPrefix // Limit of RemoteNode on routes from LocalNode
+}
+
+// localKey identifies the session from the local side
+func (p *BgpPeer) localKey() string {
+	return p.LocalNode.name + "/" + p.LocalIface
+}
+
+// remoteKey identifies the session from the remote side
+func (p *BgpPeer) remoteKey() string {
+	return p.RemoteNode.name + "/" + p.RemoteIface
+}
+
+// reverse returns the same session seen from the remote side
+func (p *BgpPeer) reverse() BgpPeer {
+	return BgpPeer{
+		LocalNode:    p.RemoteNode,
+		RemoteNode:   p.LocalNode,
+		LocalPrefix:  p.RemotePrefix,
+		RemotePrefix: p.LocalPrefix,
+		LocalIface:   p.RemoteIface,
+		RemoteIface:  p.LocalIface,
+		Relationship: p.Relationship.Reverse(),
+
+		MaxPrefix:       p.RemoteMaxPrefix,
+		RemoteMaxPrefix: p.MaxPrefix,
+	}
+}
+
+// session returns the receiving side of the session on the local node
+func (p *BgpPeer) session() session {
+	return session{
+		key:   p.remoteKey(),
+		iface: p.LocalIface,
+		rx:    route.NewRxFrom(route.WithIP(p.RemotePrefix.Addr())),
+		ibgp:  p.ibgp(),
+	}
+}
+
+// ibgp reports whether both ends are in the same AS
+func (p *BgpPeer) ibgp() bool {
+	return p.LocalNode.asn == p.RemoteNode.asn
 }
 
 type BgpTopology struct {
 	peerMap map[string][]BgpPeer
+	nodes   map[string]*BgpNode
 }
 
 func (t *BgpTopology) GetPeers(node string) []BgpPeer {
 	return t.peerMap[node]
 }
 
+// GetPeerByIface returns the peer of a node reached through a local interface
+func (t *BgpTopology) GetPeerByIface(node string, iface string) (BgpPeer, bool) {
+	for _, p := range t.peerMap[node] {
+		if p.LocalIface == iface {
+			return p, true
+		}
+	}
+	return BgpPeer{}, false
+}
+
+// GetPeerByRemoteAddr returns the peer of a node with the given remote address
+func (t *BgpTopology) GetPeerByRemoteAddr(node string, addr netip.Addr) (BgpPeer, bool) {
+	for _, p := range t.peerMap[node] {
+		if p.RemotePrefix.Addr() == addr {
+			return p, true
+		}
+	}
+	return BgpPeer{}, false
+}
+
+// Node returns the node with the given name
+func (t *BgpTopology) Node(name string) (*BgpNode, bool) {
+	n, ok := t.nodes[name]
+	return n, ok
+}
+
+// Nodes returns all nodes ordered by name
+func (t *BgpTopology) Nodes() []*BgpNode {
+	nodes := make([]*BgpNode, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b *BgpNode) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	return nodes
+}
+
+// addPeer adds a session and its reverse, registering both nodes
+func (t *BgpTopology) addPeer(p BgpPeer) {
+	t.nodes[p.LocalNode.name] = p.LocalNode
+	t.nodes[p.RemoteNode.name] = p.RemoteNode
+	t.peerMap[p.LocalNode.name] = append(t.peerMap[p.LocalNode.name], p)
+	t.peerMap[p.RemoteNode.name] = append(t.peerMap[p.RemoteNode.name], p.reverse())
+}
+
+// removePeer removes the session on a node interface and its reverse.
+// Returns the removed session seen from the given end.
+func (t *BgpTopology) removePeer(node string, iface string) (BgpPeer, bool) {
+	p, ok := t.GetPeerByIface(node, iface)
+	if !ok {
+		return BgpPeer{}, false
+	}
+	t.peerMap[node] = slices.DeleteFunc(t.peerMap[node], func(q BgpPeer) bool {
+		return q.localKey() == p.localKey()
+	})
+	remote := p.RemoteNode.name
+	t.peerMap[remote] = slices.DeleteFunc(t.peerMap[remote], func(q BgpPeer) bool {
+		return q.localKey() == p.remoteKey()
+	})
+	return p, true
+}
+
 // Build BgpTopology with BgpPeers, edges are treated as undirected
 type BgpTopologyBuilder struct {
 	peers []BgpPeer
+	nodes []*BgpNode
 }
 
 func (b *BgpTopologyBuilder) AddPeer(peer BgpPeer) {
 	b.peers = append(b.peers, peer)
 }
 
+// AddNode adds a node without requiring it to have peers
+func (b *BgpTopologyBuilder) AddNode(node *BgpNode) {
+	b.nodes = append(b.nodes, node)
+}
+
 func (b *BgpTopologyBuilder) Build() *BgpTopology {
 	t := &BgpTopology{
 		peerMap: make(map[string][]BgpPeer),
+		nodes:   make(map[string]*BgpNode),
+	}
+
+	for _, n := range b.nodes {
+		t.nodes[n.name] = n
 	}
 
 	for _, v := range b.peers {
 		localName := v.LocalNode.name
 		remoteName := v.RemoteNode.name
 
-		t.peerMap[localName] = append(t.peerMap[localName], v)
-
-		// Reverse
-		revPeer := BgpPeer{
-			LocalNode:    v.RemoteNode,
-			RemoteNode:   v.LocalNode,
-			LocalPrefix:  v.RemotePrefix,
-			RemotePrefix: v.LocalPrefix,
-			LocalIface:   v.RemoteIface,
-			RemoteIface:  v.LocalIface,
-		}
+		t.nodes[localName] = v.LocalNode
+		t.nodes[remoteName] = v.RemoteNode
 
-		t.peerMap[remoteName] = append(t.peerMap[remoteName], revPeer)
+		t.peerMap[localName] = append(t.peerMap[localName], v)
+		t.peerMap[remoteName] = append(t.peerMap[remoteName], v.reverse())
 	}
 
 	return t
@@ -0,0 +1,506 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+)
+
+var (
+	ErrTruncated = errors.New("truncated data")
+	ErrMalformed = errors.New("malformed attribute")
+)
+
+// Path attribute type codes
+const (
+	AttrOrigin          = 1
+	AttrAsPath          = 2
+	AttrNextHop         = 3
+	AttrMed             = 4
+	AttrLocalPref       = 5
+	AttrAtomicAggregate = 6
+	AttrAggregator      = 7
+	AttrCommunities     = 8
+	AttrMpReach         = 14
+	AttrMpUnreach       = 15
+	AttrAs4Path         = 17
+	AttrAs4Aggregator   = 18
+)
+
+// Path attribute flags
+const (
+	FlagOptional   = 0x80
+	FlagTransitive = 0x40
+	FlagPartial    = 0x20
+	FlagExtended   = 0x10
+)
+
+// Address families
+const (
+	AfiIPv4       = 1
+	AfiIPv6       = 2
+	SafiUnicast   = 1
+	SafiMulticast = 2
+)
+
+// AS 2-byte placeholder for 4-byte AS numbers
+const AsTrans = 23456
+
+type SegmentType uint8
+
+const (
+	AsSet            SegmentType = 1
+	AsSequence       SegmentType = 2
+	AsConfedSequence SegmentType = 3
+	AsConfedSet      SegmentType = 4
+)
+
+// AsPathSegment is one segment of an AS_PATH
+type AsPathSegment struct {
+	Type SegmentType
+	Asns []uint32
+}
+
+// Aggregator is the AGGREGATOR attribute
+type Aggregator struct {
+	Asn  uint32
+	Addr netip.Addr
+}
+
+// Nlri is a prefix with its ADD-PATH path identifier, zero without ADD-PATH
+type Nlri struct {
+	Prefix netip.Prefix
+	PathID uint32
+}
+
+// MpReach is the MP_REACH_NLRI attribute
+type MpReach struct {
+	Afi      uint16
+	Safi     uint8
+	NextHops []netip.Addr // Global, then link-local for IPv6
+	Nlri     []Nlri
+}
+
+// MpUnreach is the MP_UNREACH_NLRI attribute
+type MpUnreach struct {
+	Afi       uint16
+	Safi      uint8
+	Withdrawn []Nlri
+}
+
+// RawAttribute is an attribute kept as is
+type RawAttribute struct {
+	Flags uint8
+	Type  uint8
+	Value []byte
+}
+
+// Attributes holds decoded path attributes
+type Attributes struct {
+	Origin          route.Origin
+	AsPath          []AsPathSegment
+	NextHop         netip.Addr // Invalid if absent
+	Med             optional.Optional[uint32]
+	LocalPref       optional.Optional[uint32]
+	AtomicAggregate bool
+	Aggregator      *Aggregator
+	Communities     []uint32
+	MpReach         *MpReach
+	MpUnreach       *MpUnreach
+	Unknown         []RawAttribute
+}
+
+// Codec converts messages, path attributes and NLRI between wire format and Go values.
+// ADD-PATH is set per address family and direction, other negotiated settings apply to both directions.
+type Codec struct {
+	as4          bool
+	addPath      map[AfiSafi]uint8 // Family -> send/receive mode
+	shortMpReach bool
+}
+
+// Create new Codec, 4-byte AS numbers are on by default
+func NewCodec(opts ...func(*Codec)) *Codec {
+	c := &Codec{
+		as4: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Options
+
+// WithAS4 toggles 4-byte AS numbers in AS_PATH and AGGREGATOR
+func WithAS4(as4 bool) func(*Codec) {
+	return func(c *Codec) {
+		c.as4 = as4
+	}
+}
+
+// WithAddPath sets the ADD-PATH mode negotiated for each address family.
+// NLRI carry path identifiers when decoded in families with AddPathReceive and when encoded in families with AddPathSend.
+func WithAddPath(modes map[AfiSafi]uint8) func(*Codec) {
+	return func(c *Codec) {
+		c.addPath = maps.Clone(modes)
+	}
+}
+
+// WithShortMpReach toggles the MP_REACH_NLRI form of MRT TABLE_DUMP_V2, holding only the next hop
+func WithShortMpReach(short bool) func(*Codec) {
+	return func(c *Codec) {
+		c.shortMpReach = short
+	}
+}
+
+// DecodeAttributes decodes a sequence of path attributes.
+// Without 4-byte AS numbers, AS4_PATH and AS4_AGGREGATOR are merged in.
+func (c *Codec) DecodeAttributes(b []byte) (*Attributes, error) {
+	a := &Attributes{}
+	var as4Path []AsPathSegment
+	var as4Aggregator *Aggregator
+	for len(b) != 0 {
+		if len(b) < 3 {
+			return nil, ErrTruncated
+		}
+		flags, typ := b[0], b[1]
+		var length int
+		if flags&FlagExtended != 0 {
+			if len(b) < 4 {
+				return nil, ErrTruncated
+			}
+			length = int(binary.BigEndian.Uint16(b[2:]))
+			b = b[4:]
+		} else {
+			length = int(b[2])
+			b = b[3:]
+		}
+		if len(b) < length {
+			return nil, ErrTruncated
+		}
+		value := b[:length]
+		b = b[length:]
+
+		var err error
+		switch typ {
+		case AttrOrigin:
+			if len(value) != 1 || value[0] > byte(route.Incomplete) {
+				return nil, ErrMalformed
+			}
+			a.Origin = route.Origin(value[0])
+		case AttrAsPath:
+			a.AsPath, err = decodeAsPath(value, c.as4)
+		case AttrNextHop:
+			if len(value) != 4 {
+				return nil, ErrMalformed
+			}
+			a.NextHop = netip.AddrFrom4([4]byte(value))
+		case AttrMed:
+			if len(value) != 4 {
+				return nil, ErrMalformed
+			}
+			a.Med = optional.Of(binary.BigEndian.Uint32(value))
+		case AttrLocalPref:
+			if len(value) != 4 {
+				return nil, ErrMalformed
+			}
+			a.LocalPref = optional.Of(binary.BigEndian.Uint32(value))
+		case AttrAtomicAggregate:
+			a.AtomicAggregate = true
+		case AttrAggregator:
+			a.Aggregator, err = decodeAggregator(value, c.as4)
+		case AttrCommunities:
+			if len(value)%4 != 0 {
+				return nil, ErrMalformed
+			}
+			for i := 0; i < len(value); i += 4 {
+				a.Communities = append(a.Communities, binary.BigEndian.Uint32(value[i:]))
+			}
+		case AttrMpReach:
+			a.MpReach, err = c.decodeMpReach(value)
+		case AttrMpUnreach:
+			a.MpUnreach, err = c.decodeMpUnreach(value)
+		case AttrAs4Path:
+			as4Path, err = decodeAsPath(value, true)
+		case AttrAs4Aggregator:
+			as4Aggregator, err = decodeAggregator(value, true)
+		default:
+			a.Unknown = append(a.Unknown, RawAttribute{Flags: flags, Type: typ, Value: slices.Clone(value)})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.as4 {
+		if as4Aggregator != nil && a.Aggregator != nil && a.Aggregator.Asn == AsTrans {
+			a.Aggregator = as4Aggregator
+		}
+		if as4Path != nil {
+			a.AsPath = mergeAs4Path(a.AsPath, as4Path)
+		}
+	}
+	return a, nil
+}
+
+func decodeAsPath(b []byte, as4 bool) ([]AsPathSegment, error) {
+	size := 2
+	if as4 {
+		size = 4
+	}
+	var segments []AsPathSegment
+	for len(b) != 0 {
+		if len(b) < 2 {
+			return nil, ErrTruncated
+		}
+		typ, count := SegmentType(b[0]), int(b[1])
+		if typ < AsSet || typ > AsConfedSet {
+			return nil, ErrMalformed
+		}
+		b = b[2:]
+		if len(b) < count*size {
+			return nil, ErrTruncated
+		}
+		seg := AsPathSegment{Type: typ, Asns: make([]uint32, count)}
+		for i := range count {
+			if as4 {
+				seg.Asns[i] = binary.BigEndian.Uint32(b[i*4:])
+			} else {
+				seg.Asns[i] = uint32(binary.BigEndian.Uint16(b[i*2:]))
+			}
+		}
+		b = b[count*size:]
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+func decodeAggregator(b []byte, as4 bool) (*Aggregator, error) {
+	switch {
+	case as4 && len(b) == 8:
+		return &Aggregator{Asn: binary.BigEndian.Uint32(b), Addr: netip.AddrFrom4([4]byte(b[4:]))}, nil
+	case !as4 && len(b) == 6:
+		return &Aggregator{Asn: uint32(binary.BigEndian.Uint16(b)), Addr: netip.AddrFrom4([4]byte(b[2:]))}, nil
+	}
+	return nil, ErrMalformed
+}
+
+// pathLength counts AS numbers as in best path selection, sets count once and confederations not at all
+func pathLength(segments []AsPathSegment) int {
+	n := 0
+	for _, s := range segments {
+		switch s.Type {
+		case AsSequence:
+			n += len(s.Asns)
+		case AsSet:
+			n++
+		}
+	}
+	return n
+}
+
+// mergeAs4Path reconstructs the AS path from AS_PATH and AS4_PATH (RFC 6793 section 4.2.3)
+func mergeAs4Path(asPath, as4Path []AsPathSegment) []AsPathSegment {
+	keep := pathLength(asPath) - pathLength(as4Path)
+	if keep < 0 {
+		return asPath
+	}
+	var merged []AsPathSegment
+	for _, s := range asPath {
+		if keep == 0 {
+			break
+		}
+		switch s.Type {
+		case AsSequence:
+			n := min(keep, len(s.Asns))
+			merged = append(merged, AsPathSegment{Type: s.Type, Asns: s.Asns[:n]})
+			keep -= n
+		case AsSet:
+			merged = append(merged, s)
+			keep--
+		default:
+			merged = append(merged, s)
+		}
+	}
+	return append(merged, as4Path...)
+}
+
+func (c *Codec) decodeMpReach(b []byte) (*MpReach, error) {
+	mp := &MpReach{}
+	if c.shortMpReach {
+		if len(b) < 1 || len(b) < 1+int(b[0]) {
+			return nil, ErrTruncated
+		}
+		var err error
+		mp.NextHops, err = decodeNextHops(b[1 : 1+int(b[0])])
+		return mp, err
+	}
+
+	if len(b) < 5 {
+		return nil, ErrTruncated
+	}
+	mp.Afi = binary.BigEndian.Uint16(b)
+	mp.Safi = b[2]
+	nhLen := int(b[3])
+	b = b[4:]
+	if len(b) < nhLen+1 {
+		return nil, ErrTruncated
+	}
+	var err error
+	if mp.NextHops, err = decodeNextHops(b[:nhLen]); err != nil {
+		return nil, err
+	}
+	b = b[nhLen+1:] // Reserved byte
+	mp.Nlri, err = c.DecodeNlri(b, AfiSafi{Afi: mp.Afi, Safi: mp.Safi})
+	return mp, err
+}
+
+func (c *Codec) decodeMpUnreach(b []byte) (*MpUnreach, error) {
+	if len(b) < 3 {
+		return nil, ErrTruncated
+	}
+	mp := &MpUnreach{
+		Afi:  binary.BigEndian.Uint16(b),
+		Safi: b[2],
+	}
+	var err error
+	mp.Withdrawn, err = c.DecodeNlri(b[3:], AfiSafi{Afi: mp.Afi, Safi: mp.Safi})
+	return mp, err
+}
+
+func decodeNextHops(b []byte) ([]netip.Addr, error) {
+	switch len(b) {
+	case 4:
+		return []netip.Addr{netip.AddrFrom4([4]byte(b))}, nil
+	case 16:
+		return []netip.Addr{netip.AddrFrom16([16]byte(b))}, nil
+	case 32:
+		return []netip.Addr{netip.AddrFrom16([16]byte(b)), netip.AddrFrom16([16]byte(b[16:]))}, nil
+	}
+	return nil, ErrMalformed
+}
+
+// DecodeNlri decodes a sequence of prefixes of an address family, with path identifiers if received under ADD-PATH
+func (c *Codec) DecodeNlri(b []byte, family AfiSafi) ([]Nlri, error) {
+	pathIDs := c.addPath[family]&AddPathReceive != 0
+	var nlri []Nlri
+	for len(b) != 0 {
+		var n Nlri
+		if pathIDs {
+			if len(b) < 4 {
+				return nil, ErrTruncated
+			}
+			n.PathID = binary.BigEndian.Uint32(b)
+			b = b[4:]
+		}
+		prefix, size, err := DecodePrefix(b, family.Afi)
+		if err != nil {
+			return nil, err
+		}
+		n.Prefix = prefix
+		b = b[size:]
+		nlri = append(nlri, n)
+	}
+	return nlri, nil
+}
+
+// DecodePrefix decodes a length-prefixed prefix, returning the number of bytes consumed
+func DecodePrefix(b []byte, afi uint16) (netip.Prefix, int, error) {
+	if len(b) < 1 {
+		return netip.Prefix{}, 0, ErrTruncated
+	}
+	bits := int(b[0])
+	size := (bits + 7) / 8
+	if len(b) < 1+size {
+		return netip.Prefix{}, 0, ErrTruncated
+	}
+
+	var addr netip.Addr
+	switch afi {
+	case AfiIPv4:
+		if bits > 32 {
+			return netip.Prefix{}, 0, ErrMalformed
+		}
+		var a [4]byte
+		copy(a[:], b[1:1+size])
+		addr = netip.AddrFrom4(a)
+	case AfiIPv6:
+		if bits > 128 {
+			return netip.Prefix{}, 0, ErrMalformed
+		}
+		var a [16]byte
+		copy(a[:], b[1:1+size])
+		addr = netip.AddrFrom16(a)
+	default:
+		return netip.Prefix{}, 0, ErrMalformed
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), 1 + size, nil
+}
+
+// FlatAsPath returns the AS numbers used as a BgpRoute AS path.
+// Set members are kept in order, confederation segments are dropped.
+func (a *Attributes) FlatAsPath() []uint32 {
+	var path []uint32
+	for _, s := range a.AsPath {
+		if s.Type == AsSequence || s.Type == AsSet {
+			path = append(path, s.Asns...)
+		}
+	}
+	return path
+}
+
+// Route builds a BgpRoute for a prefix carrying these attributes.
+// The next hop is NEXT_HOP if of the same family as the prefix, otherwise the first MP_REACH_NLRI next hop.
+// ORIGIN, LOCAL_PREF, ATOMIC_AGGREGATE and AGGREGATOR are kept as is. The conversion is otherwise lossy:
+// AS_SET members are moved after all AS_SEQUENCE members into the route's AS_SET, confederation segments are dropped,
+// an absent MED becomes MED 0, COMMUNITIES and unknown attributes are dropped.
+func (a *Attributes) Route(prefix netip.Prefix, pathID uint32) *route.BgpRoute {
+	var path, set []uint32
+	for _, s := range a.AsPath {
+		switch s.Type {
+		case AsSequence:
+			path = append(path, s.Asns...)
+		case AsSet:
+			set = append(set, s.Asns...)
+		}
+	}
+	opts := []func(*route.BgpRoute){
+		route.WithPrefix(prefix),
+		route.WithPathID(int(pathID)),
+		route.WithOrigin(a.Origin),
+		route.WithAsPath(path),
+		route.WithMetric(int(a.Med.OrElse(0))),
+	}
+	if len(set) != 0 {
+		opts = append(opts, route.WithAsSet(set))
+	}
+	if a.AtomicAggregate {
+		opts = append(opts, route.WithAtomicAggregate(true))
+	}
+	if a.Aggregator != nil {
+		opts = append(opts, route.WithAggregator(route.Aggregator{Asn: a.Aggregator.Asn, RouterID: a.Aggregator.Addr}))
+	}
+	if lp, ok := a.LocalPref.Get(); ok {
+		opts = append(opts, route.WithLocalPreference(lp))
+	}
+	if nh := a.nextHop(prefix); nh.IsValid() {
+		opts = append(opts, route.WithNextHop(nexthop.New(nexthop.WithIP(nh))))
+	}
+	return route.New(opts...)
+}
+
+func (a *Attributes) nextHop(prefix netip.Prefix) netip.Addr {
+	if a.NextHop.IsValid() && a.NextHop.Is4() == prefix.Addr().Is4() {
+		return a.NextHop
+	}
+	if a.MpReach != nil && len(a.MpReach.NextHops) != 0 {
+		return a.MpReach.NextHops[0]
+	}
+	return a.NextHop
+}
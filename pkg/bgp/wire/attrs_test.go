@@ -0,0 +1,138 @@
+package wire
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+)
+
+func TestDecodeAttributes_As4PathMerge(t *testing.T) {
+	// AS_PATH 64496 AS_TRANS 64497 from a 2-byte speaker, AS4_PATH 4200000000 64497
+	b := []byte{
+		FlagTransitive, AttrOrigin, 1, 0,
+		FlagTransitive, AttrAsPath, 8, uint8(AsSequence), 3, 0xfb, 0xf0, 0x5b, 0xa0, 0xfb, 0xf1,
+		FlagOptional | FlagTransitive, AttrAs4Path, 10, uint8(AsSequence), 2, 0xfa, 0x56, 0xea, 0x00, 0, 0, 0xfb, 0xf1,
+	}
+	attrs, err := NewCodec(WithAS4(false)).DecodeAttributes(b)
+	if err != nil {
+		t.Fatalf("DecodeAttributes() = %v", err)
+	}
+	want := []uint32{64496, 4200000000, 64497}
+	if got := attrs.FlatAsPath(); !slices.Equal(got, want) {
+		t.Errorf("FlatAsPath() = %v, expected %v", got, want)
+	}
+}
+
+func TestDecodeAttributes_Truncated(t *testing.T) {
+	b := []byte{FlagTransitive, AttrAsPath, 8, uint8(AsSequence), 3}
+	if _, err := NewCodec().DecodeAttributes(b); err == nil {
+		t.Error("Expected error for truncated attribute")
+	}
+}
+
+func TestEncodeAttributes_As2(t *testing.T) {
+	a := &Attributes{
+		AsPath:  []AsPathSegment{{Type: AsSequence, Asns: []uint32{64496, 4200000000, 64497}}},
+		NextHop: netip.MustParseAddr("192.0.2.1"),
+	}
+	codec := NewCodec(WithAS4(false))
+	decoded, err := codec.DecodeAttributes(codec.EncodeAttributes(a))
+	if err != nil {
+		t.Fatalf("DecodeAttributes() = %v", err)
+	}
+	if got := decoded.FlatAsPath(); !slices.Equal(got, a.FlatAsPath()) {
+		t.Errorf("FlatAsPath() = %v, expected %v", got, a.FlatAsPath())
+	}
+	if decoded.NextHop != a.NextHop {
+		t.Errorf("NextHop = %v, expected %v", decoded.NextHop, a.NextHop)
+	}
+}
+
+func TestUpdate_RoundTrip(t *testing.T) {
+	prefix := netip.MustParsePrefix("2001:db8:1::/48")
+	attrs := &Attributes{
+		AsPath: []AsPathSegment{{Type: AsSequence, Asns: []uint32{4200000000, 64496}}},
+		MpReach: &MpReach{
+			Afi:      AfiIPv6,
+			Safi:     SafiUnicast,
+			NextHops: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			Nlri:     []Nlri{{Prefix: prefix, PathID: 3}},
+		},
+	}
+	codec := NewCodec(WithAddPath(map[AfiSafi]uint8{IPv6Unicast: AddPathBoth}))
+	u, err := codec.DecodeUpdate(codec.EncodeUpdate(&Update{Attributes: attrs}))
+	if err != nil {
+		t.Fatalf("DecodeUpdate() = %v", err)
+	}
+	advs := u.RouteAdvs()
+	if len(advs) != 1 || advs[0].Route.Prefix() != prefix || advs[0].Route.PathID() != 3 {
+		t.Fatalf("Unexpected advertisements %+v", advs)
+	}
+	if got := advs[0].Route.AsPath(); !slices.Equal(got, []uint32{4200000000, 64496}) {
+		t.Errorf("Unexpected AS path %v", got)
+	}
+}
+
+func TestRouteAttributes_Aggregate(t *testing.T) {
+	r := route.New(
+		route.WithPrefix(netip.MustParsePrefix("192.0.2.0/24")),
+		route.WithAsPath([]uint32{64496}),
+		route.WithAsSet([]uint32{64497, 64498}),
+		route.WithAtomicAggregate(true),
+		route.WithAggregator(route.Aggregator{Asn: 64496, RouterID: netip.MustParseAddr("192.0.2.1")}),
+	)
+	codec := NewCodec()
+	decoded, err := codec.DecodeAttributes(codec.EncodeAttributes(RouteAttributes(r, false)))
+	if err != nil {
+		t.Fatalf("DecodeAttributes() = %v", err)
+	}
+	got := decoded.Route(r.Prefix(), 0)
+	if !slices.Equal(got.AsPath(), r.AsPath()) || !slices.Equal(got.AsSet(), r.AsSet()) {
+		t.Errorf("AS path %v and AS_SET %v, expected %v and %v", got.AsPath(), got.AsSet(), r.AsPath(), r.AsSet())
+	}
+	if !got.AtomicAggregate() || got.Aggregator() != r.Aggregator() || got.PathLength() != 2 {
+		t.Errorf("Unexpected aggregate attributes %+v", got)
+	}
+}
+
+func TestAttributes_RouteRoundTrip(t *testing.T) {
+	a := &Attributes{
+		Origin: route.EGP,
+		AsPath: []AsPathSegment{
+			{Type: AsSequence, Asns: []uint32{64496}},
+			{Type: AsSet, Asns: []uint32{64497, 64498}},
+			{Type: AsSequence, Asns: []uint32{64499}},
+		},
+		NextHop:         netip.MustParseAddr("192.0.2.1"),
+		Med:             optional.Of(uint32(0)),
+		LocalPref:       optional.Of(uint32(150)),
+		AtomicAggregate: true,
+		Aggregator:      &Aggregator{Asn: 64496, Addr: netip.MustParseAddr("192.0.2.1")},
+		Communities:     []uint32{64496<<16 | 100},
+		Unknown:         []RawAttribute{{Flags: FlagOptional | FlagTransitive, Type: 99, Value: []byte{1}}},
+	}
+	got := RouteAttributes(a.Route(netip.MustParsePrefix("198.51.100.0/24"), 0), true)
+
+	// Kept as is
+	if got.Origin != a.Origin || got.NextHop != a.NextHop || got.LocalPref != a.LocalPref ||
+		got.AtomicAggregate != a.AtomicAggregate || *got.Aggregator != *a.Aggregator {
+		t.Errorf("Attributes %+v do not match %+v", got, a)
+	}
+	// AS_SET members moved after the AS_SEQUENCE members
+	want := []AsPathSegment{
+		{Type: AsSequence, Asns: []uint32{64496, 64499}},
+		{Type: AsSet, Asns: []uint32{64497, 64498}},
+	}
+	if !slices.EqualFunc(got.AsPath, want, func(a, b AsPathSegment) bool {
+		return a.Type == b.Type && slices.Equal(a.Asns, b.Asns)
+	}) {
+		t.Errorf("AS path %v, expected %v", got.AsPath, want)
+	}
+	// MED 0 is not told apart from an absent MED, COMMUNITIES and unknown attributes are dropped
+	if got.Med.IsValid() || len(got.Communities) != 0 || len(got.Unknown) != 0 {
+		t.Errorf("Expected no MED, COMMUNITIES or unknown attributes, got %+v", got)
+	}
+}
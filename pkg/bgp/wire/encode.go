@@ -0,0 +1,244 @@
+package wire
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+const (
+	maxSegmentLen = 255
+	maxAs2        = 0xffff
+)
+
+// RouteAttributes returns the path attributes carrying a route.
+// IPv6 routes carry their next hop in MP_REACH_NLRI, LOCAL_PREF is only included over iBGP.
+func RouteAttributes(r *route.BgpRoute, ibgp bool) *Attributes {
+	a := &Attributes{Origin: r.Origin(), AtomicAggregate: r.AtomicAggregate()}
+	if path := r.AsPath(); len(path) != 0 {
+		a.AsPath = []AsPathSegment{{Type: AsSequence, Asns: slices.Clone(path)}}
+	}
+	if set := r.AsSet(); len(set) != 0 {
+		a.AsPath = append(a.AsPath, AsPathSegment{Type: AsSet, Asns: slices.Clone(set)})
+	}
+	if agg := r.Aggregator(); agg.Asn != 0 {
+		a.Aggregator = &Aggregator{Asn: agg.Asn, Addr: agg.RouterID}
+	}
+	if r.Metric() != 0 {
+		a.Med = optional.Of(uint32(r.Metric()))
+	}
+	if ibgp {
+		a.LocalPref = optional.Of(r.LocalPreference())
+	}
+
+	nh := r.NextHop()
+	if r.Prefix().Addr().Is4() {
+		a.NextHop = nh.IP() // Dropped on encoding unless IPv4
+	} else {
+		a.MpReach = &MpReach{Afi: AfiIPv6, Safi: SafiUnicast}
+		if nh.IP().IsValid() {
+			a.MpReach.NextHops = []netip.Addr{nh.IP()}
+		}
+	}
+	return a
+}
+
+// NewUpdate builds the update carrying a single advertisement
+func NewUpdate(adv ra.RouteAdv[*route.BgpRoute], ibgp bool) *Update {
+	r := adv.Route
+	nlri := []Nlri{{Prefix: r.Prefix(), PathID: uint32(r.PathID())}}
+	v4 := r.Prefix().Addr().Is4()
+	if adv.Action == ra.Remove {
+		if v4 {
+			return &Update{Withdrawn: nlri}
+		}
+		return &Update{Attributes: &Attributes{
+			MpUnreach: &MpUnreach{Afi: AfiIPv6, Safi: SafiUnicast, Withdrawn: nlri},
+		}}
+	}
+
+	a := RouteAttributes(r, ibgp)
+	if v4 {
+		return &Update{Attributes: a, Nlri: nlri}
+	}
+	a.MpReach.Nlri = nlri
+	return &Update{Attributes: a}
+}
+
+// EncodeUpdate encodes the body of an UPDATE message.
+// Mandatory attributes are only included if the update announces routes.
+func (c *Codec) EncodeUpdate(u *Update) []byte {
+	withdrawn := c.EncodeNlri(u.Withdrawn, IPv4Unicast)
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(withdrawn)))
+	b = append(b, withdrawn...)
+
+	var attrs []byte
+	if u.Attributes != nil {
+		reach := len(u.Nlri) != 0 || u.Attributes.MpReach != nil
+		attrs = c.encodeAttributes(u.Attributes, reach)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	b = append(b, attrs...)
+	return append(b, c.EncodeNlri(u.Nlri, IPv4Unicast)...)
+}
+
+// EncodeAttributes encodes path attributes in type code order.
+// Without 4-byte AS numbers, AS4_PATH and AS4_AGGREGATOR are added as needed.
+func (c *Codec) EncodeAttributes(a *Attributes) []byte {
+	return c.encodeAttributes(a, true)
+}
+
+func (c *Codec) encodeAttributes(a *Attributes, mandatory bool) []byte {
+	var b []byte
+	if mandatory {
+		b = appendAttr(b, FlagTransitive, AttrOrigin, []byte{byte(a.Origin)})
+		b = appendAttr(b, FlagTransitive, AttrAsPath, encodeAsPath(a.AsPath, c.as4))
+	}
+	if a.NextHop.Is4() {
+		b = appendAttr(b, FlagTransitive, AttrNextHop, a.NextHop.AsSlice())
+	}
+	if med, ok := a.Med.Get(); ok {
+		b = appendAttr(b, FlagOptional, AttrMed, binary.BigEndian.AppendUint32(nil, med))
+	}
+	if lp, ok := a.LocalPref.Get(); ok {
+		b = appendAttr(b, FlagTransitive, AttrLocalPref, binary.BigEndian.AppendUint32(nil, lp))
+	}
+	if a.AtomicAggregate {
+		b = appendAttr(b, FlagTransitive, AttrAtomicAggregate, nil)
+	}
+	if a.Aggregator != nil {
+		b = appendAttr(b, FlagOptional|FlagTransitive, AttrAggregator, encodeAggregator(a.Aggregator, c.as4))
+	}
+	if len(a.Communities) != 0 {
+		var value []byte
+		for _, comm := range a.Communities {
+			value = binary.BigEndian.AppendUint32(value, comm)
+		}
+		b = appendAttr(b, FlagOptional|FlagTransitive, AttrCommunities, value)
+	}
+	if a.MpReach != nil {
+		b = appendAttr(b, FlagOptional, AttrMpReach, c.encodeMpReach(a.MpReach))
+	}
+	if a.MpUnreach != nil {
+		family := AfiSafi{Afi: a.MpUnreach.Afi, Safi: a.MpUnreach.Safi}
+		value := binary.BigEndian.AppendUint16(nil, family.Afi)
+		value = append(value, family.Safi)
+		b = appendAttr(b, FlagOptional, AttrMpUnreach, append(value, c.EncodeNlri(a.MpUnreach.Withdrawn, family)...))
+	}
+	if !c.as4 {
+		if mandatory && needsAs4(a.AsPath) {
+			b = appendAttr(b, FlagOptional|FlagTransitive, AttrAs4Path, encodeAsPath(a.AsPath, true))
+		}
+		if a.Aggregator != nil && a.Aggregator.Asn > maxAs2 {
+			b = appendAttr(b, FlagOptional|FlagTransitive, AttrAs4Aggregator, encodeAggregator(a.Aggregator, true))
+		}
+	}
+	for _, raw := range a.Unknown {
+		b = appendAttr(b, raw.Flags&^FlagExtended, raw.Type, raw.Value)
+	}
+	return b
+}
+
+// appendAttr appends an attribute, using an extended length if needed
+func appendAttr(b []byte, flags, typ uint8, value []byte) []byte {
+	if len(value) > 0xff {
+		b = append(b, flags|FlagExtended, typ)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	} else {
+		b = append(b, flags, typ, uint8(len(value)))
+	}
+	return append(b, value...)
+}
+
+// encodeAsPath encodes segments, splitting long ones. 2-byte encoding replaces large AS numbers with AS_TRANS.
+func encodeAsPath(segments []AsPathSegment, as4 bool) []byte {
+	var b []byte
+	for _, s := range segments {
+		for chunk := range slices.Chunk(s.Asns, maxSegmentLen) {
+			b = append(b, byte(s.Type), uint8(len(chunk)))
+			for _, asn := range chunk {
+				if as4 {
+					b = binary.BigEndian.AppendUint32(b, asn)
+				} else {
+					b = binary.BigEndian.AppendUint16(b, uint16(as2(asn)))
+				}
+			}
+		}
+	}
+	return b
+}
+
+func encodeAggregator(agg *Aggregator, as4 bool) []byte {
+	var b []byte
+	if as4 {
+		b = binary.BigEndian.AppendUint32(b, agg.Asn)
+	} else {
+		b = binary.BigEndian.AppendUint16(b, uint16(as2(agg.Asn)))
+	}
+	addr := agg.Addr
+	if !addr.Is4() {
+		addr = netip.IPv4Unspecified()
+	}
+	return append(b, addr.AsSlice()...)
+}
+
+func as2(asn uint32) uint32 {
+	if asn > maxAs2 {
+		return AsTrans
+	}
+	return asn
+}
+
+func needsAs4(segments []AsPathSegment) bool {
+	for _, s := range segments {
+		for _, asn := range s.Asns {
+			if asn > maxAs2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Codec) encodeMpReach(mp *MpReach) []byte {
+	var hops []byte
+	for _, nh := range mp.NextHops {
+		if mp.Afi == AfiIPv6 || !nh.Is4() {
+			a := nh.As16()
+			hops = append(hops, a[:]...)
+		} else {
+			hops = append(hops, nh.AsSlice()...)
+		}
+	}
+	if c.shortMpReach {
+		return append([]byte{uint8(len(hops))}, hops...)
+	}
+	b := binary.BigEndian.AppendUint16(nil, mp.Afi)
+	b = append(b, mp.Safi, uint8(len(hops)))
+	b = append(b, hops...)
+	b = append(b, 0) // Reserved
+	return append(b, c.EncodeNlri(mp.Nlri, AfiSafi{Afi: mp.Afi, Safi: mp.Safi})...)
+}
+
+// EncodeNlri encodes a sequence of prefixes of an address family, with path identifiers if sent under ADD-PATH
+func (c *Codec) EncodeNlri(nlri []Nlri, family AfiSafi) []byte {
+	pathIDs := c.addPath[family]&AddPathSend != 0
+	var b []byte
+	for _, n := range nlri {
+		if pathIDs {
+			b = binary.BigEndian.AppendUint32(b, n.PathID)
+		}
+		b = AppendPrefix(b, n.Prefix)
+	}
+	return b
+}
+
+// AppendPrefix appends a length-prefixed prefix
+func AppendPrefix(b []byte, prefix netip.Prefix) []byte {
+	b = append(b, uint8(prefix.Bits()))
+	return append(b, prefix.Masked().Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+}
@@ -0,0 +1,389 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"slices"
+)
+
+var (
+	ErrBadHeader   = errors.New("malformed message header")
+	ErrBadType     = errors.New("unknown message type")
+	ErrTooLong     = errors.New("message too long")
+	ErrBadOptParam = errors.New("unsupported optional parameter")
+)
+
+// Message types
+const (
+	MsgOpen         = 1
+	MsgUpdate       = 2
+	MsgNotification = 3
+	MsgKeepalive    = 4
+	MsgRouteRefresh = 5
+)
+
+const (
+	HeaderLen     = 19
+	MaxMessageLen = 4096
+	markerLen     = 16
+)
+
+// Capability codes
+const (
+	CapMultiprotocol = 1
+	CapRouteRefresh  = 2
+	CapAS4           = 65
+	CapAddPath       = 69
+)
+
+// ADD-PATH send/receive modes
+const (
+	AddPathReceive = 1
+	AddPathSend    = 2
+	AddPathBoth    = 3
+)
+
+// NOTIFICATION error codes
+const (
+	ErrCodeHeader       = 1
+	ErrCodeOpen         = 2
+	ErrCodeUpdate       = 3
+	ErrCodeHoldTimer    = 4
+	ErrCodeFsm          = 5
+	ErrCodeCease        = 6
+	ErrCodeRouteRefresh = 7
+)
+
+// Message header error subcodes
+const (
+	ErrSubNotSynchronized = 1
+	ErrSubBadLength       = 2
+	ErrSubBadType         = 3
+)
+
+// OPEN error subcodes
+const (
+	ErrSubUnsupportedVersion    = 1
+	ErrSubBadPeerAS             = 2
+	ErrSubBadBgpID              = 3
+	ErrSubUnsupportedOptParam   = 4
+	ErrSubUnacceptableHoldTime  = 6
+	ErrSubUnsupportedCapability = 7
+)
+
+// UPDATE error subcodes
+const (
+	ErrSubMalformedAttributes = 1
+)
+
+// Cease subcodes
+const (
+	ErrSubMaxPrefix          = 1
+	ErrSubAdminShutdown      = 2
+	ErrSubPeerDeconfigured   = 3
+	ErrSubAdminReset         = 4
+	ErrSubConnectionRejected = 5
+)
+
+const optParamCapabilities = 2
+
+// Message is a BGP message
+type Message interface {
+	Type() uint8
+}
+
+// AfiSafi is an address family
+type AfiSafi struct {
+	Afi  uint16
+	Safi uint8
+}
+
+var (
+	IPv4Unicast = AfiSafi{Afi: AfiIPv4, Safi: SafiUnicast}
+	IPv6Unicast = AfiSafi{Afi: AfiIPv6, Safi: SafiUnicast}
+)
+
+// RawCapability is a capability kept as is
+type RawCapability struct {
+	Code  uint8
+	Value []byte
+}
+
+// Capabilities are the capabilities advertised in an OPEN message
+type Capabilities struct {
+	Multiprotocol []AfiSafi
+	RouteRefresh  bool
+	AS4           bool
+	AddPath       map[AfiSafi]uint8 // Family -> send/receive mode
+	Unknown       []RawCapability
+}
+
+// Open is an OPEN message
+type Open struct {
+	Version      uint8
+	Asn          uint32 // From the 4-octet AS capability if present
+	HoldTime     uint16 // Seconds
+	BgpID        netip.Addr
+	Capabilities Capabilities
+}
+
+func (o *Open) Type() uint8 {
+	return MsgOpen
+}
+
+// Notification is a NOTIFICATION message, it also serves as the error closing a session
+type Notification struct {
+	Code    uint8
+	Subcode uint8
+	Data    []byte
+}
+
+func (n *Notification) Type() uint8 {
+	return MsgNotification
+}
+
+func (n *Notification) Error() string {
+	return fmt.Sprintf("notification %d/%d", n.Code, n.Subcode)
+}
+
+// Keepalive is a KEEPALIVE message
+type Keepalive struct{}
+
+func (k *Keepalive) Type() uint8 {
+	return MsgKeepalive
+}
+
+// RouteRefresh is a ROUTE-REFRESH message
+type RouteRefresh struct {
+	AfiSafi
+}
+
+func (r *RouteRefresh) Type() uint8 {
+	return MsgRouteRefresh
+}
+
+// DecodeHeader checks the header of a message, returning its type and body
+func DecodeHeader(b []byte) (uint8, []byte, error) {
+	if len(b) < HeaderLen {
+		return 0, nil, ErrTruncated
+	}
+	for _, m := range b[:markerLen] {
+		if m != 0xff {
+			return 0, nil, ErrBadHeader
+		}
+	}
+	length := int(binary.BigEndian.Uint16(b[markerLen:]))
+	if length < HeaderLen || length > len(b) {
+		return 0, nil, ErrBadHeader
+	}
+	return b[markerLen+2], b[HeaderLen:length], nil
+}
+
+// ReadMessage reads one complete message from a stream
+func ReadMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, HeaderLen, MaxMessageLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[markerLen:]))
+	if length < HeaderLen || length > MaxMessageLen {
+		return nil, ErrBadHeader
+	}
+	b := header[:length]
+	if _, err := io.ReadFull(r, b[HeaderLen:]); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// DecodeMessage decodes a complete message
+func (c *Codec) DecodeMessage(b []byte) (Message, error) {
+	typ, body, err := DecodeHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case MsgOpen:
+		return DecodeOpen(body)
+	case MsgUpdate:
+		return c.DecodeUpdate(body)
+	case MsgNotification:
+		if len(body) < 2 {
+			return nil, ErrTruncated
+		}
+		return &Notification{Code: body[0], Subcode: body[1], Data: slices.Clone(body[2:])}, nil
+	case MsgKeepalive:
+		if len(body) != 0 {
+			return nil, ErrBadHeader
+		}
+		return &Keepalive{}, nil
+	case MsgRouteRefresh:
+		if len(body) != 4 {
+			return nil, ErrBadHeader
+		}
+		return &RouteRefresh{AfiSafi{Afi: binary.BigEndian.Uint16(body), Safi: body[3]}}, nil
+	}
+	return nil, ErrBadType
+}
+
+// EncodeMessage encodes a complete message
+func (c *Codec) EncodeMessage(m Message) ([]byte, error) {
+	var body []byte
+	switch m := m.(type) {
+	case *Open:
+		body = EncodeOpen(m)
+	case *Update:
+		body = c.EncodeUpdate(m)
+	case *Notification:
+		body = append([]byte{m.Code, m.Subcode}, m.Data...)
+	case *Keepalive:
+	case *RouteRefresh:
+		body = binary.BigEndian.AppendUint16(nil, m.Afi)
+		body = append(body, 0, m.Safi)
+	default:
+		return nil, ErrBadType
+	}
+
+	length := HeaderLen + len(body)
+	if length > MaxMessageLen {
+		return nil, ErrTooLong
+	}
+	b := make([]byte, 0, length)
+	for range markerLen {
+		b = append(b, 0xff)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(length))
+	b = append(b, m.Type())
+	return append(b, body...), nil
+}
+
+// DecodeOpen decodes the body of an OPEN message
+func DecodeOpen(b []byte) (*Open, error) {
+	if len(b) < 10 {
+		return nil, ErrTruncated
+	}
+	o := &Open{
+		Version:  b[0],
+		Asn:      uint32(binary.BigEndian.Uint16(b[1:])),
+		HoldTime: binary.BigEndian.Uint16(b[3:]),
+		BgpID:    netip.AddrFrom4([4]byte(b[5:])),
+	}
+	paramLen := int(b[9])
+	b = b[10:]
+	if len(b) < paramLen {
+		return nil, ErrTruncated
+	}
+	b = b[:paramLen]
+
+	for len(b) != 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, ErrTruncated
+		}
+		typ, value := b[0], b[2:2+int(b[1])]
+		b = b[2+int(b[1]):]
+		if typ != optParamCapabilities {
+			return nil, ErrBadOptParam
+		}
+		if err := o.decodeCapabilities(value); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (o *Open) decodeCapabilities(b []byte) error {
+	caps := &o.Capabilities
+	for len(b) != 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return ErrTruncated
+		}
+		code, value := b[0], b[2:2+int(b[1])]
+		b = b[2+int(b[1]):]
+
+		switch code {
+		case CapMultiprotocol:
+			if len(value) != 4 {
+				return ErrMalformed
+			}
+			caps.Multiprotocol = append(caps.Multiprotocol, AfiSafi{Afi: binary.BigEndian.Uint16(value), Safi: value[3]})
+		case CapRouteRefresh:
+			caps.RouteRefresh = true
+		case CapAS4:
+			if len(value) != 4 {
+				return ErrMalformed
+			}
+			caps.AS4 = true
+			o.Asn = binary.BigEndian.Uint32(value)
+		case CapAddPath:
+			if len(value)%4 != 0 {
+				return ErrMalformed
+			}
+			if caps.AddPath == nil {
+				caps.AddPath = make(map[AfiSafi]uint8)
+			}
+			for i := 0; i < len(value); i += 4 {
+				family := AfiSafi{Afi: binary.BigEndian.Uint16(value[i:]), Safi: value[i+2]}
+				caps.AddPath[family] = value[i+3]
+			}
+		default:
+			caps.Unknown = append(caps.Unknown, RawCapability{Code: code, Value: slices.Clone(value)})
+		}
+	}
+	return nil
+}
+
+// EncodeOpen encodes the body of an OPEN message, AS numbers above 65535 are sent as AS_TRANS
+func EncodeOpen(o *Open) []byte {
+	caps := &o.Capabilities
+	var params []byte
+	appendCap := func(code uint8, value []byte) {
+		params = append(params, optParamCapabilities, uint8(2+len(value)), code, uint8(len(value)))
+		params = append(params, value...)
+	}
+	for _, f := range caps.Multiprotocol {
+		value := binary.BigEndian.AppendUint16(nil, f.Afi)
+		appendCap(CapMultiprotocol, append(value, 0, f.Safi))
+	}
+	if caps.RouteRefresh {
+		appendCap(CapRouteRefresh, nil)
+	}
+	if caps.AS4 {
+		appendCap(CapAS4, binary.BigEndian.AppendUint32(nil, o.Asn))
+	}
+	if len(caps.AddPath) != 0 {
+		var value []byte
+		for _, f := range slices.SortedFunc(maps.Keys(caps.AddPath), compareAfiSafi) {
+			value = binary.BigEndian.AppendUint16(value, f.Afi)
+			value = append(value, f.Safi, caps.AddPath[f])
+		}
+		appendCap(CapAddPath, value)
+	}
+	for _, raw := range caps.Unknown {
+		appendCap(raw.Code, raw.Value)
+	}
+
+	b := []byte{o.Version}
+	b = binary.BigEndian.AppendUint16(b, uint16(as2(o.Asn)))
+	b = binary.BigEndian.AppendUint16(b, o.HoldTime)
+	id := o.BgpID
+	if !id.Is4() {
+		id = netip.IPv4Unspecified()
+	}
+	b = append(b, id.AsSlice()...)
+	b = append(b, uint8(len(params)))
+	return append(b, params...)
+}
+
+func compareAfiSafi(a, b AfiSafi) int {
+	if a.Afi != b.Afi {
+		return int(a.Afi) - int(b.Afi)
+	}
+	return int(a.Safi) - int(b.Safi)
+}
@@ -0,0 +1,149 @@
+package wire
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+func roundTrip(t *testing.T, c *Codec, m Message) Message {
+	t.Helper()
+	b, err := c.EncodeMessage(m)
+	if err != nil {
+		t.Fatalf("EncodeMessage() = %v", err)
+	}
+	read, err := ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("ReadMessage() = %v", err)
+	}
+	decoded, err := c.DecodeMessage(read)
+	if err != nil {
+		t.Fatalf("DecodeMessage() = %v", err)
+	}
+	return decoded
+}
+
+func TestMessage_RoundTrip(t *testing.T) {
+	c := NewCodec()
+	for _, m := range []Message{
+		&Open{
+			Version:  4,
+			Asn:      4200000000,
+			HoldTime: 90,
+			BgpID:    netip.MustParseAddr("192.0.2.1"),
+			Capabilities: Capabilities{
+				Multiprotocol: []AfiSafi{IPv4Unicast, IPv6Unicast},
+				RouteRefresh:  true,
+				AS4:           true,
+				AddPath:       map[AfiSafi]uint8{IPv4Unicast: AddPathBoth},
+			},
+		},
+		&Notification{Code: ErrCodeCease, Subcode: ErrSubMaxPrefix, Data: []byte{1, 2}},
+		&Keepalive{},
+		&RouteRefresh{IPv6Unicast},
+	} {
+		if got := roundTrip(t, c, m); !reflect.DeepEqual(got, m) {
+			t.Errorf("Round trip of type %d = %+v, expected %+v", m.Type(), got, m)
+		}
+	}
+}
+
+func TestEncodeOpen_AsTrans(t *testing.T) {
+	o, err := DecodeOpen(EncodeOpen(&Open{Version: 4, Asn: 4200000000}))
+	if err != nil {
+		t.Fatalf("DecodeOpen() = %v", err)
+	}
+	if o.Asn != AsTrans {
+		t.Errorf("Asn = %d without the AS4 capability, expected AS_TRANS", o.Asn)
+	}
+}
+
+func TestRouteAdv_RoundTrip(t *testing.T) {
+	for _, modes := range []map[AfiSafi]uint8{
+		nil,
+		{IPv4Unicast: AddPathBoth},
+		{IPv4Unicast: AddPathBoth, IPv6Unicast: AddPathBoth},
+	} {
+		c := NewCodec(WithAddPath(modes))
+		for _, adv := range []ra.RouteAdv[*route.BgpRoute]{
+			{Action: ra.Add, Route: route.New(
+				route.WithPrefix(netip.MustParsePrefix("198.51.100.0/24")),
+				route.WithAsPath([]uint32{65001, 4200000000}),
+				route.WithNextHop(nexthop.New(nexthop.WithIP(netip.MustParseAddr("192.0.2.1")))),
+				route.WithOrigin(route.EGP),
+				route.WithMetric(20),
+				route.WithLocalPreference(150),
+				route.WithPathID(7),
+			)},
+			{Action: ra.Add, Route: route.New(
+				route.WithPrefix(netip.MustParsePrefix("2001:db8::/32")),
+				route.WithAsPath([]uint32{65001}),
+				route.WithNextHop(nexthop.New(nexthop.WithIP(netip.MustParseAddr("2001:db8::1")))),
+				route.WithLocalPreference(100),
+				route.WithPathID(3),
+			)},
+			{Action: ra.Remove, Route: route.New(route.WithPrefix(netip.MustParsePrefix("198.51.100.0/24")), route.WithPathID(7))},
+			{Action: ra.Remove, Route: route.New(route.WithPrefix(netip.MustParsePrefix("2001:db8::/32")), route.WithPathID(3))},
+		} {
+			u := roundTrip(t, c, NewUpdate(adv, true)).(*Update)
+			advs := u.RouteAdvs()
+			if len(advs) != 1 {
+				t.Fatalf("Expected 1 advertisement, got %d", len(advs))
+			}
+			got, want := advs[0].Route, adv.Route
+			if advs[0].Action != adv.Action || got.Prefix() != want.Prefix() {
+				t.Errorf("Got %v %v, expected %v %v", advs[0].Action, got.Prefix(), adv.Action, want.Prefix())
+			}
+			family := IPv6Unicast
+			if want.Prefix().Addr().Is4() {
+				family = IPv4Unicast
+			}
+			wantID := 0
+			if modes[family] != 0 {
+				wantID = want.PathID()
+			}
+			if got.PathID() != wantID {
+				t.Errorf("PathID() = %d, expected %d", got.PathID(), wantID)
+			}
+			if adv.Action == ra.Remove {
+				continue
+			}
+			gotNh, wantNh := got.NextHop(), want.NextHop()
+			if !slices.Equal(got.AsPath(), want.AsPath()) || gotNh.IP() != wantNh.IP() ||
+				got.Origin() != want.Origin() || got.Metric() != want.Metric() ||
+				got.LocalPreference() != want.LocalPreference() {
+				t.Errorf("Route %v does not match %v", got, want)
+			}
+		}
+	}
+}
+
+func TestNlri_AddPathDirection(t *testing.T) {
+	nlri := []Nlri{{Prefix: netip.MustParsePrefix("198.51.100.0/24"), PathID: 7}}
+	for _, tc := range []struct {
+		mode    uint8
+		encoded int
+	}{
+		{AddPathReceive, 4},
+		{AddPathSend, 8},
+		{AddPathBoth, 8},
+	} {
+		c := NewCodec(WithAddPath(map[AfiSafi]uint8{IPv4Unicast: tc.mode}))
+		if got := len(c.EncodeNlri(nlri, IPv4Unicast)); got != tc.encoded {
+			t.Errorf("Mode %d encoded %d bytes, expected %d", tc.mode, got, tc.encoded)
+		}
+	}
+
+	sender := NewCodec(WithAddPath(map[AfiSafi]uint8{IPv4Unicast: AddPathSend}))
+	receiver := NewCodec(WithAddPath(map[AfiSafi]uint8{IPv4Unicast: AddPathReceive}))
+	got, err := receiver.DecodeNlri(sender.EncodeNlri(nlri, IPv4Unicast), IPv4Unicast)
+	if err != nil || !slices.Equal(got, nlri) {
+		t.Errorf("DecodeNlri() = %v, %v, expected %v", got, err, nlri)
+	}
+}
@@ -0,0 +1,88 @@
+package wire
+
+import (
+	"encoding/binary"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Update is an UPDATE message
+type Update struct {
+	Withdrawn  []Nlri      // IPv4 unicast
+	Attributes *Attributes // Nil if the message only withdraws
+	Nlri       []Nlri      // IPv4 unicast
+}
+
+func (u *Update) Type() uint8 {
+	return MsgUpdate
+}
+
+// DecodeUpdate decodes the body of an UPDATE message
+func (c *Codec) DecodeUpdate(b []byte) (*Update, error) {
+	if len(b) < 2 {
+		return nil, ErrTruncated
+	}
+	wLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < wLen+2 {
+		return nil, ErrTruncated
+	}
+	u := &Update{}
+	var err error
+	if u.Withdrawn, err = c.DecodeNlri(b[:wLen], IPv4Unicast); err != nil {
+		return nil, err
+	}
+	b = b[wLen:]
+
+	aLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < aLen {
+		return nil, ErrTruncated
+	}
+	if aLen != 0 {
+		if u.Attributes, err = c.DecodeAttributes(b[:aLen]); err != nil {
+			return nil, err
+		}
+	}
+	if u.Nlri, err = c.DecodeNlri(b[aLen:], IPv4Unicast); err != nil {
+		return nil, err
+	}
+	if len(u.Nlri) != 0 && u.Attributes == nil {
+		return nil, ErrMalformed
+	}
+	return u, nil
+}
+
+// RouteAdvs converts the unicast reachability of an update into advertisements, withdrawals first
+func (u *Update) RouteAdvs() []ra.RouteAdv[*route.BgpRoute] {
+	var advs []ra.RouteAdv[*route.BgpRoute]
+	withdraw := func(nlri []Nlri) {
+		for _, n := range nlri {
+			advs = append(advs, ra.RouteAdv[*route.BgpRoute]{
+				Route:  route.New(route.WithPrefix(n.Prefix), route.WithPathID(int(n.PathID))),
+				Action: ra.Remove,
+			})
+		}
+	}
+	announce := func(nlri []Nlri) {
+		for _, n := range nlri {
+			advs = append(advs, ra.RouteAdv[*route.BgpRoute]{
+				Route:  u.Attributes.Route(n.Prefix, n.PathID),
+				Action: ra.Add,
+			})
+		}
+	}
+
+	withdraw(u.Withdrawn)
+	if a := u.Attributes; a != nil {
+		if a.MpUnreach != nil && a.MpUnreach.Safi == SafiUnicast {
+			withdraw(a.MpUnreach.Withdrawn)
+		}
+		announce(u.Nlri)
+		if a.MpReach != nil && a.MpReach.Safi == SafiUnicast {
+			announce(a.MpReach.Nlri)
+		}
+	}
+	return advs
+}
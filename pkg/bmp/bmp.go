@@ -0,0 +1,460 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+var (
+	ErrTruncated  = errors.New("truncated message")
+	ErrBadVersion = errors.New("unsupported BMP version")
+	ErrBadType    = errors.New("unknown message type")
+)
+
+const (
+	Version       = 3
+	HeaderLen     = 6
+	PeerHeaderLen = 42
+	maxMessageLen = 1 << 20 // Guards against corrupt length fields
+)
+
+// Message types
+const (
+	MsgRouteMonitoring = 0
+	MsgStatsReport     = 1
+	MsgPeerDown        = 2
+	MsgPeerUp          = 3
+	MsgInitiation      = 4
+	MsgTermination     = 5
+)
+
+// Peer types
+const (
+	PeerGlobal = 0
+	PeerRD     = 1
+	PeerLocal  = 2
+)
+
+// Per-peer header flags
+const (
+	FlagIPv6       = 0x80
+	FlagPostPolicy = 0x40
+	FlagAS2        = 0x20 // AS_PATH in route monitoring uses 2 byte AS numbers
+)
+
+// Information TLV types of Initiation and Peer Up messages
+const (
+	InfoString   = 0
+	InfoSysDescr = 1
+	InfoSysName  = 2
+)
+
+// Information TLV types of Termination messages
+const (
+	TermString = 0
+	TermReason = 1
+)
+
+// Termination reasons
+const (
+	TermAdminClose     = 0
+	TermUnspecified    = 1
+	TermOutOfResources = 2
+	TermRedundant      = 3
+	TermPermAdminClose = 4
+)
+
+// Peer Down reasons
+const (
+	DownLocalNotification   = 1
+	DownLocalNoNotification = 2 // Data is a 2 byte FSM event code
+	DownRemoteNotification  = 3
+	DownRemoteNoData        = 4
+	DownDeconfigured        = 5
+)
+
+// Stat types
+const (
+	StatRejected          = 0
+	StatDuplicatePrefix   = 1
+	StatDuplicateWithdraw = 2
+	StatClusterListLoop   = 3
+	StatAsPathLoop        = 4
+	StatOriginatorLoop    = 5
+	StatConfedLoop        = 6
+	StatAdjRibIn          = 7
+	StatLocRib            = 8
+)
+
+// Message is a BMP message
+type Message interface {
+	Type() uint8
+}
+
+// PeerHeader identifies the monitored peer of a message
+type PeerHeader struct {
+	PeerType      uint8
+	Flags         uint8
+	Distinguisher uint64
+	Addr          netip.Addr
+	Asn           uint32
+	BgpID         netip.Addr
+	Timestamp     time.Time
+}
+
+// PostPolicy reports whether routes were monitored after import policy
+func (h *PeerHeader) PostPolicy() bool {
+	return h.Flags&FlagPostPolicy != 0
+}
+
+// Info is an information TLV
+type Info struct {
+	Type  uint16
+	Value []byte
+}
+
+// Stat is a statistic of a Stats Report. Types 7 and 8 are 64 bit gauges, others 32 bit counters.
+type Stat struct {
+	Type  uint16
+	Value uint64
+}
+
+// RouteMonitoring carries routes received from a peer
+type RouteMonitoring struct {
+	Peer   PeerHeader
+	Update []byte // Complete BGP UPDATE message
+}
+
+func (m *RouteMonitoring) Type() uint8 {
+	return MsgRouteMonitoring
+}
+
+// StatsReport carries statistics about a peer
+type StatsReport struct {
+	Peer  PeerHeader
+	Stats []Stat
+}
+
+func (m *StatsReport) Type() uint8 {
+	return MsgStatsReport
+}
+
+// PeerDown reports a session going down
+type PeerDown struct {
+	Peer   PeerHeader
+	Reason uint8
+	Data   []byte
+}
+
+func (m *PeerDown) Type() uint8 {
+	return MsgPeerDown
+}
+
+// PeerUp reports a session reaching Established
+type PeerUp struct {
+	Peer         PeerHeader
+	LocalAddr    netip.Addr
+	LocalPort    uint16
+	RemotePort   uint16
+	SentOpen     []byte // Complete BGP OPEN messages
+	ReceivedOpen []byte
+	Info         []Info
+}
+
+func (m *PeerUp) Type() uint8 {
+	return MsgPeerUp
+}
+
+// Initiation starts the stream of a monitored router
+type Initiation struct {
+	Info []Info
+}
+
+func (m *Initiation) Type() uint8 {
+	return MsgInitiation
+}
+
+// Termination ends the stream of a monitored router
+type Termination struct {
+	Info []Info
+}
+
+func (m *Termination) Type() uint8 {
+	return MsgTermination
+}
+
+// Decoding
+
+// DecodeHeader checks the common header of a message, returning its type and body
+func DecodeHeader(b []byte) (uint8, []byte, error) {
+	if len(b) < HeaderLen {
+		return 0, nil, ErrTruncated
+	}
+	if b[0] != Version {
+		return 0, nil, ErrBadVersion
+	}
+	length := int(binary.BigEndian.Uint32(b[1:]))
+	if length < HeaderLen || length > len(b) {
+		return 0, nil, ErrTruncated
+	}
+	return b[5], b[HeaderLen:length], nil
+}
+
+// ReadMessage reads one complete message from a stream
+func ReadMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != Version {
+		return nil, ErrBadVersion
+	}
+	length := int(binary.BigEndian.Uint32(header[1:]))
+	if length < HeaderLen || length > maxMessageLen {
+		return nil, ErrTruncated
+	}
+	b := make([]byte, length)
+	copy(b, header)
+	if _, err := io.ReadFull(r, b[HeaderLen:]); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// DecodeMessage decodes a complete message
+func DecodeMessage(b []byte) (Message, error) {
+	typ, body, err := DecodeHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case MsgInitiation:
+		info, err := decodeInfo(body)
+		return &Initiation{Info: info}, err
+	case MsgTermination:
+		info, err := decodeInfo(body)
+		return &Termination{Info: info}, err
+	case MsgRouteMonitoring, MsgStatsReport, MsgPeerDown, MsgPeerUp:
+	default:
+		return nil, ErrBadType
+	}
+
+	peer, body, err := DecodePeerHeader(body)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case MsgRouteMonitoring:
+		return &RouteMonitoring{Peer: peer, Update: slices.Clone(body)}, nil
+	case MsgStatsReport:
+		return decodeStatsReport(peer, body)
+	case MsgPeerDown:
+		if len(body) < 1 {
+			return nil, ErrTruncated
+		}
+		return &PeerDown{Peer: peer, Reason: body[0], Data: slices.Clone(body[1:])}, nil
+	}
+	return decodePeerUp(peer, body)
+}
+
+// DecodePeerHeader decodes the per-peer header at the start of a message body
+func DecodePeerHeader(b []byte) (PeerHeader, []byte, error) {
+	if len(b) < PeerHeaderLen {
+		return PeerHeader{}, nil, ErrTruncated
+	}
+	h := PeerHeader{
+		PeerType:      b[0],
+		Flags:         b[1],
+		Distinguisher: binary.BigEndian.Uint64(b[2:]),
+		Asn:           binary.BigEndian.Uint32(b[26:]),
+		BgpID:         netip.AddrFrom4([4]byte(b[30:])),
+	}
+	h.Addr = decodeAddr(b[10:], h.Flags&FlagIPv6 != 0)
+	sec, usec := binary.BigEndian.Uint32(b[34:]), binary.BigEndian.Uint32(b[38:])
+	h.Timestamp = time.Unix(int64(sec), int64(usec)*int64(time.Microsecond)).UTC()
+	return h, b[PeerHeaderLen:], nil
+}
+
+// decodeAddr decodes a 16 byte address field, IPv4 addresses sit in its last 4 bytes
+func decodeAddr(b []byte, v6 bool) netip.Addr {
+	if v6 {
+		return netip.AddrFrom16([16]byte(b))
+	}
+	return netip.AddrFrom4([4]byte(b[12:]))
+}
+
+func decodeInfo(b []byte) ([]Info, error) {
+	var info []Info
+	for len(b) != 0 {
+		if len(b) < 4 {
+			return nil, ErrTruncated
+		}
+		length := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+length {
+			return nil, ErrTruncated
+		}
+		info = append(info, Info{Type: binary.BigEndian.Uint16(b), Value: slices.Clone(b[4 : 4+length])})
+		b = b[4+length:]
+	}
+	return info, nil
+}
+
+func decodeStatsReport(peer PeerHeader, b []byte) (*StatsReport, error) {
+	if len(b) < 4 {
+		return nil, ErrTruncated
+	}
+	count := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	m := &StatsReport{Peer: peer}
+	for range count {
+		if len(b) < 4 {
+			return nil, ErrTruncated
+		}
+		typ, length := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+length {
+			return nil, ErrTruncated
+		}
+		s := Stat{Type: typ}
+		switch length {
+		case 4:
+			s.Value = uint64(binary.BigEndian.Uint32(b[4:]))
+		case 8:
+			s.Value = binary.BigEndian.Uint64(b[4:])
+		}
+		m.Stats = append(m.Stats, s)
+		b = b[4+length:]
+	}
+	return m, nil
+}
+
+func decodePeerUp(peer PeerHeader, b []byte) (*PeerUp, error) {
+	if len(b) < 20 {
+		return nil, ErrTruncated
+	}
+	m := &PeerUp{
+		Peer:       peer,
+		LocalAddr:  decodeAddr(b, peer.Flags&FlagIPv6 != 0),
+		LocalPort:  binary.BigEndian.Uint16(b[16:]),
+		RemotePort: binary.BigEndian.Uint16(b[18:]),
+	}
+	b = b[20:]
+	var err error
+	if m.SentOpen, b, err = splitBgpMessage(b); err != nil {
+		return nil, err
+	}
+	if m.ReceivedOpen, b, err = splitBgpMessage(b); err != nil {
+		return nil, err
+	}
+	if m.Info, err = decodeInfo(b); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// splitBgpMessage splits off a complete BGP message using the length in its header
+func splitBgpMessage(b []byte) ([]byte, []byte, error) {
+	if len(b) < 19 {
+		return nil, nil, ErrTruncated
+	}
+	length := int(binary.BigEndian.Uint16(b[16:]))
+	if length < 19 || len(b) < length {
+		return nil, nil, ErrTruncated
+	}
+	return slices.Clone(b[:length]), b[length:], nil
+}
+
+// Encoding
+
+// EncodeMessage encodes a complete message
+func EncodeMessage(m Message) []byte {
+	b := []byte{Version, 0, 0, 0, 0, m.Type()}
+	switch m := m.(type) {
+	case *Initiation:
+		b = appendInfo(b, m.Info)
+	case *Termination:
+		b = appendInfo(b, m.Info)
+	case *RouteMonitoring:
+		b = appendPeerHeader(b, &m.Peer)
+		b = append(b, m.Update...)
+	case *StatsReport:
+		b = appendPeerHeader(b, &m.Peer)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(m.Stats)))
+		for _, s := range m.Stats {
+			b = binary.BigEndian.AppendUint16(b, s.Type)
+			if gauge(s.Type) {
+				b = binary.BigEndian.AppendUint16(b, 8)
+				b = binary.BigEndian.AppendUint64(b, s.Value)
+			} else {
+				b = binary.BigEndian.AppendUint16(b, 4)
+				b = binary.BigEndian.AppendUint32(b, uint32(s.Value))
+			}
+		}
+	case *PeerDown:
+		b = appendPeerHeader(b, &m.Peer)
+		b = append(b, m.Reason)
+		b = append(b, m.Data...)
+	case *PeerUp:
+		b = appendPeerHeader(b, &m.Peer)
+		b = appendAddr(b, m.LocalAddr)
+		b = binary.BigEndian.AppendUint16(b, m.LocalPort)
+		b = binary.BigEndian.AppendUint16(b, m.RemotePort)
+		b = append(b, m.SentOpen...)
+		b = append(b, m.ReceivedOpen...)
+		b = appendInfo(b, m.Info)
+	}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(b)))
+	return b
+}
+
+func gauge(typ uint16) bool {
+	return typ == StatAdjRibIn || typ == StatLocRib
+}
+
+func appendPeerHeader(b []byte, h *PeerHeader) []byte {
+	flags := h.Flags &^ FlagIPv6
+	if h.Addr.Is6() && !h.Addr.Is4In6() {
+		flags |= FlagIPv6
+	}
+	b = append(b, h.PeerType, flags)
+	b = binary.BigEndian.AppendUint64(b, h.Distinguisher)
+	b = appendAddr(b, h.Addr)
+	b = binary.BigEndian.AppendUint32(b, h.Asn)
+	id := h.BgpID
+	if !id.Is4() {
+		id = netip.IPv4Unspecified()
+	}
+	b = append(b, id.AsSlice()...)
+	var sec, usec uint32
+	if !h.Timestamp.IsZero() {
+		sec = uint32(h.Timestamp.Unix())
+		usec = uint32(h.Timestamp.Nanosecond() / int(time.Microsecond))
+	}
+	b = binary.BigEndian.AppendUint32(b, sec)
+	return binary.BigEndian.AppendUint32(b, usec)
+}
+
+// appendAddr appends a 16 byte address field, IPv4 addresses are padded with leading zeros
+func appendAddr(b []byte, addr netip.Addr) []byte {
+	if addr.Is4() {
+		b = append(b, make([]byte, 12)...)
+		return append(b, addr.AsSlice()...)
+	}
+	a := addr.As16()
+	return append(b, a[:]...)
+}
+
+func appendInfo(b []byte, info []Info) []byte {
+	for _, i := range info {
+		b = binary.BigEndian.AppendUint16(b, i.Type)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(i.Value)))
+		b = append(b, i.Value...)
+	}
+	return b
+}
@@ -0,0 +1,265 @@
+package bmp
+
+import (
+	"bytes"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+var (
+	v4Prefix = netip.MustParsePrefix("203.0.113.0/24")
+	v6Prefix = netip.MustParsePrefix("2001:db8::/32")
+)
+
+// Chain a - b - c, c originates an IPv4 and an IPv6 prefix
+func makeChain() *bgp.Simulation {
+	a := bgp.NewBgpNode("a", 65001, bgp.WithRouterID(netip.MustParseAddr("192.0.2.1")))
+	b := bgp.NewBgpNode("b", 65002, bgp.WithRouterID(netip.MustParseAddr("192.0.2.2")))
+	c := bgp.NewBgpNode("c", 65003, bgp.WithRouterID(netip.MustParseAddr("192.0.2.3")))
+	builder := &bgp.BgpTopologyBuilder{}
+	for i, pair := range [][2]*bgp.BgpNode{{a, b}, {b, c}} {
+		builder.AddPeer(bgp.BgpPeer{
+			LocalNode:    pair[0],
+			RemoteNode:   pair[1],
+			LocalPrefix:  netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 1}), 30),
+			RemotePrefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 2}), 30),
+			LocalIface:   "eth1",
+			RemoteIface:  "eth0",
+		})
+	}
+	for _, p := range []netip.Prefix{v4Prefix, v6Prefix} {
+		c.AddStaticRoute(bgp.StaticRoute{Prefix: p, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+		c.AddNetwork(bgp.Network{Prefix: p})
+	}
+	return bgp.NewSimulation(builder.Build())
+}
+
+func readAll(t *testing.T, buf *bytes.Buffer) []Message {
+	t.Helper()
+	var msgs []Message
+	for buf.Len() != 0 {
+		b, err := ReadMessage(buf)
+		if err != nil {
+			t.Fatalf("ReadMessage() = %v", err)
+		}
+		m, err := DecodeMessage(b)
+		if err != nil {
+			t.Fatalf("DecodeMessage() = %v", err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func TestEncodeMessage_RoundTrip(t *testing.T) {
+	peer := PeerHeader{
+		Flags:     FlagPostPolicy,
+		Addr:      netip.MustParseAddr("2001:db8::2"),
+		Asn:       4200000000,
+		BgpID:     netip.MustParseAddr("192.0.2.2"),
+		Timestamp: time.Unix(1700000000, 5000).UTC(),
+	}
+	open := encodeOpen(bgp.NewBgpNode("x", 65001), true)
+	msgs := []Message{
+		&Initiation{Info: []Info{{Type: InfoSysName, Value: []byte("x")}}},
+		&PeerUp{Peer: peer, LocalAddr: netip.MustParseAddr("2001:db8::1"), LocalPort: 179, RemotePort: 40000, SentOpen: open, ReceivedOpen: open},
+		&StatsReport{Peer: peer, Stats: []Stat{{Type: StatRejected, Value: 3}, {Type: StatAdjRibIn, Value: 1 << 40}}},
+		&PeerDown{Peer: peer, Reason: DownRemoteNoData},
+	}
+	for _, m := range msgs {
+		got, err := DecodeMessage(EncodeMessage(m))
+		if err != nil {
+			t.Fatalf("DecodeMessage(%T) = %v", m, err)
+		}
+		if !bytes.Equal(EncodeMessage(got), EncodeMessage(m)) {
+			t.Errorf("%T did not round trip: %+v", m, got)
+		}
+	}
+
+	up := msgs[1].(*PeerUp)
+	if up.Peer.Flags&FlagIPv6 != 0 {
+		t.Fatal("Encoding must not modify the message")
+	}
+	got, _ := DecodeMessage(EncodeMessage(up))
+	if h := got.(*PeerUp).Peer; h.Flags&FlagIPv6 == 0 || h.Addr != peer.Addr || !h.Timestamp.Equal(peer.Timestamp) {
+		t.Errorf("Unexpected peer header %+v", h)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	sim := makeChain()
+	b, _ := sim.Topology().Node("b")
+	// b rejects the IPv6 prefix from c
+	b.SetImportPolicy("eth1", policy.New(
+		policy.Clause{Match: []policy.Match{policy.MatchPrefix(v6Prefix)}, Action: policy.Deny},
+		policy.Clause{Action: policy.Permit},
+	))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var buf bytes.Buffer
+	m := NewMonitor(sim, b, &buf)
+	if err := m.Sync(); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	msgs := readAll(t, &buf)
+	if _, ok := msgs[0].(*Initiation); !ok {
+		t.Fatalf("Expected Initiation first, got %T", msgs[0])
+	}
+
+	c := netip.MustParseAddr("10.0.1.2")
+	var ups, pre, post int
+	for _, msg := range msgs[1:] {
+		switch msg := msg.(type) {
+		case *PeerUp:
+			ups++
+			if msg.Peer.Addr == c && msg.Peer.Asn != 65003 {
+				t.Errorf("Unexpected peer header %+v", msg.Peer)
+			}
+		case *RouteMonitoring:
+			if msg.Peer.Addr != c {
+				t.Errorf("Unexpected routes from %v", msg.Peer.Addr)
+			}
+			if msg.Peer.PostPolicy() {
+				post++
+			} else {
+				pre++
+			}
+		case *StatsReport:
+			if msg.Peer.Addr == c && !slices.Equal(msg.Stats, []Stat{{Type: StatAdjRibIn, Value: 2}, {Type: StatLocRib, Value: 1}}) {
+				t.Errorf("Unexpected stats %+v", msg.Stats)
+			}
+		}
+	}
+	if ups != 2 || pre != 2 || post != 1 {
+		t.Fatalf("Expected 2 peers up, 2 pre- and 1 post-policy routes, got %d, %d and %d", ups, pre, post)
+	}
+
+	// Withdrawals are monitored, removed links are reported down
+	origin, _ := sim.Topology().Node("c")
+	origin.RemoveNetwork(v4Prefix)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	m.Sync()
+	var withdrawn int
+	for _, msg := range readAll(t, &buf) {
+		if rm, ok := msg.(*RouteMonitoring); ok {
+			u, err := wire.NewCodec().DecodeMessage(rm.Update)
+			if err != nil {
+				t.Fatalf("DecodeMessage() = %v", err)
+			}
+			withdrawn += len(u.(*wire.Update).Withdrawn)
+		}
+	}
+	if withdrawn != 2 {
+		t.Errorf("Expected the prefix withdrawn pre- and post-policy, got %d withdrawals", withdrawn)
+	}
+
+	sim.RemoveLink(bgp.LinkID{Node: "b", Iface: "eth1"})
+	WithStats(false)(m)
+	m.Sync()
+	msgs = readAll(t, &buf)
+	if len(msgs) != 1 {
+		t.Fatalf("Expected a single message, got %d", len(msgs))
+	}
+	if down, ok := msgs[0].(*PeerDown); !ok || down.Peer.Addr != c {
+		t.Fatalf("Expected Peer Down for %v, got %+v", c, msgs[0])
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if _, ok := readAll(t, &buf)[0].(*Termination); !ok {
+		t.Fatal("Expected Termination")
+	}
+}
+
+func TestMonitor_AddPath(t *testing.T) {
+	s := bgp.NewBgpNode("s", 65001, bgp.WithRouterID(netip.MustParseAddr("192.0.2.1")))
+	r := bgp.NewBgpNode("r", 65002, bgp.WithExternal(true))
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    s,
+		RemoteNode:   r,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	})
+	sim := bgp.NewSimulation(builder.Build())
+	id := bgp.LinkID{Node: "r", Iface: "eth0"}
+	path := func(id int) ra.RouteAdv[*route.BgpRoute] {
+		return ra.RouteAdv[*route.BgpRoute]{Action: ra.Add, Route: route.New(
+			route.WithPrefix(v4Prefix),
+			route.WithPathID(id),
+			route.WithAsPath([]uint32{65002, uint32(65100 + id)}),
+			route.WithNextHop(nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.2")))),
+		)}
+	}
+	sim.Inject(id, path(1), path(2))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var buf bytes.Buffer
+	m := NewMonitor(sim, s, &buf, WithStats(false))
+	m.Sync()
+	codec := wire.NewCodec(wire.WithAddPath(addPathModes))
+	var localPrefs int
+	for _, msg := range readAll(t, &buf) {
+		switch msg := msg.(type) {
+		case *PeerUp:
+			open, err := wire.NewCodec().DecodeMessage(msg.ReceivedOpen)
+			if err != nil || len(open.(*wire.Open).Capabilities.AddPath) == 0 {
+				t.Errorf("Expected ADD-PATH in the OPEN of the peer, got %+v, %v", open, err)
+			}
+		case *RouteMonitoring:
+			u, err := codec.DecodeMessage(msg.Update)
+			if err != nil {
+				t.Fatalf("DecodeMessage() = %v", err)
+			}
+			if _, ok := u.(*wire.Update).Attributes.LocalPref.Get(); ok {
+				localPrefs++
+				if !msg.Peer.PostPolicy() {
+					t.Error("Expected LOCAL_PREF only on post-policy routes of an eBGP peer")
+				}
+			}
+		}
+	}
+	if localPrefs != 2 {
+		t.Errorf("Expected LOCAL_PREF on both post-policy paths, got %d", localPrefs)
+	}
+
+	// Withdrawing one path withdraws only that path at the collector
+	withdrawal := path(1)
+	withdrawal.Action = ra.Remove
+	sim.Inject(id, withdrawal)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	m.Sync()
+	for _, msg := range readAll(t, &buf) {
+		rm, ok := msg.(*RouteMonitoring)
+		if !ok {
+			t.Fatalf("Expected Route Monitoring only, got %T", msg)
+		}
+		u, err := codec.DecodeMessage(rm.Update)
+		if err != nil {
+			t.Fatalf("DecodeMessage() = %v", err)
+		}
+		if w := u.(*wire.Update).Withdrawn; len(w) != 1 || w[0].PathID != 1 {
+			t.Errorf("Expected path 1 withdrawn, got %+v", w)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package bmp
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+const (
+	bgpPort      = 179 // Simulated sessions have no transport, both ends are reported on the BGP port
+	openHoldTime = 90
+	sysDescr     = "bgpsim-go simulated router"
+)
+
+// addPathModes are the ADD-PATH modes of sessions reported with multiple paths
+var addPathModes = map[wire.AfiSafi]uint8{wire.IPv4Unicast: wire.AddPathBoth, wire.IPv6Unicast: wire.AddPathBoth}
+
+// pathKey identifies a route within an Adj-RIB-In
+type pathKey struct {
+	prefix netip.Prefix
+	pathID int
+}
+
+type adjRib map[pathKey]*route.BgpRoute
+
+// peerState is the Adj-RIB-In of a peer as last reported to the collector
+type peerState struct {
+	peer    PeerHeader // Identifies the peer once its link is gone
+	pre     adjRib
+	post    adjRib
+	addPath bool // ADD-PATH was negotiated in the reported OPENs, NLRI carry path IDs
+}
+
+// Monitor emits the BMP stream of one simulated node, as the router it stands for would send it to a collector.
+// Sessions of the node are reported as peers: Peer Up when a link appears, Peer Down when it goes away, and
+// Route Monitoring for every change of their Adj-RIB-In before and after import policy.
+// Sync must be called from the goroutine running the simulation, typically after each Run.
+type Monitor struct {
+	sim        *bgp.Simulation
+	node       *bgp.BgpNode
+	w          io.Writer
+	start      time.Time
+	tick       time.Duration
+	prePolicy  bool
+	postPolicy bool
+	stats      bool
+	sysName    string
+	sysDescr   string
+
+	peers   map[bgp.LinkID]*peerState
+	started bool
+	err     error
+}
+
+// Create new Monitor writing to w. Both pre- and post-policy routes are monitored and stats are reported on each Sync.
+func NewMonitor(sim *bgp.Simulation, node *bgp.BgpNode, w io.Writer, opts ...func(*Monitor)) *Monitor {
+	m := &Monitor{
+		sim:        sim,
+		node:       node,
+		w:          w,
+		start:      time.Unix(0, 0).UTC(),
+		tick:       time.Millisecond,
+		prePolicy:  true,
+		postPolicy: true,
+		stats:      true,
+		sysName:    node.Name(),
+		sysDescr:   sysDescr,
+		peers:      make(map[bgp.LinkID]*peerState),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Dial connects to a collector at addr and returns a Monitor streaming to it
+func Dial(ctx context.Context, addr string, sim *bgp.Simulation, node *bgp.BgpNode, opts ...func(*Monitor)) (*Monitor, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewMonitor(sim, node, conn, opts...), nil
+}
+
+// Options
+
+// WithClock maps logical clock ticks onto timestamps.
+// Under a timing model messages are stamped with the virtual time from start instead.
+func WithClock(start time.Time, tick time.Duration) func(*Monitor) {
+	return func(m *Monitor) {
+		m.start = start
+		m.tick = tick
+	}
+}
+
+// WithRouteMonitoring selects which Adj-RIB-In views are monitored
+func WithRouteMonitoring(pre, post bool) func(*Monitor) {
+	return func(m *Monitor) {
+		m.prePolicy = pre
+		m.postPolicy = post
+	}
+}
+
+// WithStats sets whether a Stats Report is sent for every peer on each Sync
+func WithStats(s bool) func(*Monitor) {
+	return func(m *Monitor) {
+		m.stats = s
+	}
+}
+
+// WithSysInfo sets the sysName and sysDescr sent in the Initiation message, sysName defaults to the node name
+func WithSysInfo(name, descr string) func(*Monitor) {
+	return func(m *Monitor) {
+		m.sysName = name
+		m.sysDescr = descr
+	}
+}
+
+// Sync reports every change of the node's sessions and Adj-RIBs-In since the last call.
+// The Initiation message is sent on the first call. Writing stops at the first error, which is returned.
+func (m *Monitor) Sync() error {
+	if !m.started {
+		m.started = true
+		m.write(&Initiation{Info: []Info{
+			{Type: InfoSysDescr, Value: []byte(m.sysDescr)},
+			{Type: InfoSysName, Value: []byte(m.sysName)},
+		}})
+	}
+
+	peers := make(map[bgp.LinkID]bgp.BgpPeer)
+	for _, p := range m.sim.Topology().GetPeers(m.node.Name()) {
+		if m.node.SessionUp(p.LocalIface) {
+			peers[p.ID()] = p
+		}
+	}
+	for _, id := range slices.SortedFunc(maps.Keys(m.peers), compareLinkID) {
+		if _, ok := peers[id]; !ok {
+			m.peerDown(id)
+		}
+	}
+	for _, id := range slices.SortedFunc(maps.Keys(peers), compareLinkID) {
+		p := peers[id]
+		state, ok := m.peers[id]
+		addPath := multiplePaths(p.LocalNode.AdjRibIn(p, true))
+		if ok && addPath && !state.addPath {
+			// Path IDs can only be reported once the OPENs announced ADD-PATH, the peer is reported again
+			m.peerDown(id)
+			ok = false
+		}
+		if !ok {
+			state = &peerState{peer: m.peerHeader(&p, false), pre: make(adjRib), post: make(adjRib), addPath: addPath}
+			m.peers[id] = state
+			m.peerUp(&p, addPath)
+		}
+		if m.prePolicy {
+			m.monitor(&p, state, state.pre, false)
+		}
+		if m.postPolicy {
+			m.monitor(&p, state, state.post, true)
+		}
+		if m.stats {
+			m.statsReport(&p, state)
+		}
+	}
+	return m.err
+}
+
+// Close sends a Termination message and closes the writer if it is an io.Closer
+func (m *Monitor) Close() error {
+	m.write(&Termination{Info: []Info{
+		{Type: TermReason, Value: []byte{0, TermAdminClose}},
+	}})
+	if c, ok := m.w.(io.Closer); ok {
+		if err := c.Close(); m.err == nil {
+			m.err = err
+		}
+	}
+	return m.err
+}
+
+// Err returns the first error encountered while writing
+func (m *Monitor) Err() error {
+	return m.err
+}
+
+func (m *Monitor) write(msg Message) {
+	if m.err != nil {
+		return
+	}
+	_, m.err = m.w.Write(EncodeMessage(msg))
+}
+
+// peerHeader identifies the remote end of a session
+func (m *Monitor) peerHeader(p *bgp.BgpPeer, postPolicy bool) PeerHeader {
+	h := PeerHeader{
+		PeerType:  PeerGlobal,
+		Addr:      p.RemotePrefix.Addr(),
+		Asn:       p.RemoteNode.Asn(),
+		BgpID:     p.RemoteNode.RouterID(),
+		Timestamp: m.now(),
+	}
+	if postPolicy {
+		h.Flags |= FlagPostPolicy
+	}
+	return h
+}
+
+// now maps the logical clock of the simulation onto a timestamp, or its virtual time under a timing model
+func (m *Monitor) now() time.Time {
+	if m.sim.Timed() {
+		return m.start.Add(m.sim.Now())
+	}
+	return m.start.Add(time.Duration(m.sim.Clock()) * m.tick)
+}
+
+func (m *Monitor) peerUp(p *bgp.BgpPeer, addPath bool) {
+	m.write(&PeerUp{
+		Peer:         m.peerHeader(p, false),
+		LocalAddr:    p.LocalPrefix.Addr(),
+		LocalPort:    bgpPort,
+		RemotePort:   bgpPort,
+		SentOpen:     encodeOpen(p.LocalNode, addPath),
+		ReceivedOpen: encodeOpen(p.RemoteNode, addPath),
+	})
+}
+
+// peerDown reports a link removed from the topology as closed by the local system
+func (m *Monitor) peerDown(id bgp.LinkID) {
+	h := m.peers[id].peer
+	h.Timestamp = m.now()
+	delete(m.peers, id)
+	m.write(&PeerDown{
+		Peer:   h,
+		Reason: DownLocalNoNotification,
+		Data:   []byte{0, 0},
+	})
+}
+
+// monitor sends Route Monitoring messages for the differences between an Adj-RIB-In view and its last report.
+// Post-policy routes carry the attributes set by import policy, including LOCAL_PREF.
+func (m *Monitor) monitor(p *bgp.BgpPeer, state *peerState, reported adjRib, postPolicy bool) {
+	current := make(adjRib)
+	for _, r := range p.LocalNode.AdjRibIn(*p, !postPolicy) {
+		current[pathKey{prefix: r.Prefix(), pathID: r.PathID()}] = r
+	}
+
+	var advs []ra.RouteAdv[*route.BgpRoute]
+	for _, k := range slices.SortedFunc(maps.Keys(reported), comparePathKey) {
+		if _, ok := current[k]; !ok {
+			advs = append(advs, ra.RouteAdv[*route.BgpRoute]{Route: reported[k], Action: ra.Remove})
+			delete(reported, k)
+		}
+	}
+	for _, k := range slices.SortedFunc(maps.Keys(current), comparePathKey) {
+		r := current[k]
+		if reported[k] != r {
+			advs = append(advs, ra.RouteAdv[*route.BgpRoute]{Route: r, Action: ra.Add})
+			reported[k] = r
+		}
+	}
+
+	localPref := postPolicy || p.LocalNode.Asn() == p.RemoteNode.Asn()
+	codec := wire.NewCodec()
+	if state.addPath {
+		codec = wire.NewCodec(wire.WithAddPath(addPathModes))
+	}
+	for _, adv := range advs {
+		update, err := codec.EncodeMessage(wire.NewUpdate(adv, localPref))
+		if err != nil {
+			if m.err == nil {
+				m.err = err
+			}
+			return
+		}
+		m.write(&RouteMonitoring{Peer: m.peerHeader(p, postPolicy), Update: update})
+	}
+}
+
+// statsReport sends the route counts of a peer: its Adj-RIB-In before policy and its routes selected in the Loc-RIB
+func (m *Monitor) statsReport(p *bgp.BgpPeer, state *peerState) {
+	from := p.RemotePrefix.Addr()
+	var selected uint64
+	for _, prefix := range p.LocalNode.LocRibPrefixes() {
+		rs, _ := p.LocalNode.LocRib(prefix)
+		rx := rs.BestPath().ReceivedFrom()
+		if rx.Type() == route.IP && rx.LinkLocalIP() == from {
+			selected++
+		}
+	}
+	pre := uint64(len(state.pre))
+	if !m.prePolicy {
+		pre = uint64(len(p.LocalNode.AdjRibIn(*p, true)))
+	}
+	m.write(&StatsReport{
+		Peer: m.peerHeader(p, false),
+		Stats: []Stat{
+			{Type: StatAdjRibIn, Value: pre},
+			{Type: StatLocRib, Value: selected},
+		},
+	})
+}
+
+// encodeOpen returns the OPEN message a node sends when bringing up a session
+func encodeOpen(n *bgp.BgpNode, addPath bool) []byte {
+	caps := wire.Capabilities{
+		Multiprotocol: []wire.AfiSafi{wire.IPv4Unicast, wire.IPv6Unicast},
+		RouteRefresh:  true,
+		AS4:           true,
+	}
+	if addPath {
+		caps.AddPath = addPathModes
+	}
+	b, _ := wire.NewCodec().EncodeMessage(&wire.Open{
+		Version:      4,
+		Asn:          n.Asn(),
+		HoldTime:     openHoldTime,
+		BgpID:        n.RouterID(),
+		Capabilities: caps,
+	})
+	return b
+}
+
+// multiplePaths reports whether routes hold several paths for a prefix
+func multiplePaths(routes []*route.BgpRoute) bool {
+	seen := make(map[netip.Prefix]struct{}, len(routes))
+	for _, r := range routes {
+		if _, ok := seen[r.Prefix()]; ok {
+			return true
+		}
+		seen[r.Prefix()] = struct{}{}
+	}
+	return false
+}
+
+func compareLinkID(a, b bgp.LinkID) int {
+	if c := cmp.Compare(a.Node, b.Node); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Iface, b.Iface)
+}
+
+func comparePathKey(a, b pathKey) int {
+	if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.prefix.Bits(), b.prefix.Bits()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.pathID, b.pathID)
+}
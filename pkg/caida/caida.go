@@ -0,0 +1,288 @@
+package caida
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/gaorexford"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+var (
+	ErrMalformed     = errors.New("malformed line")
+	ErrPoolExhausted = errors.New("link address pool exhausted")
+)
+
+// Relationship values of as-rel files
+const (
+	relProvider = "-1" // First AS is a provider of the second
+	relPeer     = "0"
+	relSibling  = "1"
+)
+
+var defaultLinkPool = netip.MustParsePrefix("100.64.0.0/10")
+
+// NodeName returns the name of the node representing an AS
+func NodeName(asn uint32) string {
+	return "AS" + strconv.FormatUint(uint64(asn), 10)
+}
+
+// Loader builds AS-level topologies from CAIDA datasets
+type Loader struct {
+	linkPool    netip.Prefix
+	nodeOptions []func(*bgp.BgpNode)
+	policies    bool
+}
+
+// Create new Loader
+func New(opts ...func(*Loader)) *Loader {
+	l := &Loader{
+		linkPool: defaultLinkPool,
+		policies: true,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Options
+
+// WithLinkPool sets the prefix link subnets are allocated from, /31 for IPv4 and /127 for IPv6
+func WithLinkPool(pool netip.Prefix) func(*Loader) {
+	return func(l *Loader) {
+		l.linkPool = pool.Masked()
+	}
+}
+
+// WithNodeOptions sets options applied to every created node
+func WithNodeOptions(opts ...func(*bgp.BgpNode)) func(*Loader) {
+	return func(l *Loader) {
+		l.nodeOptions = append(l.nodeOptions, opts...)
+	}
+}
+
+// WithPolicies toggles installing Gao-Rexford policies on every session
+func WithPolicies(p bool) func(*Loader) {
+	return func(l *Loader) {
+		l.policies = p
+	}
+}
+
+// ReadAsRel builds a topology from an as-rel or as-rel2 file, one node per AS.
+// Lines are "a|b|rel" with optional trailing fields, rel -1 meaning a is a provider of b,
+// 0 peers and 1 siblings. Comments and duplicate links are skipped.
+func (l *Loader) ReadAsRel(r io.Reader) (*bgp.BgpTopology, error) {
+	nodes := make(map[uint32]*bgp.BgpNode)
+	node := func(asn uint32) *bgp.BgpNode {
+		n, ok := nodes[asn]
+		if !ok {
+			n = bgp.NewBgpNode(NodeName(asn), asn, l.nodeOptions...)
+			nodes[asn] = n
+		}
+		return n
+	}
+
+	type pair struct{ a, b uint32 }
+	seen := make(map[pair]struct{})
+	builder := &bgp.BgpTopologyBuilder{}
+	alloc := newAllocator(l.linkPool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "|")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+		a, errA := parseAsn(fields[0])
+		b, errB := parseAsn(fields[1])
+		if errA != nil || errB != nil {
+			return nil, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+
+		var rel bgp.Relationship
+		switch fields[2] {
+		case relProvider:
+			rel = bgp.Customer
+		case relPeer:
+			rel = bgp.Peer
+		case relSibling:
+			rel = bgp.Sibling
+		default:
+			return nil, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+
+		if a == b {
+			continue
+		}
+		key := pair{min(a, b), max(a, b)}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		local, remote, ok := alloc.next()
+		if !ok {
+			return nil, ErrPoolExhausted
+		}
+		builder.AddPeer(bgp.BgpPeer{
+			LocalNode:    node(a),
+			RemoteNode:   node(b),
+			LocalPrefix:  local,
+			RemotePrefix: remote,
+			LocalIface:   NodeName(b),
+			RemoteIface:  NodeName(a),
+			Relationship: rel,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	t := builder.Build()
+	if l.policies {
+		gaorexford.Apply(t)
+	}
+	return t, nil
+}
+
+// LoadAsRel reads an as-rel file from disk, decompressing .bz2 and .gz files
+func (l *Loader) LoadAsRel(path string) (*bgp.BgpTopology, error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return l.ReadAsRel(f)
+}
+
+// ReadPfx2As originates prefixes from a routeviews prefix2as file at the nodes of their origin ASes.
+// Lines are "address<TAB>length<TAB>origins", origins separated by '_' (MOAS) or ',' (AS set).
+// Prefixes of ASes missing from the topology are skipped. Returns the number of originated routes.
+func ReadPfx2As(topology *bgp.BgpTopology, r io.Reader) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return count, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", line, ErrMalformed)
+		}
+
+		for _, s := range strings.FieldsFunc(fields[2], func(r rune) bool { return r == '_' || r == ',' }) {
+			asn, err := parseAsn(s)
+			if err != nil {
+				return count, fmt.Errorf("line %d: %w", line, ErrMalformed)
+			}
+			n, ok := topology.Node(NodeName(asn))
+			if !ok {
+				continue
+			}
+			n.Originate(route.New(route.WithPrefix(prefix)))
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+// LoadPfx2As reads a prefix2as file from disk, decompressing .bz2 and .gz files
+func LoadPfx2As(topology *bgp.BgpTopology, path string) (int, error) {
+	f, err := open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return ReadPfx2As(topology, f)
+}
+
+func parseAsn(s string) (uint32, error) {
+	asn, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+	return uint32(asn), err
+}
+
+// file is an opened, possibly compressed, dataset
+type file struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f *file) Close() error {
+	var errs []error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		errs = append(errs, f.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+func open(path string) (*file, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(path, ".bz2"):
+		return &file{Reader: bzip2.NewReader(f), closers: []io.Closer{f}}, nil
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &file{Reader: gz, closers: []io.Closer{f, gz}}, nil
+	}
+	return &file{Reader: f, closers: []io.Closer{f}}, nil
+}
+
+// allocator hands out point-to-point link subnets from a pool
+type allocator struct {
+	cursor netip.Addr
+	pool   netip.Prefix
+	bits   int
+}
+
+func newAllocator(pool netip.Prefix) *allocator {
+	return &allocator{
+		cursor: pool.Addr(),
+		pool:   pool,
+		bits:   pool.Addr().BitLen() - 1,
+	}
+}
+
+// next returns both ends of the next free subnet
+func (a *allocator) next() (netip.Prefix, netip.Prefix, bool) {
+	first := a.cursor
+	second := first.Next()
+	if !first.IsValid() || !second.IsValid() || !a.pool.Contains(second) {
+		return netip.Prefix{}, netip.Prefix{}, false
+	}
+	a.cursor = second.Next()
+	return netip.PrefixFrom(first, a.bits), netip.PrefixFrom(second, a.bits), true
+}
@@ -0,0 +1,106 @@
+package caida
+
+import (
+	"compress/gzip"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+)
+
+const asRel2 = `# source:topology|BGP
+# 1|2|-1 means AS1 is a provider of AS2
+1|2|-1|bgp
+1|3|-1|bgp
+2|3|0|bgp
+3|4|-1|bgp
+2|1|-1|bgp
+4|4|0|bgp
+`
+
+const pfx2as = `203.0.113.0	24	4
+198.51.100.0	24	2_3
+192.0.2.0	24	64999
+`
+
+func TestReadAsRel(t *testing.T) {
+	topo, err := New(WithLinkPool(netip.MustParsePrefix("100.64.0.0/29"))).ReadAsRel(strings.NewReader(asRel2))
+	if err != nil {
+		t.Fatalf("ReadAsRel() = %v", err)
+	}
+	if len(topo.Nodes()) != 4 || len(topo.Links()) != 4 {
+		t.Fatalf("Expected 4 nodes and 4 links, got %d and %d", len(topo.Nodes()), len(topo.Links()))
+	}
+
+	tests := []struct {
+		node, iface string
+		expected    bgp.Relationship
+	}{
+		{node: "AS1", iface: "AS2", expected: bgp.Customer},
+		{node: "AS2", iface: "AS1", expected: bgp.Provider}, // Duplicate 2|1|-1 is skipped
+		{node: "AS2", iface: "AS3", expected: bgp.Peer},
+		{node: "AS4", iface: "AS3", expected: bgp.Provider},
+	}
+	for _, tt := range tests {
+		p, ok := topo.GetPeerByIface(tt.node, tt.iface)
+		if !ok || p.Relationship != tt.expected {
+			t.Errorf("%s %s: expected %s, got %s", tt.node, tt.iface, tt.expected, p.Relationship)
+		}
+	}
+
+	n, err := ReadPfx2As(topo, strings.NewReader(pfx2as))
+	if err != nil || n != 3 {
+		t.Fatalf("ReadPfx2As() = %d, %v, expected 3 routes", n, err)
+	}
+	if err := bgp.NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	as1, _ := topo.Node("AS1")
+	rs, ok := as1.LocRib(netip.MustParsePrefix("203.0.113.0/24"))
+	if !ok {
+		t.Fatal("Expected AS1 to reach the prefix of AS4")
+	}
+	// Through its customer AS3 rather than its customer AS2 and the peering
+	if path := rs.BestPath().AsPath(); len(path) != 2 || path[0] != 3 {
+		t.Errorf("Expected AS path [3 4], got %v", path)
+	}
+}
+
+func TestReadAsRel_Errors(t *testing.T) {
+	if _, err := New().ReadAsRel(strings.NewReader("1|2\n")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed, got %v", err)
+	}
+	if _, err := New().ReadAsRel(strings.NewReader("1|2|-1\n1|3|7\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+	// A /30 pool holds a single link
+	_, err := New(WithLinkPool(netip.MustParsePrefix("100.64.0.0/30"))).ReadAsRel(strings.NewReader("1|2|-1\n1|3|-1\n3|4|0\n"))
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Expected ErrPoolExhausted, got %v", err)
+	}
+}
+
+func TestLoadAsRel_Gzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "as-rel2.txt.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(asRel2))
+	gz.Close()
+	f.Close()
+
+	topo, err := New(WithPolicies(false)).LoadAsRel(path)
+	if err != nil {
+		t.Fatalf("LoadAsRel() = %v", err)
+	}
+	if len(topo.Nodes()) != 4 {
+		t.Errorf("Expected 4 nodes, got %d", len(topo.Nodes()))
+	}
+}
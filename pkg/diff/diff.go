@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"cmp"
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+// PrefixDiff describes how one node's view of a prefix changed
+type PrefixDiff struct {
+	Node       string
+	Prefix     netip.Prefix
+	BestBefore *route.BgpRoute // nil if absent
+	BestAfter  *route.BgpRoute // nil if absent
+	FibBefore  []rib.Route
+	FibAfter   []rib.Route
+}
+
+// Unreachable reports whether the node lost all forwarding entries for the prefix
+func (d *PrefixDiff) Unreachable() bool {
+	return len(d.FibBefore) != 0 && len(d.FibAfter) == 0
+}
+
+// BestChanged reports whether the best path changed
+func (d *PrefixDiff) BestChanged() bool {
+	return routeHash(d.BestBefore) != routeHash(d.BestAfter)
+}
+
+// FibChanged reports whether the forwarding entries changed
+func (d *PrefixDiff) FibChanged() bool {
+	return !slices.Equal(fibHashes(d.FibBefore), fibHashes(d.FibAfter))
+}
+
+// Compare diffs the best paths and FIB entries of nodes present in both topologies.
+// Results are ordered by node, then prefix.
+func Compare(before, after *bgp.BgpTopology) []PrefixDiff {
+	var diffs []PrefixDiff
+	for _, nb := range before.Nodes() {
+		na, ok := after.Node(nb.Name())
+		if !ok {
+			continue
+		}
+		diffs = append(diffs, compareNode(nb, na)...)
+	}
+	return diffs
+}
+
+func compareNode(before, after *bgp.BgpNode) []PrefixDiff {
+	prefixes := make(map[netip.Prefix]struct{})
+	for _, n := range []*bgp.BgpNode{before, after} {
+		for _, p := range n.LocRibPrefixes() {
+			prefixes[p] = struct{}{}
+		}
+		for _, p := range n.Rib().Prefixes() {
+			prefixes[p] = struct{}{}
+		}
+	}
+
+	var diffs []PrefixDiff
+	for _, p := range slices.SortedFunc(maps.Keys(prefixes), comparePrefix) {
+		d := PrefixDiff{
+			Node:       before.Name(),
+			Prefix:     p,
+			BestBefore: bestPath(before, p),
+			BestAfter:  bestPath(after, p),
+			FibBefore:  before.Rib().GetActiveRoutesForPrefix(p),
+			FibAfter:   after.Rib().GetActiveRoutesForPrefix(p),
+		}
+		if d.BestChanged() || d.FibChanged() {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+func bestPath(n *bgp.BgpNode, prefix netip.Prefix) *route.BgpRoute {
+	rs, ok := n.LocRib(prefix)
+	if !ok {
+		return nil
+	}
+	return rs.BestPath()
+}
+
+func routeHash(r *route.BgpRoute) uint32 {
+	if r == nil {
+		return 0
+	}
+	return r.Hash()
+}
+
+// fibHashes returns the sorted hashes of FIB entries
+func fibHashes(routes []rib.Route) []uint32 {
+	hashes := make([]uint32, 0, len(routes))
+	for _, r := range routes {
+		hashes = append(hashes, r.Hash())
+	}
+	slices.Sort(hashes)
+	return hashes
+}
+
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Bits(), b.Bits())
+}
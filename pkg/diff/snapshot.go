@@ -0,0 +1,266 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+// Attribute is a compared property of a node's view of a prefix
+type Attribute int
+
+const (
+	BestPath        Attribute = iota // Neighbor the best path was learned from
+	Multipath                        // Neighbors of all selected paths
+	NextHop                          // Next hops of all selected paths
+	AsPath                           // AS path of the best path
+	LocalPreference                  // Attributes of the best path
+	Metric
+	Origin
+	Weight
+)
+
+var attributeNames = []string{"best-path", "multipath", "next-hop", "as-path", "local-pref", "metric", "origin", "weight"}
+
+func (a Attribute) String() string {
+	if a < 0 || int(a) >= len(attributeNames) {
+		return "unknown"
+	}
+	return attributeNames[a]
+}
+
+func (a Attribute) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Attribute) UnmarshalText(text []byte) error {
+	i := slices.Index(attributeNames, string(text))
+	if i < 0 {
+		return fmt.Errorf("unknown attribute %q", text)
+	}
+	*a = Attribute(i)
+	return nil
+}
+
+// Change is a difference in one attribute, values are empty when absent
+type Change struct {
+	Attribute Attribute `json:"attribute"`
+	Before    string    `json:"before"`
+	After     string    `json:"after"`
+}
+
+// RouteDiff lists the changes of one node's view of a prefix
+type RouteDiff struct {
+	Node    string       `json:"node"`
+	Prefix  netip.Prefix `json:"prefix"`
+	Changes []Change     `json:"changes"`
+}
+
+// Filter restricts a snapshot diff, empty fields match everything
+type Filter struct {
+	Nodes      []string
+	Prefixes   []netip.Prefix // Matches these prefixes and their more-specifics
+	Attributes []Attribute
+}
+
+// Options
+
+func WithNodes(nodes ...string) func(*Filter) {
+	return func(f *Filter) {
+		f.Nodes = append(f.Nodes, nodes...)
+	}
+}
+
+func WithPrefixes(prefixes ...netip.Prefix) func(*Filter) {
+	return func(f *Filter) {
+		f.Prefixes = append(f.Prefixes, prefixes...)
+	}
+}
+
+func WithAttributes(attributes ...Attribute) func(*Filter) {
+	return func(f *Filter) {
+		f.Attributes = append(f.Attributes, attributes...)
+	}
+}
+
+func (f *Filter) matchNode(name string) bool {
+	return len(f.Nodes) == 0 || slices.Contains(f.Nodes, name)
+}
+
+func (f *Filter) matchPrefix(prefix netip.Prefix) bool {
+	if len(f.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range f.Prefixes {
+		if p.Bits() <= prefix.Bits() && p.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) matchAttribute(a Attribute) bool {
+	return len(f.Attributes) == 0 || slices.Contains(f.Attributes, a)
+}
+
+// Snapshots diffs the Loc-RIBs of two converged simulations.
+// Nodes present in only one of them are compared against an empty Loc-RIB.
+// Results are ordered by node, then prefix.
+func Snapshots(before, after *bgp.Simulation, opts ...func(*Filter)) []RouteDiff {
+	f := &Filter{}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	names := make(map[string]struct{})
+	for _, s := range []*bgp.Simulation{before, after} {
+		for _, n := range s.Topology().Nodes() {
+			names[n.Name()] = struct{}{}
+		}
+	}
+
+	var diffs []RouteDiff
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if !f.matchNode(name) {
+			continue
+		}
+		nb, _ := before.Topology().Node(name)
+		na, _ := after.Topology().Node(name)
+		diffs = append(diffs, snapshotNode(name, nb, na, f)...)
+	}
+	return diffs
+}
+
+func snapshotNode(name string, before, after *bgp.BgpNode, f *Filter) []RouteDiff {
+	prefixes := make(map[netip.Prefix]struct{})
+	for _, n := range []*bgp.BgpNode{before, after} {
+		if n == nil {
+			continue
+		}
+		for _, p := range n.LocRibPrefixes() {
+			prefixes[p] = struct{}{}
+		}
+	}
+
+	var diffs []RouteDiff
+	for _, p := range slices.SortedFunc(maps.Keys(prefixes), comparePrefix) {
+		if !f.matchPrefix(p) {
+			continue
+		}
+		vb, va := describe(before, p), describe(after, p)
+		var changes []Change
+		for a := range vb {
+			if vb[a] == va[a] || !f.matchAttribute(Attribute(a)) {
+				continue
+			}
+			changes = append(changes, Change{Attribute: Attribute(a), Before: vb[a], After: va[a]})
+		}
+		if len(changes) != 0 {
+			diffs = append(diffs, RouteDiff{Node: name, Prefix: p, Changes: changes})
+		}
+	}
+	return diffs
+}
+
+// describe renders every attribute of a node's view of a prefix, indexed by Attribute
+func describe(n *bgp.BgpNode, prefix netip.Prefix) []string {
+	values := make([]string, len(attributeNames))
+	if n == nil {
+		return values
+	}
+	rs, ok := n.LocRib(prefix)
+	if !ok {
+		return values
+	}
+
+	best := rs.BestPath()
+	paths := rs.MultipathSet()
+	if len(paths) == 0 {
+		paths = []*route.BgpRoute{best}
+	}
+	var neighbors, nextHops []string
+	for _, r := range paths {
+		neighbors = append(neighbors, formatRxFrom(r.ReceivedFrom()))
+		nextHops = append(nextHops, formatNextHop(r.NextHop()))
+	}
+	slices.Sort(neighbors)
+	slices.Sort(nextHops)
+
+	asPath := make([]string, len(best.AsPath()))
+	for i, asn := range best.AsPath() {
+		asPath[i] = strconv.FormatUint(uint64(asn), 10)
+	}
+
+	values[BestPath] = formatRxFrom(best.ReceivedFrom())
+	values[Multipath] = strings.Join(neighbors, ",")
+	values[NextHop] = strings.Join(slices.Compact(nextHops), ",")
+	values[AsPath] = strings.Join(asPath, " ")
+	values[LocalPreference] = strconv.FormatUint(uint64(best.LocalPreference()), 10)
+	values[Metric] = strconv.Itoa(best.Metric())
+	values[Origin] = best.Origin().String()
+	values[Weight] = strconv.Itoa(best.Weight())
+	return values
+}
+
+func formatRxFrom(rx route.RxFrom) string {
+	switch rx.Type() {
+	case route.Local:
+		return "local"
+	case route.IP:
+		return rx.LinkLocalIP().String()
+	case route.Interface:
+		return rx.Iface()
+	}
+	return "unknown"
+}
+
+func formatNextHop(nh nexthop.NextHop) string {
+	switch nh.Type() {
+	case nexthop.IP:
+		return nh.IP().String()
+	case nexthop.Interface:
+		return nh.Iface()
+	case nexthop.Discard:
+		return "discard"
+	}
+	return "invalid"
+}
+
+// WriteTable writes diffs as an aligned text table, one row per change
+func WriteTable(w io.Writer, diffs []RouteDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tPREFIX\tATTRIBUTE\tBEFORE\tAFTER")
+	for _, d := range diffs {
+		for _, c := range d.Changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Node, d.Prefix, c.Attribute, orNone(c.Before), orNone(c.After))
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes diffs as an indented JSON array
+func WriteJSON(w io.Writer, diffs []RouteDiff) error {
+	if diffs == nil {
+		diffs = []RouteDiff{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diffs)
+}
+
+func orNone(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
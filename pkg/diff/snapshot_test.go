@@ -0,0 +1,140 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/optional"
+)
+
+var target = netip.MustParsePrefix("203.0.113.0/24")
+
+// Chain a - b - c, a originates 203.0.113.0/24 with the given network statement
+func makeChain(t *testing.T, nw bgp.Network) *bgp.Simulation {
+	a := bgp.NewBgpNode("a", 65001)
+	b := bgp.NewBgpNode("b", 65002)
+	c := bgp.NewBgpNode("c", 65003)
+
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    a,
+		RemoteNode:   b,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	})
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    b,
+		RemoteNode:   c,
+		LocalPrefix:  netip.MustParsePrefix("10.0.1.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.1.2/30"),
+		LocalIface:   "eth1",
+		RemoteIface:  "eth0",
+	})
+
+	a.AddStaticRoute(bgp.StaticRoute{Prefix: target, NextHop: nexthop.New(nexthop.WithDiscard())})
+	nw.Prefix = target
+	a.AddNetwork(nw)
+
+	sim := bgp.NewSimulation(builder.Build())
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	return sim
+}
+
+func TestSnapshots(t *testing.T) {
+	before := makeChain(t, bgp.Network{Origin: route.IGP})
+	after := makeChain(t, bgp.Network{Origin: route.Incomplete, Metric: optional.Of(50)})
+
+	diffs := Snapshots(before, after)
+	got := make(map[string][]Attribute)
+	for _, d := range diffs {
+		if d.Prefix != target {
+			t.Errorf("Unexpected diff for %s", d.Prefix)
+		}
+		for _, c := range d.Changes {
+			got[d.Node] = append(got[d.Node], c.Attribute)
+		}
+	}
+	// MED is not passed on by b
+	want := map[string][]Attribute{
+		"a": {Metric, Origin},
+		"b": {Metric, Origin},
+		"c": {Origin},
+	}
+	for node, attrs := range want {
+		if len(got[node]) != len(attrs) {
+			t.Errorf("Node %s: expected %v, got %v", node, attrs, got[node])
+			continue
+		}
+		for i := range attrs {
+			if got[node][i] != attrs[i] {
+				t.Errorf("Node %s: expected %v, got %v", node, attrs, got[node])
+			}
+		}
+	}
+
+	if len(Snapshots(before, before)) != 0 {
+		t.Error("Expected no differences between identical snapshots")
+	}
+}
+
+func TestSnapshots_Filter(t *testing.T) {
+	before := makeChain(t, bgp.Network{Origin: route.IGP})
+	after := makeChain(t, bgp.Network{Origin: route.Incomplete, Metric: optional.Of(50)})
+
+	diffs := Snapshots(before, after, WithAttributes(Metric))
+	if len(diffs) != 2 || diffs[0].Node != "a" || diffs[1].Node != "b" {
+		t.Errorf("Expected metric changes on a and b, got %+v", diffs)
+	}
+
+	diffs = Snapshots(before, after, WithNodes("c"), WithPrefixes(netip.MustParsePrefix("203.0.0.0/16")))
+	if len(diffs) != 1 || diffs[0].Node != "c" {
+		t.Errorf("Expected a single diff for c, got %+v", diffs)
+	}
+
+	if diffs := Snapshots(before, after, WithPrefixes(netip.MustParsePrefix("198.51.100.0/24"))); len(diffs) != 0 {
+		t.Errorf("Expected no diffs outside the filtered prefix, got %+v", diffs)
+	}
+}
+
+func TestSnapshots_Output(t *testing.T) {
+	before := makeChain(t, bgp.Network{Origin: route.IGP})
+	after := makeChain(t, bgp.Network{Origin: route.EGP})
+	diffs := Snapshots(before, after, WithNodes("a"))
+
+	var table bytes.Buffer
+	if err := WriteTable(&table, diffs); err != nil {
+		t.Fatalf("WriteTable() = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "NODE") {
+		t.Fatalf("Unexpected table:\n%s", table.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 5 || fields[2] != "origin" || fields[3] != "igp" || fields[4] != "egp" {
+		t.Errorf("Unexpected row %q", lines[1])
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, diffs); err != nil {
+		t.Fatalf("WriteJSON() = %v", err)
+	}
+	var decoded []RouteDiff
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Prefix != target || decoded[0].Changes[0] != diffs[0].Changes[0] {
+		t.Errorf("JSON round trip mismatch: %+v", decoded)
+	}
+	if !strings.Contains(out.String(), `"attribute": "origin"`) {
+		t.Errorf("Expected attribute names in JSON, got %s", out.String())
+	}
+}
@@ -0,0 +1,391 @@
+package gateway
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// State is a state of the BGP finite state machine
+type State int
+
+const (
+	Idle State = iota
+	Connect
+	Active
+	OpenSent
+	OpenConfirm
+	Established
+)
+
+func (s State) String() string {
+	switch s {
+	case Idle:
+		return "idle"
+	case Connect:
+		return "connect"
+	case Active:
+		return "active"
+	case OpenSent:
+		return "opensent"
+	case OpenConfirm:
+		return "openconfirm"
+	case Established:
+		return "established"
+	}
+	return "unknown"
+}
+
+const (
+	bgpVersion      = 4
+	openHoldTime    = 4 * time.Minute // Large hold time until OPEN is received
+	minHoldTime     = 3 * time.Second
+	keepaliveFactor = 3
+)
+
+// families are the address families offered to the peer
+var families = []wire.AfiSafi{wire.IPv4Unicast, wire.IPv6Unicast}
+
+type readResult struct {
+	msg []byte
+	err error
+}
+
+// session runs the state machine over a single connection
+type session struct {
+	gw      *Gateway
+	conn    net.Conn
+	codec   *wire.Codec
+	nextHop netip.Addr
+	sent    map[netip.Prefix]*route.BgpRoute // Adj-RIB-Out as sent to the peer, for route refresh
+	afis    map[wire.AfiSafi]struct{}        // Address families negotiated with the peer
+
+	reads     chan readResult
+	holdTime  time.Duration
+	hold      *time.Timer
+	keepalive *time.Ticker
+}
+
+func newSession(gw *Gateway, conn net.Conn) *session {
+	s := &session{
+		gw:    gw,
+		conn:  conn,
+		codec: wire.NewCodec(),
+		sent:  make(map[netip.Prefix]*route.BgpRoute),
+		reads: make(chan readResult),
+	}
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		s.nextHop = addr.AddrPort().Addr().Unmap()
+	}
+	return s
+}
+
+// reader delivers messages until the connection fails
+func (s *session) reader(done <-chan struct{}) {
+	for {
+		msg, err := wire.ReadMessage(s.conn)
+		select {
+		case s.reads <- readResult{msg: msg, err: err}:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *session) send(m wire.Message) error {
+	b, err := s.codec.EncodeMessage(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(b)
+	return err
+}
+
+// fail sends a NOTIFICATION and returns it as the error closing the session
+func (s *session) fail(code, subcode uint8) error {
+	n := &wire.Notification{Code: code, Subcode: subcode}
+	s.send(n)
+	return n
+}
+
+// decodeFailure maps an error decoding msg onto the NOTIFICATION reporting it, by message type and state
+func (s *session) decodeFailure(msg []byte, err error) error {
+	typ, _, herr := wire.DecodeHeader(msg)
+	switch {
+	case herr != nil:
+		return s.fail(wire.ErrCodeHeader, wire.ErrSubNotSynchronized)
+	case err == wire.ErrBadType:
+		return s.fail(wire.ErrCodeHeader, wire.ErrSubBadType)
+	}
+	switch typ {
+	case wire.MsgOpen:
+		if err == wire.ErrBadOptParam {
+			return s.fail(wire.ErrCodeOpen, wire.ErrSubUnsupportedOptParam)
+		}
+		return s.fail(wire.ErrCodeOpen, 0)
+	case wire.MsgUpdate:
+		// UPDATE messages are only expected once established
+		if s.gw.State() != Established {
+			return s.fail(wire.ErrCodeFsm, 0)
+		}
+		return s.fail(wire.ErrCodeUpdate, wire.ErrSubMalformedAttributes)
+	}
+	// NOTIFICATION, KEEPALIVE and ROUTE-REFRESH only fail on their length
+	return s.fail(wire.ErrCodeHeader, wire.ErrSubBadLength)
+}
+
+// receive waits for the next message, failing on hold timer expiry
+func (s *session) receive(wake <-chan struct{}) (wire.Message, bool, error) {
+	for {
+		select {
+		case r := <-s.reads:
+			if r.err == wire.ErrBadHeader {
+				return nil, false, s.fail(wire.ErrCodeHeader, wire.ErrSubBadLength)
+			}
+			if r.err != nil {
+				return nil, false, r.err
+			}
+			s.resetHold()
+			m, err := s.codec.DecodeMessage(r.msg)
+			if err != nil {
+				return nil, false, s.decodeFailure(r.msg, err)
+			}
+			if n, ok := m.(*wire.Notification); ok {
+				return nil, false, n
+			}
+			return m, false, nil
+		case <-s.holdC():
+			return nil, false, s.fail(wire.ErrCodeHoldTimer, 0)
+		case <-s.keepaliveC():
+			if err := s.send(&wire.Keepalive{}); err != nil {
+				return nil, false, err
+			}
+		case <-wake:
+			return nil, true, nil
+		case <-s.gw.done:
+			return nil, false, s.fail(wire.ErrCodeCease, wire.ErrSubAdminShutdown)
+		}
+	}
+}
+
+func (s *session) holdC() <-chan time.Time {
+	if s.hold == nil {
+		return nil
+	}
+	return s.hold.C
+}
+
+func (s *session) keepaliveC() <-chan time.Time {
+	if s.keepalive == nil {
+		return nil
+	}
+	return s.keepalive.C
+}
+
+func (s *session) resetHold() {
+	if s.hold != nil {
+		s.hold.Reset(s.holdTime)
+	}
+}
+
+func (s *session) stopTimers() {
+	if s.hold != nil {
+		s.hold.Stop()
+	}
+	if s.keepalive != nil {
+		s.keepalive.Stop()
+	}
+}
+
+// run drives the connection from OpenSent to Established and serves it until it closes
+func (s *session) run() error {
+	done := make(chan struct{})
+	defer close(done)
+	go s.reader(done)
+	defer s.stopTimers()
+
+	g := s.gw
+	open := &wire.Open{
+		Version:  bgpVersion,
+		Asn:      g.peer.LocalNode.Asn(),
+		HoldTime: uint16(g.holdTime / time.Second),
+		BgpID:    g.routerID,
+		Capabilities: wire.Capabilities{
+			Multiprotocol: families,
+			RouteRefresh:  true,
+			AS4:           true,
+		},
+	}
+	if err := s.send(open); err != nil {
+		return err
+	}
+	g.setState(OpenSent)
+	s.holdTime = openHoldTime
+	s.hold = time.NewTimer(s.holdTime)
+
+	m, _, err := s.receive(nil)
+	if err != nil {
+		return err
+	}
+	remote, ok := m.(*wire.Open)
+	if !ok {
+		return s.fail(wire.ErrCodeFsm, 0)
+	}
+	if err := s.negotiate(remote); err != nil {
+		return err
+	}
+	if err := s.send(&wire.Keepalive{}); err != nil {
+		return err
+	}
+	g.setState(OpenConfirm)
+
+	if m, _, err = s.receive(nil); err != nil {
+		return err
+	}
+	if _, ok := m.(*wire.Keepalive); !ok {
+		return s.fail(wire.ErrCodeFsm, 0)
+	}
+	g.up()
+	defer g.down()
+	return s.established()
+}
+
+// negotiate checks the OPEN of the peer and settles on hold time, AS number size and address families
+func (s *session) negotiate(remote *wire.Open) error {
+	g := s.gw
+	switch {
+	case remote.Version != bgpVersion:
+		return s.fail(wire.ErrCodeOpen, wire.ErrSubUnsupportedVersion)
+	case remote.Asn != g.peer.RemoteNode.Asn():
+		return s.fail(wire.ErrCodeOpen, wire.ErrSubBadPeerAS)
+	case !remote.BgpID.IsValid() || remote.BgpID.IsUnspecified():
+		return s.fail(wire.ErrCodeOpen, wire.ErrSubBadBgpID)
+	}
+	peerHold := time.Duration(remote.HoldTime) * time.Second
+	if peerHold != 0 && peerHold < minHoldTime {
+		return s.fail(wire.ErrCodeOpen, wire.ErrSubUnacceptableHoldTime)
+	}
+
+	s.holdTime = min(g.holdTime, peerHold)
+	if s.holdTime == 0 {
+		s.hold.Stop()
+		s.hold = nil
+	} else {
+		s.resetHold()
+		s.keepalive = time.NewTicker(s.holdTime / keepaliveFactor)
+	}
+	s.codec = wire.NewCodec(wire.WithAS4(remote.Capabilities.AS4))
+
+	// A peer without Multiprotocol capabilities only supports IPv4 unicast
+	offered := remote.Capabilities.Multiprotocol
+	if len(offered) == 0 {
+		offered = []wire.AfiSafi{wire.IPv4Unicast}
+	}
+	s.afis = make(map[wire.AfiSafi]struct{})
+	for _, f := range offered {
+		if slices.Contains(families, f) {
+			s.afis[f] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// negotiated reports whether the address family of a prefix was negotiated with the peer
+func (s *session) negotiated(prefix netip.Prefix) bool {
+	family := wire.IPv6Unicast
+	if prefix.Addr().Is4() {
+		family = wire.IPv4Unicast
+	}
+	_, ok := s.afis[family]
+	return ok
+}
+
+// established exchanges updates until the session closes
+func (s *session) established() error {
+	for {
+		m, woken, err := s.receive(s.gw.wake)
+		if err != nil {
+			return err
+		}
+		if woken {
+			if err := s.advertise(s.gw.pending()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch m := m.(type) {
+		case *wire.Update:
+			s.gw.received(s.imported(m.RouteAdvs()))
+		case *wire.RouteRefresh:
+			if err := s.refresh(m.AfiSafi); err != nil {
+				return err
+			}
+		case *wire.Keepalive:
+		default:
+			return s.fail(wire.ErrCodeFsm, 0)
+		}
+	}
+}
+
+// imported rewrites received next hops to the address of the external node, so they resolve in the simulation
+func (s *session) imported(advs []ra.RouteAdv[*route.BgpRoute]) []ra.RouteAdv[*route.BgpRoute] {
+	nh := nexthop.New(nexthop.WithIP(s.gw.peer.RemotePrefix.Addr()))
+	for i, adv := range advs {
+		if adv.Action == ra.Add {
+			advs[i].Route = adv.Route.Clone(route.WithNextHop(nh))
+		}
+	}
+	return advs
+}
+
+// advertise sends advertisements of the simulated node with the local address as next hop.
+// Advertisements of address families not negotiated with the peer are dropped.
+func (s *session) advertise(advs []ra.RouteAdv[*route.BgpRoute]) error {
+	ibgp := s.gw.peer.LocalNode.Asn() == s.gw.peer.RemoteNode.Asn()
+	for _, adv := range advs {
+		r := adv.Route
+		if !s.negotiated(r.Prefix()) {
+			continue
+		}
+		if adv.Action == ra.Add {
+			if s.nextHop.IsValid() && s.nextHop.Is4() == r.Prefix().Addr().Is4() {
+				r = r.Clone(route.WithNextHop(nexthop.New(nexthop.WithIP(s.nextHop))))
+			}
+			s.sent[r.Prefix()] = r
+		} else {
+			delete(s.sent, r.Prefix())
+		}
+		adv.Route = r
+		if err := s.send(wire.NewUpdate(adv, ibgp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh sends every route of a negotiated address family again
+func (s *session) refresh(family wire.AfiSafi) error {
+	if _, ok := s.afis[family]; !ok {
+		return nil
+	}
+	ibgp := s.gw.peer.LocalNode.Asn() == s.gw.peer.RemoteNode.Asn()
+	for _, r := range s.sent {
+		if r.Prefix().Addr().Is4() != (family == wire.IPv4Unicast) {
+			continue
+		}
+		if err := s.send(wire.NewUpdate(ra.RouteAdv[*route.BgpRoute]{Route: r, Action: ra.Add}, ibgp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+var (
+	ErrNotExternal = errors.New("remote node of the peer is not external")
+	ErrClosed      = errors.New("gateway closed")
+)
+
+const (
+	defaultHoldTime     = 90 * time.Second
+	defaultConnectRetry = 30 * time.Second
+)
+
+type eventKind int
+
+const (
+	sessionUp eventKind = iota
+	sessionDown
+	updates
+)
+
+type event struct {
+	kind eventKind
+	advs []ra.RouteAdv[*route.BgpRoute]
+}
+
+// Gateway connects a real BGP speaker to a simulated node over TCP.
+// The session maps onto a BgpPeer whose remote node is external and stands in for the real speaker:
+// the link is added to the simulation once the session is established and removed when it closes.
+// Sessions run on their own goroutines, Sync carries their effects into the simulation.
+type Gateway struct {
+	sim          *bgp.Simulation
+	peer         bgp.BgpPeer
+	routerID     netip.Addr
+	holdTime     time.Duration
+	connectRetry time.Duration
+	onState      func(State)
+
+	mu     sync.Mutex
+	state  State
+	events []event
+	out    []ra.RouteAdv[*route.BgpRoute] // Advertisements waiting to be sent
+	wake   chan struct{}
+	done   chan struct{}
+	closed bool
+
+	linked bool // Link is in the topology, only touched by Sync
+}
+
+// Create new Gateway. peer is seen from the simulated node, its remote node must be external.
+func New(sim *bgp.Simulation, peer bgp.BgpPeer, opts ...func(*Gateway)) (*Gateway, error) {
+	if !peer.RemoteNode.External() {
+		return nil, ErrNotExternal
+	}
+	g := &Gateway{
+		sim:          sim,
+		peer:         peer,
+		routerID:     peer.LocalNode.RouterID(),
+		holdTime:     defaultHoldTime,
+		connectRetry: defaultConnectRetry,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
+}
+
+// Options
+
+// WithHoldTime sets the proposed hold time, zero disables keepalives
+func WithHoldTime(d time.Duration) func(*Gateway) {
+	return func(g *Gateway) {
+		g.holdTime = d
+	}
+}
+
+// WithRouterID sets the BGP identifier sent in OPEN, defaults to the router ID of the simulated node
+func WithRouterID(id netip.Addr) func(*Gateway) {
+	return func(g *Gateway) {
+		g.routerID = id
+	}
+}
+
+// WithConnectRetry sets the delay between connection attempts of DialAndServe
+func WithConnectRetry(d time.Duration) func(*Gateway) {
+	return func(g *Gateway) {
+		g.connectRetry = d
+	}
+}
+
+// WithStateHook calls fn on every state transition, from the session goroutine
+func WithStateHook(fn func(State)) func(*Gateway) {
+	return func(g *Gateway) {
+		g.onState = fn
+	}
+}
+
+// State returns the current state of the session
+func (g *Gateway) State() State {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+func (g *Gateway) setState(s State) {
+	g.mu.Lock()
+	g.state = s
+	g.mu.Unlock()
+	if g.onState != nil {
+		g.onState(s)
+	}
+}
+
+// Serve runs the session over an established connection until it closes, then closes conn
+func (g *Gateway) Serve(conn net.Conn) error {
+	defer conn.Close()
+	defer g.setState(Idle)
+	return newSession(g, conn).run()
+}
+
+// ListenAndServe accepts connections from the peer on addr, serving one session at a time
+func (g *Gateway) ListenAndServe(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-g.done:
+		}
+		l.Close()
+	}()
+
+	for {
+		g.setState(Active)
+		conn, err := l.Accept()
+		if err != nil {
+			g.setState(Idle)
+			return g.stopped(ctx, err)
+		}
+		g.Serve(conn)
+	}
+}
+
+// DialAndServe connects to the peer at addr, reconnecting after the connect retry delay when a session closes
+func (g *Gateway) DialAndServe(ctx context.Context, addr string) error {
+	var d net.Dialer
+	for {
+		g.setState(Connect)
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			g.Serve(conn)
+		} else {
+			g.setState(Idle)
+		}
+
+		select {
+		case <-time.After(g.connectRetry):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.done:
+			return ErrClosed
+		}
+	}
+}
+
+func (g *Gateway) stopped(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	select {
+	case <-g.done:
+		return ErrClosed
+	default:
+	}
+	return err
+}
+
+// Close tears down the session with a Cease NOTIFICATION and stops serving
+func (g *Gateway) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.closed {
+		g.closed = true
+		close(g.done)
+	}
+}
+
+// Sync brings up or removes the link on session changes, injects received updates and queues the
+// advertisements of the simulated node for sending. It must be called from the goroutine running the
+// simulation, typically before and after each Run.
+func (g *Gateway) Sync() {
+	g.mu.Lock()
+	events := g.events
+	g.events = nil
+	g.mu.Unlock()
+
+	for _, ev := range events {
+		switch ev.kind {
+		case sessionUp:
+			if !g.linked {
+				g.sim.AddLink(g.peer)
+				g.linked = true
+			}
+		case sessionDown:
+			if g.linked {
+				g.sim.RemoveLink(g.peer.ID())
+				g.linked = false
+			}
+		case updates:
+			if g.linked {
+				g.sim.Inject(g.remoteID(), ev.advs...)
+			}
+		}
+	}
+	if !g.linked {
+		return
+	}
+
+	advs := g.sim.Drain(g.remoteID())
+	if len(advs) == 0 {
+		return
+	}
+	g.mu.Lock()
+	g.out = append(g.out, advs...)
+	g.mu.Unlock()
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remoteID identifies the external end of the link
+func (g *Gateway) remoteID() bgp.LinkID {
+	return bgp.LinkID{Node: g.peer.RemoteNode.Name(), Iface: g.peer.RemoteIface}
+}
+
+func (g *Gateway) post(ev event) {
+	g.mu.Lock()
+	g.events = append(g.events, ev)
+	g.mu.Unlock()
+}
+
+// up is called by the session once it reaches Established
+func (g *Gateway) up() {
+	g.mu.Lock()
+	g.out = nil
+	g.mu.Unlock()
+	g.post(event{kind: sessionUp})
+	g.setState(Established)
+}
+
+// down is called by the session when an established session ends
+func (g *Gateway) down() {
+	g.mu.Lock()
+	g.out = nil
+	g.mu.Unlock()
+	g.post(event{kind: sessionDown})
+}
+
+func (g *Gateway) received(advs []ra.RouteAdv[*route.BgpRoute]) {
+	if len(advs) != 0 {
+		g.post(event{kind: updates, advs: advs})
+	}
+}
+
+// pending takes the advertisements waiting to be sent
+func (g *Gateway) pending() []ra.RouteAdv[*route.BgpRoute] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := g.out
+	g.out = nil
+	return out
+}
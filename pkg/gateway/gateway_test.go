@@ -0,0 +1,261 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+var (
+	simPrefix  = netip.MustParsePrefix("203.0.113.0/24")
+	realPrefix = netip.MustParsePrefix("198.51.100.0/24")
+)
+
+// Simulated node s in AS 65001 originating 203.0.113.0/24, external node r in AS 65002
+func makeGateway(t *testing.T) (*bgp.Simulation, *bgp.BgpNode, *Gateway) {
+	s := bgp.NewBgpNode("s", 65001, bgp.WithRouterID(netip.MustParseAddr("192.0.2.1")))
+	r := bgp.NewBgpNode("r", 65002, bgp.WithExternal(true))
+	s.AddStaticRoute(bgp.StaticRoute{Prefix: simPrefix, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+	s.AddNetwork(bgp.Network{Prefix: simPrefix})
+
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddNode(s)
+	sim := bgp.NewSimulation(builder.Build())
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	gw, err := New(sim, bgp.BgpPeer{
+		LocalNode:    s,
+		RemoteNode:   r,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	}, WithHoldTime(9*time.Second))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return sim, s, gw
+}
+
+// router is the real speaker side of a test session
+type router struct {
+	t     *testing.T
+	conn  net.Conn
+	codec *wire.Codec
+}
+
+func (r *router) send(m wire.Message) {
+	b, err := r.codec.EncodeMessage(m)
+	if err != nil {
+		r.t.Fatalf("EncodeMessage() = %v", err)
+	}
+	if _, err := r.conn.Write(b); err != nil {
+		r.t.Fatalf("Write() = %v", err)
+	}
+}
+
+func (r *router) read() wire.Message {
+	r.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b, err := wire.ReadMessage(r.conn)
+	if err != nil {
+		r.t.Fatalf("ReadMessage() = %v", err)
+	}
+	m, err := r.codec.DecodeMessage(b)
+	if err != nil {
+		r.t.Fatalf("DecodeMessage() = %v", err)
+	}
+	return m
+}
+
+// connect opens a session to the gateway as a router in AS asn offering the given address families
+func connect(t *testing.T, gw *Gateway, asn uint32, families ...wire.AfiSafi) (*router, chan error) {
+	client, server := tcpPair(t)
+	errs := make(chan error, 1)
+	go func() { errs <- gw.Serve(server) }()
+
+	r := &router{t: t, conn: client, codec: wire.NewCodec()}
+	r.send(&wire.Open{
+		Version:      4,
+		Asn:          asn,
+		HoldTime:     30,
+		BgpID:        netip.MustParseAddr("192.0.2.2"),
+		Capabilities: wire.Capabilities{AS4: true, Multiprotocol: families, RouteRefresh: true},
+	})
+	open, ok := r.read().(*wire.Open)
+	if !ok || open.Asn != 65001 || open.HoldTime != 9 {
+		t.Fatalf("Unexpected OPEN %+v", open)
+	}
+	return r, errs
+}
+
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Listen() = %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+// converge syncs and runs the simulation until cond holds
+func converge(t *testing.T, sim *bgp.Simulation, gw *Gateway, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		gw.Sync()
+		if err := sim.Run(); err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+		gw.Sync()
+		if cond() {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGateway_Session(t *testing.T) {
+	sim, s, gw := makeGateway(t)
+	r, errs := connect(t, gw, 65002)
+	if _, ok := r.read().(*wire.Keepalive); !ok {
+		t.Fatal("Expected KEEPALIVE")
+	}
+	r.send(&wire.Keepalive{})
+	converge(t, sim, gw, func() bool { return gw.State() == Established })
+
+	// The simulated route is advertised with the gateway address as next hop
+	converge(t, sim, gw, func() bool { return true })
+	var u *wire.Update
+	for u == nil {
+		u, _ = r.read().(*wire.Update)
+	}
+	advs := u.RouteAdvs()
+	if len(advs) != 1 || advs[0].Route.Prefix() != simPrefix {
+		t.Fatalf("Unexpected advertisements %+v", advs)
+	}
+	if nh := advs[0].Route.NextHop(); nh.IP() != netip.MustParseAddr("127.0.0.1") {
+		t.Errorf("Next hop = %v, expected 127.0.0.1", nh.IP())
+	}
+
+	// A route from the router is imported
+	r.send(wire.NewUpdate(ra.RouteAdv[*route.BgpRoute]{Action: ra.Add, Route: route.New(
+		route.WithPrefix(realPrefix),
+		route.WithAsPath([]uint32{65002}),
+		route.WithNextHop(nexthop.New(nexthop.WithIP(netip.MustParseAddr("127.0.0.1")))),
+	)}, false))
+	converge(t, sim, gw, func() bool {
+		_, ok := s.LocRib(realPrefix)
+		return ok
+	})
+
+	// Closing the session withdraws it
+	r.send(&wire.Notification{Code: wire.ErrCodeCease, Subcode: wire.ErrSubAdminShutdown})
+	if err := <-errs; err == nil {
+		t.Error("Expected the NOTIFICATION as error")
+	}
+	converge(t, sim, gw, func() bool {
+		_, ok := s.LocRib(realPrefix)
+		return !ok
+	})
+	if gw.State() != Idle {
+		t.Errorf("State() = %v, expected idle", gw.State())
+	}
+}
+
+func TestGateway_Families(t *testing.T) {
+	sim, s, gw := makeGateway(t)
+	v6Prefix := netip.MustParsePrefix("2001:db8::/32")
+	s.AddStaticRoute(bgp.StaticRoute{Prefix: v6Prefix, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+	s.AddNetwork(bgp.Network{Prefix: v6Prefix})
+
+	r, _ := connect(t, gw, 65002, wire.IPv6Unicast)
+	r.read() // KEEPALIVE
+	r.send(&wire.Keepalive{})
+	converge(t, sim, gw, func() bool { return gw.State() == Established })
+	converge(t, sim, gw, func() bool { return true })
+
+	// Only IPv6 is advertised, a refresh of IPv4 is ignored
+	r.send(&wire.RouteRefresh{AfiSafi: wire.IPv4Unicast})
+	r.send(&wire.RouteRefresh{AfiSafi: wire.IPv6Unicast})
+	for updates := 0; updates < 2; {
+		u, ok := r.read().(*wire.Update)
+		if !ok {
+			continue
+		}
+		updates++
+		advs := u.RouteAdvs()
+		if len(advs) != 1 || advs[0].Route.Prefix() != v6Prefix {
+			t.Fatalf("Expected only %v advertised, got %+v", v6Prefix, advs)
+		}
+	}
+}
+
+func TestGateway_BadPeerAS(t *testing.T) {
+	_, _, gw := makeGateway(t)
+	r, errs := connect(t, gw, 65009)
+	n, ok := r.read().(*wire.Notification)
+	if !ok || n.Code != wire.ErrCodeOpen || n.Subcode != wire.ErrSubBadPeerAS {
+		t.Errorf("Expected bad peer AS NOTIFICATION, got %+v", n)
+	}
+	if err := <-errs; err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestGateway_TruncatedOpen(t *testing.T) {
+	_, _, gw := makeGateway(t)
+	client, server := tcpPair(t)
+	errs := make(chan error, 1)
+	go func() { errs <- gw.Serve(server) }()
+
+	// OPEN with only version and AS number
+	b := bytes.Repeat([]byte{0xff}, 16)
+	b = binary.BigEndian.AppendUint16(b, wire.HeaderLen+3)
+	b = append(b, wire.MsgOpen, 4, 0xfd, 0xea)
+	if _, err := client.Write(b); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	r := &router{t: t, conn: client, codec: wire.NewCodec()}
+	if _, ok := r.read().(*wire.Open); !ok {
+		t.Fatal("Expected OPEN")
+	}
+	n, ok := r.read().(*wire.Notification)
+	if !ok || n.Code != wire.ErrCodeOpen {
+		t.Errorf("Expected OPEN message error NOTIFICATION, got %+v", n)
+	}
+	if err := <-errs; err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestNew_NotExternal(t *testing.T) {
+	a := bgp.NewBgpNode("a", 65001)
+	b := bgp.NewBgpNode("b", 65002)
+	sim := bgp.NewSimulation((&bgp.BgpTopologyBuilder{}).Build())
+	if _, err := New(sim, bgp.BgpPeer{LocalNode: a, RemoteNode: b}); err != ErrNotExternal {
+		t.Errorf("Expected ErrNotExternal, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package mrt
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+)
+
+// BGP4MP and BGP4MP_ET subtypes
+const (
+	SubtypeStateChange            = 0
+	SubtypeMessage                = 1
+	SubtypeMessageAS4             = 4
+	SubtypeStateChangeAS4         = 5
+	SubtypeMessageLocal           = 6
+	SubtypeMessageAS4Local        = 7
+	SubtypeMessageAddPath         = 8
+	SubtypeMessageAS4AddPath      = 9
+	SubtypeMessageLocalAddPath    = 10
+	SubtypeMessageAS4LocalAddPath = 11
+)
+
+// StateEstablished is the BGP FSM state of an established session
+const StateEstablished = 6
+
+// addPathModes are the ADD-PATH modes of ADD-PATH messages, whose NLRI carry path identifiers in every family
+var addPathModes = map[wire.AfiSafi]uint8{
+	wire.IPv4Unicast: wire.AddPathReceive,
+	wire.IPv6Unicast: wire.AddPathReceive,
+	{Afi: wire.AfiIPv4, Safi: wire.SafiMulticast}: wire.AddPathReceive,
+	{Afi: wire.AfiIPv6, Safi: wire.SafiMulticast}: wire.AddPathReceive,
+}
+
+// Bgp4mpHeader identifies the session of a BGP4MP record
+type Bgp4mpHeader struct {
+	PeerAsn   uint32
+	LocalAsn  uint32
+	Iface     uint16
+	PeerAddr  netip.Addr
+	LocalAddr netip.Addr
+}
+
+// Peer returns the collector peer of the session
+func (h *Bgp4mpHeader) Peer() PeerEntry {
+	return PeerEntry{Addr: h.PeerAddr, Asn: h.PeerAsn}
+}
+
+// Bgp4mpMessage is a BGP message exchanged with a collector peer
+type Bgp4mpMessage struct {
+	Bgp4mpHeader
+	AS4     bool   // AS numbers in the message are 4 bytes
+	AddPath bool   // NLRI carry path identifiers
+	Local   bool   // Sent by the collector
+	Data    []byte // Complete message including its header
+}
+
+// Bgp4mpStateChange is a transition of the BGP FSM of a session
+type Bgp4mpStateChange struct {
+	Bgp4mpHeader
+	OldState uint16
+	NewState uint16
+}
+
+// IsMessage reports whether a BGP4MP subtype holds a BGP message
+func IsMessage(subtype uint16) bool {
+	switch subtype {
+	case SubtypeMessage, SubtypeMessageAS4, SubtypeMessageLocal, SubtypeMessageAS4Local,
+		SubtypeMessageAddPath, SubtypeMessageAS4AddPath, SubtypeMessageLocalAddPath, SubtypeMessageAS4LocalAddPath:
+		return true
+	}
+	return false
+}
+
+// IsStateChange reports whether a BGP4MP subtype holds a state change
+func IsStateChange(subtype uint16) bool {
+	return subtype == SubtypeStateChange || subtype == SubtypeStateChangeAS4
+}
+
+func decodeBgp4mpHeader(b []byte, as4 bool) (Bgp4mpHeader, []byte, error) {
+	var h Bgp4mpHeader
+	if as4 {
+		if len(b) < 8 {
+			return h, nil, ErrTruncated
+		}
+		h.PeerAsn, h.LocalAsn = binary.BigEndian.Uint32(b), binary.BigEndian.Uint32(b[4:])
+		b = b[8:]
+	} else {
+		if len(b) < 4 {
+			return h, nil, ErrTruncated
+		}
+		h.PeerAsn, h.LocalAsn = uint32(binary.BigEndian.Uint16(b)), uint32(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+	}
+	if len(b) < 4 {
+		return h, nil, ErrTruncated
+	}
+	h.Iface = binary.BigEndian.Uint16(b)
+	afi := binary.BigEndian.Uint16(b[2:])
+	b = b[4:]
+
+	switch afi {
+	case wire.AfiIPv4:
+		if len(b) < 8 {
+			return h, nil, ErrTruncated
+		}
+		h.PeerAddr, h.LocalAddr = netip.AddrFrom4([4]byte(b)), netip.AddrFrom4([4]byte(b[4:]))
+		b = b[8:]
+	case wire.AfiIPv6:
+		if len(b) < 32 {
+			return h, nil, ErrTruncated
+		}
+		h.PeerAddr, h.LocalAddr = netip.AddrFrom16([16]byte(b)), netip.AddrFrom16([16]byte(b[16:]))
+		b = b[32:]
+	default:
+		return h, nil, ErrUnsupported
+	}
+	return h, b, nil
+}
+
+// DecodeBgp4mpMessage decodes the data of a BGP4MP message record
+func DecodeBgp4mpMessage(subtype uint16, b []byte) (*Bgp4mpMessage, error) {
+	if !IsMessage(subtype) {
+		return nil, ErrUnsupported
+	}
+	m := &Bgp4mpMessage{}
+	switch subtype {
+	case SubtypeMessageAS4, SubtypeMessageAS4Local, SubtypeMessageAS4AddPath, SubtypeMessageAS4LocalAddPath:
+		m.AS4 = true
+	}
+	switch subtype {
+	case SubtypeMessageAddPath, SubtypeMessageAS4AddPath, SubtypeMessageLocalAddPath, SubtypeMessageAS4LocalAddPath:
+		m.AddPath = true
+	}
+	switch subtype {
+	case SubtypeMessageLocal, SubtypeMessageAS4Local, SubtypeMessageLocalAddPath, SubtypeMessageAS4LocalAddPath:
+		m.Local = true
+	}
+
+	var err error
+	if m.Bgp4mpHeader, m.Data, err = decodeBgp4mpHeader(b, m.AS4); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DecodeBgp4mpStateChange decodes the data of a BGP4MP state change record
+func DecodeBgp4mpStateChange(subtype uint16, b []byte) (*Bgp4mpStateChange, error) {
+	if !IsStateChange(subtype) {
+		return nil, ErrUnsupported
+	}
+	h, b, err := decodeBgp4mpHeader(b, subtype == SubtypeStateChangeAS4)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, ErrTruncated
+	}
+	return &Bgp4mpStateChange{
+		Bgp4mpHeader: h,
+		OldState:     binary.BigEndian.Uint16(b),
+		NewState:     binary.BigEndian.Uint16(b[2:]),
+	}, nil
+}
+
+// Update decodes the message as an UPDATE, returning nil for other message types
+func (m *Bgp4mpMessage) Update() (*wire.Update, error) {
+	typ, body, err := wire.DecodeHeader(m.Data)
+	if err != nil || typ != wire.MsgUpdate {
+		return nil, err
+	}
+	codec := wire.NewCodec(wire.WithAS4(m.AS4))
+	if m.AddPath {
+		codec = wire.NewCodec(wire.WithAS4(m.AS4), wire.WithAddPath(addPathModes))
+	}
+	return codec.DecodeUpdate(body)
+}
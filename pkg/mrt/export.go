@@ -0,0 +1,160 @@
+package mrt
+
+import (
+	"cmp"
+	"io"
+	"maps"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+// WriteRibs writes the Loc-RIB of every node as a TABLE_DUMP_V2 dump.
+// Each node is a collector peer, indexed in name order, contributing its best path per prefix as it
+// would advertise it over eBGP: prepended with its AS number and without LOCAL_PREF.
+func WriteRibs(w io.Writer, topology *bgp.BgpTopology, ts time.Time) error {
+	nodes := topology.Nodes()
+	table := &PeerIndexTable{CollectorID: netip.IPv4Unspecified()}
+	prefixes := make(map[netip.Prefix]struct{})
+	for _, n := range nodes {
+		table.Peers = append(table.Peers, PeerEntry{BgpID: n.RouterID(), Addr: n.RouterID(), Asn: n.Asn()})
+		for _, p := range n.LocRibPrefixes() {
+			prefixes[p] = struct{}{}
+		}
+	}
+
+	wr := NewWriter(w)
+	err := wr.Write(&Record{
+		Timestamp: ts,
+		Type:      TypeTableDumpV2,
+		Subtype:   SubtypePeerIndexTable,
+		Data:      EncodePeerIndexTable(table),
+	})
+	if err != nil {
+		return err
+	}
+
+	var seq uint32
+	for _, prefix := range slices.SortedFunc(maps.Keys(prefixes), comparePrefix) {
+		rib := &Rib{Sequence: seq, Prefix: prefix}
+		for i, n := range nodes {
+			rs, ok := n.LocRib(prefix)
+			if !ok {
+				continue
+			}
+			rib.Entries = append(rib.Entries, RibEntry{
+				PeerIndex:  uint16(i),
+				Originated: ts,
+				Attributes: wire.RouteAttributes(collectorView(n, rs.BestPath()), false),
+			})
+		}
+		subtype := RibSubtype(prefix, false)
+		err := wr.Write(&Record{
+			Timestamp: ts,
+			Type:      TypeTableDumpV2,
+			Subtype:   subtype,
+			Data:      EncodeRib(subtype, rib),
+		})
+		if err != nil {
+			return err
+		}
+		seq++
+	}
+	return nil
+}
+
+// collectorView returns a route as a node advertises it to a route collector
+func collectorView(n *bgp.BgpNode, r *route.BgpRoute) *route.BgpRoute {
+	opts := []func(*route.BgpRoute){
+		route.WithAsPath(append([]uint32{n.Asn()}, r.AsPath()...)),
+	}
+	if nh := r.NextHop(); !nh.IP().IsValid() {
+		opts = append(opts, route.WithNextHop(nexthop.New(nexthop.WithIP(n.RouterID()))))
+	}
+	return r.Clone(opts...)
+}
+
+// UpdateWriter writes the advertisements sent between nodes as BGP4MP_ET records.
+// Its Observe method is meant to be passed to bgp.WithObserver.
+type UpdateWriter struct {
+	w     *Writer
+	start time.Time
+	tick  time.Duration
+	err   error
+}
+
+// Create new UpdateWriter, logical time starts at the Unix epoch and advances a millisecond per tick
+func NewUpdateWriter(w io.Writer, opts ...func(*UpdateWriter)) *UpdateWriter {
+	uw := &UpdateWriter{
+		w:     NewWriter(w),
+		start: time.Unix(0, 0).UTC(),
+		tick:  time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(uw)
+	}
+	return uw
+}
+
+// Options
+
+// WithClock maps logical clock ticks onto timestamps.
+// Under a timing model advertisements are stamped with their virtual time from start instead.
+func WithClock(start time.Time, tick time.Duration) func(*UpdateWriter) {
+	return func(uw *UpdateWriter) {
+		uw.start = start
+		uw.tick = tick
+	}
+}
+
+// Observe writes an advertisement as a BGP4MP_ET MESSAGE_AS4 record from the sending node.
+// Writing stops at the first error, returned by Err.
+func (uw *UpdateWriter) Observe(a bgp.Advertisement) {
+	if uw.err != nil {
+		return
+	}
+	local, remote := a.Peer.LocalNode, a.Peer.RemoteNode
+	update := wire.NewUpdate(a.Adv, local.Asn() == remote.Asn())
+	msg, err := wire.NewCodec().EncodeMessage(update)
+	if err != nil {
+		uw.err = err
+		return
+	}
+	m := &Bgp4mpMessage{
+		Bgp4mpHeader: Bgp4mpHeader{
+			PeerAsn:   local.Asn(),
+			LocalAsn:  remote.Asn(),
+			PeerAddr:  a.Peer.LocalPrefix.Addr(),
+			LocalAddr: a.Peer.RemotePrefix.Addr(),
+		},
+		AS4:  true,
+		Data: msg,
+	}
+	ts := uw.start.Add(time.Duration(a.Clock) * uw.tick)
+	if a.Timed {
+		ts = uw.start.Add(a.Time)
+	}
+	uw.err = uw.w.Write(&Record{
+		Timestamp: ts,
+		Type:      TypeBgp4mpEt,
+		Subtype:   m.Subtype(),
+		Data:      EncodeBgp4mpMessage(m),
+	})
+}
+
+// Err returns the first error encountered while writing
+func (uw *UpdateWriter) Err() error {
+	return uw.err
+}
+
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Bits(), b.Bits())
+}
@@ -0,0 +1,167 @@
+package mrt
+
+import (
+	"bytes"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Chain a - b - c, c originates an IPv4 and an IPv6 prefix
+func makeChain(opts ...func(*bgp.Simulation)) *bgp.Simulation {
+	a := bgp.NewBgpNode("a", 65001, bgp.WithRouterID(netip.MustParseAddr("192.0.2.1")))
+	b := bgp.NewBgpNode("b", 65002, bgp.WithRouterID(netip.MustParseAddr("192.0.2.2")))
+	c := bgp.NewBgpNode("c", 65003, bgp.WithRouterID(netip.MustParseAddr("192.0.2.3")))
+	builder := &bgp.BgpTopologyBuilder{}
+	for i, pair := range [][2]*bgp.BgpNode{{a, b}, {b, c}} {
+		builder.AddPeer(bgp.BgpPeer{
+			LocalNode:    pair[0],
+			RemoteNode:   pair[1],
+			LocalPrefix:  netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 1}), 30),
+			RemotePrefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, byte(i), 2}), 30),
+			LocalIface:   "eth1",
+			RemoteIface:  "eth0",
+		})
+	}
+	for _, p := range []netip.Prefix{v4Prefix, v6Prefix} {
+		c.AddStaticRoute(bgp.StaticRoute{Prefix: p, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+		c.AddNetwork(bgp.Network{Prefix: p})
+	}
+	return bgp.NewSimulation(builder.Build(), opts...)
+}
+
+func TestWriteRibs(t *testing.T) {
+	sim := makeChain()
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteRibs(&buf, sim.Topology(), time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("WriteRibs() = %v", err)
+	}
+
+	rd := NewReader(&buf)
+	rec, err := rd.Next()
+	if err != nil {
+		t.Fatalf("Next() = %v", err)
+	}
+	table, err := DecodePeerIndexTable(rec.Data)
+	if err != nil {
+		t.Fatalf("DecodePeerIndexTable() = %v", err)
+	}
+	if len(table.Peers) != 3 || table.Peers[0].Asn != 65001 {
+		t.Fatalf("Unexpected peers %+v", table.Peers)
+	}
+
+	var prefixes []netip.Prefix
+	for {
+		rec, err := rd.Next()
+		if err != nil {
+			break
+		}
+		rib, err := DecodeRib(rec.Subtype, rec.Data)
+		if err != nil {
+			t.Fatalf("DecodeRib() = %v", err)
+		}
+		prefixes = append(prefixes, rib.Prefix)
+		if len(rib.Entries) != 3 {
+			t.Errorf("Expected 3 entries for %v, got %d", rib.Prefix, len(rib.Entries))
+			continue
+		}
+		want := []uint32{65001, 65002, 65003}
+		if got := rib.Entries[0].Attributes.FlatAsPath(); !slices.Equal(got, want) {
+			t.Errorf("AS path of a = %v, expected %v", got, want)
+		}
+	}
+	if !slices.Equal(prefixes, []netip.Prefix{v4Prefix, v6Prefix}) {
+		t.Errorf("Unexpected prefixes %v", prefixes)
+	}
+}
+
+func TestUpdateWriter(t *testing.T) {
+	var buf bytes.Buffer
+	uw := NewUpdateWriter(&buf)
+	sim := makeChain(bgp.WithObserver(uw.Observe))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	sim.RemoveLink(bgp.LinkID{Node: "c", Iface: "eth0"})
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if err := uw.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	// c announces both prefixes to b, b to a, then b withdraws both from a
+	var announced, withdrawn int
+	var last time.Time
+	rd := NewReader(&buf)
+	for {
+		rec, err := rd.Next()
+		if err != nil {
+			break
+		}
+		if rec.Type != TypeBgp4mpEt || rec.Timestamp.Before(last) {
+			t.Errorf("Unexpected record type %d at %v", rec.Type, rec.Timestamp)
+		}
+		last = rec.Timestamp
+		m, err := DecodeBgp4mpMessage(rec.Subtype, rec.Data)
+		if err != nil {
+			t.Fatalf("DecodeBgp4mpMessage() = %v", err)
+		}
+		u, err := m.Update()
+		if err != nil {
+			t.Fatalf("Update() = %v", err)
+		}
+		for _, adv := range u.RouteAdvs() {
+			if adv.Action == ra.Add {
+				announced++
+			} else {
+				withdrawn++
+			}
+		}
+	}
+	if announced != 4 || withdrawn != 2 {
+		t.Errorf("Expected 4 announcements and 2 withdrawals, got %d and %d", announced, withdrawn)
+	}
+}
+
+func TestUpdateWriter_Timed(t *testing.T) {
+	var buf bytes.Buffer
+	uw := NewUpdateWriter(&buf)
+	sim := makeChain(bgp.WithObserver(uw.Observe), bgp.WithTiming(bgp.Timing{Delay: 100 * time.Millisecond}))
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	// b relays the routes of c once they crossed the link
+	var last time.Time
+	rd := NewReader(&buf)
+	for {
+		rec, err := rd.Next()
+		if err != nil {
+			break
+		}
+		last = rec.Timestamp
+	}
+	if got := last.Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Errorf("Expected the last record stamped at the virtual time 100ms, got %v", got)
+	}
+}
+
+func TestBgp4mpMessage_Subtype(t *testing.T) {
+	m := &Bgp4mpMessage{AS4: true, Local: true, AddPath: true}
+	if m.Subtype() != SubtypeMessageAS4LocalAddPath {
+		t.Errorf("Subtype() = %d, expected %d", m.Subtype(), SubtypeMessageAS4LocalAddPath)
+	}
+	m = &Bgp4mpMessage{AS4: true}
+	if m.Subtype() != SubtypeMessageAS4 {
+		t.Errorf("Subtype() = %d, expected %d", m.Subtype(), SubtypeMessageAS4)
+	}
+}
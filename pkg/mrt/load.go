@@ -0,0 +1,147 @@
+package mrt
+
+import (
+	"io"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// FeedName returns the name of the node feed carrying a collector peer's routes
+func FeedName(peer PeerEntry) string {
+	return "mrt/" + peer.Addr.String()
+}
+
+// RibLoader loads TABLE_DUMP_V2 unicast RIBs into the Adj-RIB-In of a node, one feed per collector peer.
+// Records are decoded one at a time, so only the queued routes are kept in memory.
+type RibLoader struct {
+	node       *bgp.BgpNode
+	peerFilter func(PeerEntry) bool
+	flushEvery int
+	flush      func() error
+}
+
+// Create new RibLoader
+func NewRibLoader(node *bgp.BgpNode, opts ...func(*RibLoader)) *RibLoader {
+	l := &RibLoader{
+		node: node,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Options
+
+// WithPeerFilter only loads routes of collector peers accepted by filter
+func WithPeerFilter(filter func(PeerEntry) bool) func(*RibLoader) {
+	return func(l *RibLoader) {
+		l.peerFilter = filter
+	}
+}
+
+// WithFlush calls flush after every n RIB records, typically running the simulation to drain the queue
+func WithFlush(n int, flush func() error) func(*RibLoader) {
+	return func(l *RibLoader) {
+		l.flushEvery = n
+		l.flush = flush
+	}
+}
+
+// Load reads an MRT stream, feeding every unicast RIB entry to the node.
+// Records of other types are skipped. Returns the number of routes fed.
+func (l *RibLoader) Load(r io.Reader) (int, error) {
+	rd := NewReader(r)
+	var peers []PeerEntry
+	var feeds []string // Per peer index, empty if filtered out
+	count, records := 0, 0
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if rec.Type != TypeTableDumpV2 {
+			continue
+		}
+
+		if rec.Subtype == SubtypePeerIndexTable {
+			t, err := DecodePeerIndexTable(rec.Data)
+			if err != nil {
+				return count, err
+			}
+			peers = t.Peers
+			feeds = make([]string, len(peers))
+			continue
+		}
+		if !IsRib(rec.Subtype) {
+			continue
+		}
+		if peers == nil {
+			return count, ErrNoPeerIndex
+		}
+
+		rib, err := DecodeRib(rec.Subtype, rec.Data)
+		if err == ErrUnsupported {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		if rib.Safi != wire.SafiUnicast {
+			continue
+		}
+		advs := make(map[string][]ra.RouteAdv[*route.BgpRoute])
+		var order []string
+		for _, e := range rib.Entries {
+			if int(e.PeerIndex) >= len(peers) {
+				return count, ErrBadPeer
+			}
+			feed := l.feed(peers, feeds, int(e.PeerIndex))
+			if feed == "" {
+				continue
+			}
+			if _, ok := advs[feed]; !ok {
+				order = append(order, feed)
+			}
+			advs[feed] = append(advs[feed], ra.RouteAdv[*route.BgpRoute]{
+				Route:  e.Attributes.Route(rib.Prefix, e.PathID),
+				Action: ra.Add,
+			})
+		}
+		for _, feed := range order {
+			l.node.Feed(feed, advs[feed]...)
+			count += len(advs[feed])
+		}
+
+		records++
+		if l.flush != nil && l.flushEvery > 0 && records%l.flushEvery == 0 {
+			if err := l.flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if l.flush != nil {
+		return count, l.flush()
+	}
+	return count, nil
+}
+
+// feed returns the feed of a peer, registering it on first use, or an empty string if filtered out
+func (l *RibLoader) feed(peers []PeerEntry, feeds []string, i int) string {
+	if feeds[i] != "" {
+		return feeds[i]
+	}
+	p := peers[i]
+	if l.peerFilter != nil && !l.peerFilter(p) {
+		return ""
+	}
+	feeds[i] = FeedName(p)
+	l.node.AddFeed(feeds[i], p.Asn)
+	return feeds[i]
+}
@@ -0,0 +1,97 @@
+package mrt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrTruncated   = errors.New("truncated record")
+	ErrNoPeerIndex = errors.New("RIB record before PEER_INDEX_TABLE")
+	ErrBadPeer     = errors.New("peer index out of range")
+)
+
+// Record types
+const (
+	TypeTableDumpV2 = 13
+	TypeBgp4mp      = 16
+	TypeBgp4mpEt    = 17
+)
+
+// TABLE_DUMP_V2 subtypes
+const (
+	SubtypePeerIndexTable          = 1
+	SubtypeRibIPv4Unicast          = 2
+	SubtypeRibIPv4Multicast        = 3
+	SubtypeRibIPv6Unicast          = 4
+	SubtypeRibIPv6Multicast        = 5
+	SubtypeRibGeneric              = 6
+	SubtypeRibIPv4UnicastAddPath   = 8
+	SubtypeRibIPv4MulticastAddPath = 9
+	SubtypeRibIPv6UnicastAddPath   = 10
+	SubtypeRibIPv6MulticastAddPath = 11
+	SubtypeRibGenericAddPath       = 12
+)
+
+const (
+	commonHeaderLen = 12
+	maxRecordLen    = 1 << 24 // Guards against corrupt length fields
+)
+
+// Record is a single MRT record
+type Record struct {
+	Timestamp time.Time // Includes microseconds for extended timestamp types
+	Type      uint16
+	Subtype   uint16
+	Data      []byte
+}
+
+// Reader reads MRT records one at a time from a stream
+type Reader struct {
+	r      *bufio.Reader
+	header [commonHeaderLen]byte
+}
+
+// Create new Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next record, or io.EOF at the end of the stream
+func (rd *Reader) Next() (*Record, error) {
+	if _, err := io.ReadFull(rd.r, rd.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+	rec := &Record{
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint32(rd.header[0:])), 0).UTC(),
+		Type:      binary.BigEndian.Uint16(rd.header[4:]),
+		Subtype:   binary.BigEndian.Uint16(rd.header[6:]),
+	}
+	length := binary.BigEndian.Uint32(rd.header[8:])
+	if length > maxRecordLen {
+		return nil, ErrTruncated
+	}
+	rec.Data = make([]byte, length)
+	if _, err := io.ReadFull(rd.r, rec.Data); err != nil {
+		return nil, ErrTruncated
+	}
+
+	// Extended timestamps carry microseconds in front of the message, counted in the length
+	if rec.Type == TypeBgp4mpEt {
+		if len(rec.Data) < 4 {
+			return nil, ErrTruncated
+		}
+		us := binary.BigEndian.Uint32(rec.Data)
+		rec.Timestamp = rec.Timestamp.Add(time.Duration(us) * time.Microsecond)
+		rec.Data = rec.Data[4:]
+	}
+	return rec, nil
+}
@@ -0,0 +1,207 @@
+package mrt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+)
+
+var (
+	v4Prefix = netip.MustParsePrefix("198.51.100.0/24")
+	v6Prefix = netip.MustParsePrefix("2001:db8:1::/48")
+)
+
+func record(typ, subtype uint16, data []byte) []byte {
+	return recordAt(1700000000, typ, subtype, data)
+}
+
+func recordAt(ts uint32, typ, subtype uint16, data []byte) []byte {
+	b := make([]byte, commonHeaderLen, commonHeaderLen+len(data))
+	binary.BigEndian.PutUint32(b, ts)
+	binary.BigEndian.PutUint16(b[4:], typ)
+	binary.BigEndian.PutUint16(b[6:], subtype)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(data)))
+	return append(b, data...)
+}
+
+// Two peers: 192.0.2.1 in AS 64500 and 2001:db8::1 in AS 4200000000
+func peerIndex() []byte {
+	b := []byte{192, 0, 2, 254, 0, 0, 0, 2}
+	b = append(b, 0x02, 192, 0, 2, 1, 192, 0, 2, 1, 0, 0, 0xfb, 0xf4)
+	b = append(b, 0x03, 192, 0, 2, 2)
+	b = append(b, netip.MustParseAddr("2001:db8::1").AsSlice()...)
+	return binary.BigEndian.AppendUint32(b, 4200000000)
+}
+
+func attr(flags, typ uint8, value []byte) []byte {
+	return append([]byte{flags, typ, uint8(len(value))}, value...)
+}
+
+func asPath(asns ...uint32) []byte {
+	b := []byte{uint8(wire.AsSequence), uint8(len(asns))}
+	for _, asn := range asns {
+		b = binary.BigEndian.AppendUint32(b, asn)
+	}
+	return attr(wire.FlagTransitive, wire.AttrAsPath, b)
+}
+
+func ribEntry(peer uint16, pathID uint32, addPath bool, attrs []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, peer)
+	b = binary.BigEndian.AppendUint32(b, 1700000000)
+	if addPath {
+		b = binary.BigEndian.AppendUint32(b, pathID)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	return append(b, attrs...)
+}
+
+func rib(prefix netip.Prefix, entries ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 0)
+	b = append(b, uint8(prefix.Bits()))
+	b = append(b, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(entries)))
+	for _, e := range entries {
+		b = append(b, e...)
+	}
+	return b
+}
+
+func dump() []byte {
+	v4Attrs := append(attr(wire.FlagTransitive, wire.AttrOrigin, []byte{0}), asPath(64500, 64496)...)
+	v4Attrs = append(v4Attrs, attr(wire.FlagTransitive, wire.AttrNextHop, []byte{192, 0, 2, 1})...)
+
+	// RIB records carry the short MP_REACH_NLRI form, next hop only
+	mp := append([]byte{16}, netip.MustParseAddr("2001:db8::1").AsSlice()...)
+	v6Attrs := append(attr(wire.FlagTransitive, wire.AttrOrigin, []byte{0}), asPath(4200000000, 64497)...)
+	v6Attrs = append(v6Attrs, attr(wire.FlagOptional, wire.AttrMpReach, mp)...)
+
+	var b []byte
+	b = append(b, record(TypeTableDumpV2, SubtypePeerIndexTable, peerIndex())...)
+	b = append(b, record(TypeTableDumpV2, SubtypeRibIPv4Unicast, rib(v4Prefix, ribEntry(0, 0, false, v4Attrs)))...)
+	b = append(b, record(TypeTableDumpV2, SubtypeRibIPv6UnicastAddPath, rib(v6Prefix,
+		ribEntry(1, 7, true, v6Attrs),
+	))...)
+	return b
+}
+
+func TestReader_Next(t *testing.T) {
+	rd := NewReader(bytes.NewReader(dump()))
+	rec, err := rd.Next()
+	if err != nil {
+		t.Fatalf("Next() = %v", err)
+	}
+	if rec.Type != TypeTableDumpV2 || rec.Subtype != SubtypePeerIndexTable {
+		t.Fatalf("Expected PEER_INDEX_TABLE, got %d/%d", rec.Type, rec.Subtype)
+	}
+	table, err := DecodePeerIndexTable(rec.Data)
+	if err != nil {
+		t.Fatalf("DecodePeerIndexTable() = %v", err)
+	}
+	want := []PeerEntry{
+		{BgpID: netip.MustParseAddr("192.0.2.1"), Addr: netip.MustParseAddr("192.0.2.1"), Asn: 64500},
+		{BgpID: netip.MustParseAddr("192.0.2.2"), Addr: netip.MustParseAddr("2001:db8::1"), Asn: 4200000000},
+	}
+	if len(table.Peers) != len(want) {
+		t.Fatalf("Expected %d peers, got %d", len(want), len(table.Peers))
+	}
+	for i := range want {
+		if table.Peers[i] != want[i] {
+			t.Errorf("Peer %d = %+v, expected %+v", i, table.Peers[i], want[i])
+		}
+	}
+
+	rd = NewReader(bytes.NewReader(dump()[:20]))
+	if _, err := rd.Next(); err != ErrTruncated {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+}
+
+// Node a peers with b, the dump is loaded into a
+func makeTopology() (*bgp.BgpNode, *bgp.BgpNode, *bgp.Simulation) {
+	a := bgp.NewBgpNode("a", 65001)
+	b := bgp.NewBgpNode("b", 65002)
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    a,
+		RemoteNode:   b,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	})
+	return a, b, bgp.NewSimulation(builder.Build())
+}
+
+func TestRibLoader_Load(t *testing.T) {
+	a, b, sim := makeTopology()
+	n, err := NewRibLoader(a, WithFlush(1, sim.Run)).Load(bytes.NewReader(dump()))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 routes loaded, got %d", n)
+	}
+	if len(a.Feeds()) != 2 {
+		t.Errorf("Expected 2 feeds, got %v", a.Feeds())
+	}
+
+	rs, ok := a.LocRib(v4Prefix)
+	if !ok {
+		t.Fatalf("Expected %v selected at a", v4Prefix)
+	}
+	if got := rs.BestPath().AsPath(); len(got) != 2 || got[0] != 64500 {
+		t.Errorf("Unexpected AS path %v", got)
+	}
+
+	rs, ok = a.LocRib(v6Prefix)
+	if !ok {
+		t.Fatalf("Expected %v selected at a", v6Prefix)
+	}
+	best := rs.BestPath()
+	if best.PathID() != 7 {
+		t.Errorf("Expected path ID 7, got %d", best.PathID())
+	}
+	if nh := best.NextHop(); nh.IP() != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("Unexpected next hop %v", nh.IP())
+	}
+
+	rs, ok = b.LocRib(v4Prefix)
+	if !ok {
+		t.Fatalf("Expected %v propagated to b", v4Prefix)
+	}
+	if got := rs.BestPath().AsPath(); len(got) != 3 || got[0] != 65001 {
+		t.Errorf("Unexpected AS path at b %v", got)
+	}
+}
+
+func TestRibLoader_PeerFilter(t *testing.T) {
+	a, _, sim := makeTopology()
+	filter := func(p PeerEntry) bool { return p.Addr.Is4() }
+	n, err := NewRibLoader(a, WithPeerFilter(filter), WithFlush(0, sim.Run)).Load(bytes.NewReader(dump()))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 route loaded, got %d", n)
+	}
+	if _, ok := a.LocRib(v6Prefix); ok {
+		t.Errorf("Expected %v filtered out", v6Prefix)
+	}
+	if _, ok := a.LocRib(v4Prefix); !ok {
+		t.Errorf("Expected %v selected", v4Prefix)
+	}
+}
+
+func TestRibLoader_NoPeerIndex(t *testing.T) {
+	a, _, _ := makeTopology()
+	data := dump()
+	// Skip the PEER_INDEX_TABLE record
+	skip := commonHeaderLen + int(binary.BigEndian.Uint32(data[8:]))
+	if _, err := NewRibLoader(a).Load(bytes.NewReader(data[skip:])); err != ErrNoPeerIndex {
+		t.Errorf("Expected ErrNoPeerIndex, got %v", err)
+	}
+}
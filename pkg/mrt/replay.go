@@ -0,0 +1,205 @@
+package mrt
+
+import (
+	"io"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/ra"
+)
+
+// Replayer replays BGP4MP update streams into a node, one feed per collector peer.
+// Updates are fed in record order and grouped into batches by timestamp, the simulation runs to
+// convergence after every batch so fed routes arrive on the logical clock in their recorded order.
+// The simulated clock follows the batch timestamps, so time-based state such as flap dampening
+// penalties decays as recorded.
+type Replayer struct {
+	sim        *bgp.Simulation
+	node       *bgp.BgpNode
+	peerFilter func(PeerEntry) bool
+	interval   time.Duration
+	onBatch    func(time.Time) error
+
+	start time.Time     // Timestamp of the first batch
+	base  time.Duration // Simulated time of the first batch
+}
+
+// ReplayStats counts what a replay fed into the node
+type ReplayStats struct {
+	Updates      int
+	Announced    int
+	Withdrawn    int
+	SessionsUp   int
+	SessionsDown int
+	Batches      int
+	Malformed    int // Records that failed to decode, skipped
+}
+
+// Create new Replayer
+func NewReplayer(sim *bgp.Simulation, node *bgp.BgpNode, opts ...func(*Replayer)) *Replayer {
+	r := &Replayer{
+		sim:  sim,
+		node: node,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Options
+
+// WithReplayFilter only replays updates of collector peers accepted by filter
+func WithReplayFilter(filter func(PeerEntry) bool) func(*Replayer) {
+	return func(r *Replayer) {
+		r.peerFilter = filter
+	}
+}
+
+// WithInterval batches updates received within interval of the first one, zero batches by timestamp
+func WithInterval(interval time.Duration) func(*Replayer) {
+	return func(r *Replayer) {
+		r.interval = interval
+	}
+}
+
+// WithBatchHook calls fn with the timestamp of each batch once the simulation has converged
+func WithBatchHook(fn func(time.Time) error) func(*Replayer) {
+	return func(r *Replayer) {
+		r.onBatch = fn
+	}
+}
+
+// Replay reads an MRT stream, feeding every received unicast UPDATE to the node.
+// A session leaving the Established state removes its feed, withdrawing its routes.
+// Messages sent by the collector, records of other types and malformed records are skipped.
+func (r *Replayer) Replay(rd io.Reader) (ReplayStats, error) {
+	var stats ReplayStats
+	mr := NewReader(rd)
+	var batch time.Time
+	pending := false
+	for {
+		rec, err := mr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		if rec.Type != TypeBgp4mp && rec.Type != TypeBgp4mpEt {
+			continue
+		}
+
+		if pending && r.closes(batch, rec.Timestamp) {
+			if err := r.flush(batch, &stats); err != nil {
+				return stats, err
+			}
+			pending = false
+		}
+		if !pending {
+			batch = rec.Timestamp
+		}
+
+		var worked bool
+		switch {
+		case IsStateChange(rec.Subtype):
+			worked, err = r.stateChange(rec, &stats)
+		case IsMessage(rec.Subtype):
+			worked, err = r.message(rec, &stats)
+		}
+		if err == ErrUnsupported {
+			continue
+		}
+		if err != nil {
+			stats.Malformed++
+			continue
+		}
+		pending = pending || worked
+	}
+	if pending {
+		return stats, r.flush(batch, &stats)
+	}
+	return stats, nil
+}
+
+// closes reports whether a record at t falls outside the batch started at start
+func (r *Replayer) closes(start, t time.Time) bool {
+	if r.interval == 0 {
+		return !t.Equal(start)
+	}
+	return t.Sub(start) >= r.interval
+}
+
+func (r *Replayer) flush(batch time.Time, stats *ReplayStats) error {
+	stats.Batches++
+	if r.start.IsZero() {
+		r.start, r.base = batch, r.sim.Now()
+	}
+	r.sim.AdvanceTo(r.base + batch.Sub(r.start))
+	if err := r.sim.Run(); err != nil {
+		return err
+	}
+	if r.onBatch != nil {
+		return r.onBatch(batch)
+	}
+	return nil
+}
+
+func (r *Replayer) stateChange(rec *Record, stats *ReplayStats) (bool, error) {
+	sc, err := DecodeBgp4mpStateChange(rec.Subtype, rec.Data)
+	if err != nil {
+		return false, err
+	}
+	peer := sc.Peer()
+	if r.peerFilter != nil && !r.peerFilter(peer) {
+		return false, nil
+	}
+	name := FeedName(peer)
+	switch {
+	case sc.NewState == StateEstablished:
+		stats.SessionsUp++
+		r.node.AddFeed(name, peer.Asn)
+	case sc.OldState == StateEstablished:
+		stats.SessionsDown++
+		r.node.RemoveFeed(name)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *Replayer) message(rec *Record, stats *ReplayStats) (bool, error) {
+	m, err := DecodeBgp4mpMessage(rec.Subtype, rec.Data)
+	if err != nil {
+		return false, err
+	}
+	peer := m.Peer()
+	if m.Local || (r.peerFilter != nil && !r.peerFilter(peer)) {
+		return false, nil
+	}
+	u, err := m.Update()
+	if err != nil || u == nil {
+		return false, err
+	}
+
+	advs := u.RouteAdvs()
+	if len(advs) == 0 {
+		return false, nil
+	}
+	stats.Updates++
+	for _, adv := range advs {
+		if adv.Action == ra.Add {
+			stats.Announced++
+		} else {
+			stats.Withdrawn++
+		}
+	}
+
+	// Sessions established before the stream started have no state change
+	name := FeedName(peer)
+	if !r.node.Feed(name, advs...) {
+		r.node.AddFeed(name, peer.Asn)
+		r.node.Feed(name, advs...)
+	}
+	return true, nil
+}
@@ -0,0 +1,131 @@
+package mrt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+)
+
+// Session of collector peer 192.0.2.1 in AS 64500
+func bgp4mpHeader() []byte {
+	b := binary.BigEndian.AppendUint32(nil, 64500)
+	b = binary.BigEndian.AppendUint32(b, 65000)
+	b = append(b, 0, 0, 0, wire.AfiIPv4)
+	return append(b, 192, 0, 2, 1, 192, 0, 2, 254)
+}
+
+func stateChange(from, to uint16) []byte {
+	b := bgp4mpHeader()
+	b = binary.BigEndian.AppendUint16(b, from)
+	return binary.BigEndian.AppendUint16(b, to)
+}
+
+func update(withdrawn []netip.Prefix, attrs []byte, nlri []netip.Prefix) []byte {
+	prefixes := func(ps []netip.Prefix) []byte {
+		var b []byte
+		for _, p := range ps {
+			b = append(b, uint8(p.Bits()))
+			b = append(b, p.Addr().AsSlice()[:(p.Bits()+7)/8]...)
+		}
+		return b
+	}
+	w := prefixes(withdrawn)
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(w)))
+	body = append(body, w...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(attrs)))
+	body = append(body, attrs...)
+	body = append(body, prefixes(nlri)...)
+
+	msg := bytes.Repeat([]byte{0xff}, 16)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(wire.HeaderLen+len(body)))
+	msg = append(msg, wire.MsgUpdate)
+	return append(append(bgp4mpHeader(), msg...), body...)
+}
+
+func updates() []byte {
+	attrs := append(attr(wire.FlagTransitive, wire.AttrOrigin, []byte{0}), asPath(64500, 64496)...)
+	attrs = append(attrs, attr(wire.FlagTransitive, wire.AttrNextHop, []byte{192, 0, 2, 1})...)
+	other := netip.MustParsePrefix("198.51.100.0/25")
+
+	var b []byte
+	b = append(b, recordAt(100, TypeBgp4mp, SubtypeStateChangeAS4, stateChange(1, StateEstablished))...)
+	b = append(b, recordAt(100, TypeBgp4mp, SubtypeMessageAS4, update(nil, attrs, []netip.Prefix{v4Prefix, other}))...)
+	b = append(b, recordAt(130, TypeBgp4mp, SubtypeMessageAS4, update(nil, []byte{wire.FlagTransitive, wire.AttrOrigin, 5, 0}, nil))...)
+	b = append(b, recordAt(160, TypeBgp4mp, SubtypeMessageAS4, update([]netip.Prefix{other}, nil, nil))...)
+	b = append(b, recordAt(220, TypeBgp4mp, SubtypeStateChangeAS4, stateChange(StateEstablished, 1))...)
+	return b
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	a, b, sim := makeTopology()
+	other := netip.MustParsePrefix("198.51.100.0/25")
+	var batches []time.Time
+	hook := func(ts time.Time) error {
+		batches = append(batches, ts)
+		_, okA := a.LocRib(v4Prefix)
+		_, okB := b.LocRib(other)
+		switch len(batches) {
+		case 1:
+			if !okA || !okB {
+				t.Errorf("Expected routes propagated after first batch")
+			}
+		case 2:
+			if !okA || okB {
+				t.Errorf("Expected only %v withdrawn after second batch", other)
+			}
+		case 3:
+			if okA {
+				t.Errorf("Expected routes withdrawn after session down")
+			}
+		}
+		return nil
+	}
+	stats, err := NewReplayer(sim, a, WithBatchHook(hook)).Replay(bytes.NewReader(updates()))
+	if err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	want := ReplayStats{Updates: 2, Announced: 2, Withdrawn: 1, SessionsUp: 1, SessionsDown: 1, Batches: 3, Malformed: 1}
+	if stats != want {
+		t.Errorf("Replay() = %+v, expected %+v", stats, want)
+	}
+	if len(batches) != 3 || !batches[1].Equal(time.Unix(160, 0)) {
+		t.Errorf("Unexpected batches %v", batches)
+	}
+	if len(a.Feeds()) != 0 {
+		t.Errorf("Expected no feeds left, got %v", a.Feeds())
+	}
+}
+
+func TestReplayer_Interval(t *testing.T) {
+	a, _, sim := makeTopology()
+	stats, err := NewReplayer(sim, a, WithInterval(time.Minute+time.Second)).Replay(bytes.NewReader(updates()))
+	if err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	if stats.Batches != 2 {
+		t.Errorf("Expected 2 batches, got %d", stats.Batches)
+	}
+}
+
+func TestReader_ExtendedTimestamp(t *testing.T) {
+	data := binary.BigEndian.AppendUint32(nil, 250000)
+	data = append(data, stateChange(1, StateEstablished)...)
+	rec, err := NewReader(bytes.NewReader(recordAt(100, TypeBgp4mpEt, SubtypeStateChangeAS4, data))).Next()
+	if err != nil {
+		t.Fatalf("Next() = %v", err)
+	}
+	if want := time.Unix(100, 250000000); !rec.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, expected %v", rec.Timestamp, want)
+	}
+	sc, err := DecodeBgp4mpStateChange(rec.Subtype, rec.Data)
+	if err != nil {
+		t.Fatalf("DecodeBgp4mpStateChange() = %v", err)
+	}
+	if sc.PeerAsn != 64500 || sc.NewState != StateEstablished {
+		t.Errorf("Unexpected state change %+v", sc)
+	}
+}
@@ -0,0 +1,210 @@
+package mrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported subtype or address family")
+)
+
+// Peer types of PEER_INDEX_TABLE entries
+const (
+	peerTypeIPv6 = 0x01
+	peerTypeAS4  = 0x02
+)
+
+// PeerEntry is a collector peer
+type PeerEntry struct {
+	BgpID netip.Addr
+	Addr  netip.Addr
+	Asn   uint32
+}
+
+// PeerIndexTable lists the peers RIB entries refer to
+type PeerIndexTable struct {
+	CollectorID netip.Addr
+	ViewName    string
+	Peers       []PeerEntry
+}
+
+// RibEntry is one peer's route for a prefix
+type RibEntry struct {
+	PeerIndex  uint16
+	Originated time.Time
+	PathID     uint32 // Zero unless ADD-PATH
+	Attributes *wire.Attributes
+}
+
+// Rib holds all peers' routes for a prefix
+type Rib struct {
+	Sequence uint32
+	Afi      uint16
+	Safi     uint8
+	Prefix   netip.Prefix
+	Entries  []RibEntry
+}
+
+// DecodePeerIndexTable decodes the data of a PEER_INDEX_TABLE record
+func DecodePeerIndexTable(b []byte) (*PeerIndexTable, error) {
+	if len(b) < 6 {
+		return nil, ErrTruncated
+	}
+	t := &PeerIndexTable{
+		CollectorID: netip.AddrFrom4([4]byte(b)),
+	}
+	nameLen := int(binary.BigEndian.Uint16(b[4:]))
+	b = b[6:]
+	if len(b) < nameLen+2 {
+		return nil, ErrTruncated
+	}
+	t.ViewName = string(b[:nameLen])
+	count := int(binary.BigEndian.Uint16(b[nameLen:]))
+	b = b[nameLen+2:]
+
+	t.Peers = make([]PeerEntry, 0, count)
+	for range count {
+		if len(b) < 5 {
+			return nil, ErrTruncated
+		}
+		typ := b[0]
+		p := PeerEntry{BgpID: netip.AddrFrom4([4]byte(b[1:]))}
+		b = b[5:]
+
+		if typ&peerTypeIPv6 != 0 {
+			if len(b) < 16 {
+				return nil, ErrTruncated
+			}
+			p.Addr = netip.AddrFrom16([16]byte(b))
+			b = b[16:]
+		} else {
+			if len(b) < 4 {
+				return nil, ErrTruncated
+			}
+			p.Addr = netip.AddrFrom4([4]byte(b))
+			b = b[4:]
+		}
+
+		if typ&peerTypeAS4 != 0 {
+			if len(b) < 4 {
+				return nil, ErrTruncated
+			}
+			p.Asn = binary.BigEndian.Uint32(b)
+			b = b[4:]
+		} else {
+			if len(b) < 2 {
+				return nil, ErrTruncated
+			}
+			p.Asn = uint32(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		t.Peers = append(t.Peers, p)
+	}
+	return t, nil
+}
+
+// IsRib reports whether a TABLE_DUMP_V2 subtype holds RIB entries
+func IsRib(subtype uint16) bool {
+	switch subtype {
+	case SubtypePeerIndexTable, 7: // GEO_PEER_TABLE
+		return false
+	}
+	return subtype <= SubtypeRibGenericAddPath
+}
+
+// DecodeRib decodes the data of a RIB record, including ADD-PATH and generic variants
+func DecodeRib(subtype uint16, b []byte) (*Rib, error) {
+	rib := &Rib{}
+	addPath := false
+	switch subtype {
+	case SubtypeRibIPv4Unicast:
+		rib.Afi, rib.Safi = wire.AfiIPv4, wire.SafiUnicast
+	case SubtypeRibIPv4Multicast:
+		rib.Afi, rib.Safi = wire.AfiIPv4, wire.SafiMulticast
+	case SubtypeRibIPv6Unicast:
+		rib.Afi, rib.Safi = wire.AfiIPv6, wire.SafiUnicast
+	case SubtypeRibIPv6Multicast:
+		rib.Afi, rib.Safi = wire.AfiIPv6, wire.SafiMulticast
+	case SubtypeRibIPv4UnicastAddPath:
+		rib.Afi, rib.Safi, addPath = wire.AfiIPv4, wire.SafiUnicast, true
+	case SubtypeRibIPv4MulticastAddPath:
+		rib.Afi, rib.Safi, addPath = wire.AfiIPv4, wire.SafiMulticast, true
+	case SubtypeRibIPv6UnicastAddPath:
+		rib.Afi, rib.Safi, addPath = wire.AfiIPv6, wire.SafiUnicast, true
+	case SubtypeRibIPv6MulticastAddPath:
+		rib.Afi, rib.Safi, addPath = wire.AfiIPv6, wire.SafiMulticast, true
+	case SubtypeRibGeneric, SubtypeRibGenericAddPath:
+		addPath = subtype == SubtypeRibGenericAddPath
+	default:
+		return nil, ErrUnsupported
+	}
+
+	if len(b) < 4 {
+		return nil, ErrTruncated
+	}
+	rib.Sequence = binary.BigEndian.Uint32(b)
+	b = b[4:]
+
+	if rib.Afi == 0 {
+		if len(b) < 3 {
+			return nil, ErrTruncated
+		}
+		rib.Afi, rib.Safi = binary.BigEndian.Uint16(b), b[2]
+		b = b[3:]
+		if rib.Afi != wire.AfiIPv4 && rib.Afi != wire.AfiIPv6 {
+			return nil, ErrUnsupported
+		}
+	}
+
+	prefix, size, err := wire.DecodePrefix(b, rib.Afi)
+	if err != nil {
+		return nil, err
+	}
+	rib.Prefix = prefix
+	b = b[size:]
+
+	if len(b) < 2 {
+		return nil, ErrTruncated
+	}
+	count := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+
+	codec := wire.NewCodec(wire.WithShortMpReach(true))
+	rib.Entries = make([]RibEntry, 0, count)
+	for range count {
+		if len(b) < 6 {
+			return nil, ErrTruncated
+		}
+		e := RibEntry{
+			PeerIndex:  binary.BigEndian.Uint16(b),
+			Originated: time.Unix(int64(binary.BigEndian.Uint32(b[2:])), 0).UTC(),
+		}
+		b = b[6:]
+		if addPath {
+			if len(b) < 4 {
+				return nil, ErrTruncated
+			}
+			e.PathID = binary.BigEndian.Uint32(b)
+			b = b[4:]
+		}
+		if len(b) < 2 {
+			return nil, ErrTruncated
+		}
+		attrLen := int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+		if len(b) < attrLen {
+			return nil, ErrTruncated
+		}
+		if e.Attributes, err = codec.DecodeAttributes(b[:attrLen]); err != nil {
+			return nil, err
+		}
+		b = b[attrLen:]
+		rib.Entries = append(rib.Entries, e)
+	}
+	return rib, nil
+}
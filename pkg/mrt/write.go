@@ -0,0 +1,155 @@
+package mrt
+
+import (
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/wire"
+)
+
+// Writer writes MRT records to a stream
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// Create new Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+	}
+}
+
+// Write writes a record, extended timestamp types carry the microseconds of its timestamp
+func (wr *Writer) Write(rec *Record) error {
+	data := rec.Data
+	length := len(data)
+	if rec.Type == TypeBgp4mpEt {
+		length += 4
+	}
+	b := wr.buf[:0]
+	b = binary.BigEndian.AppendUint32(b, uint32(rec.Timestamp.Unix()))
+	b = binary.BigEndian.AppendUint16(b, rec.Type)
+	b = binary.BigEndian.AppendUint16(b, rec.Subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(length))
+	if rec.Type == TypeBgp4mpEt {
+		b = binary.BigEndian.AppendUint32(b, uint32(rec.Timestamp.Nanosecond()/int(time.Microsecond)))
+	}
+	b = append(b, data...)
+	wr.buf = b
+	_, err := wr.w.Write(b)
+	return err
+}
+
+// EncodePeerIndexTable encodes the data of a PEER_INDEX_TABLE record, AS numbers are always 4 bytes
+func EncodePeerIndexTable(t *PeerIndexTable) []byte {
+	b := v4Slice(t.CollectorID)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(t.ViewName)))
+	b = append(b, t.ViewName...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(t.Peers)))
+	for _, p := range t.Peers {
+		typ := uint8(peerTypeAS4)
+		if p.Addr.Is6() {
+			typ |= peerTypeIPv6
+		}
+		b = append(b, typ)
+		b = append(b, v4Slice(p.BgpID)...)
+		if p.Addr.Is6() {
+			b = append(b, p.Addr.AsSlice()...)
+		} else {
+			b = append(b, v4Slice(p.Addr)...)
+		}
+		b = binary.BigEndian.AppendUint32(b, p.Asn)
+	}
+	return b
+}
+
+// RibSubtype returns the TABLE_DUMP_V2 subtype of a unicast RIB record for a prefix
+func RibSubtype(prefix netip.Prefix, addPath bool) uint16 {
+	switch {
+	case prefix.Addr().Is4() && addPath:
+		return SubtypeRibIPv4UnicastAddPath
+	case prefix.Addr().Is4():
+		return SubtypeRibIPv4Unicast
+	case addPath:
+		return SubtypeRibIPv6UnicastAddPath
+	}
+	return SubtypeRibIPv6Unicast
+}
+
+// EncodeRib encodes the data of a unicast RIB record of the given subtype
+func EncodeRib(subtype uint16, rib *Rib) []byte {
+	addPath := subtype == SubtypeRibIPv4UnicastAddPath || subtype == SubtypeRibIPv6UnicastAddPath
+	b := binary.BigEndian.AppendUint32(nil, rib.Sequence)
+	b = wire.AppendPrefix(b, rib.Prefix)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rib.Entries)))
+
+	codec := wire.NewCodec(wire.WithShortMpReach(true))
+	for _, e := range rib.Entries {
+		b = binary.BigEndian.AppendUint16(b, e.PeerIndex)
+		b = binary.BigEndian.AppendUint32(b, uint32(e.Originated.Unix()))
+		if addPath {
+			b = binary.BigEndian.AppendUint32(b, e.PathID)
+		}
+		attrs := codec.EncodeAttributes(e.Attributes)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return b
+}
+
+// Subtype returns the BGP4MP subtype matching the flags of the message
+func (m *Bgp4mpMessage) Subtype() uint16 {
+	var subtype uint16
+	switch {
+	case m.AddPath && m.Local:
+		subtype = SubtypeMessageLocalAddPath
+	case m.AddPath:
+		subtype = SubtypeMessageAddPath
+	case m.Local:
+		subtype = SubtypeMessageLocal
+	case m.AS4:
+		return SubtypeMessageAS4
+	default:
+		return SubtypeMessage
+	}
+	// Other AS4 variants directly follow their 2-byte counterparts
+	if m.AS4 {
+		subtype++
+	}
+	return subtype
+}
+
+// EncodeBgp4mpMessage encodes the data of a BGP4MP message record
+func EncodeBgp4mpMessage(m *Bgp4mpMessage) []byte {
+	var b []byte
+	if m.AS4 {
+		b = binary.BigEndian.AppendUint32(b, m.PeerAsn)
+		b = binary.BigEndian.AppendUint32(b, m.LocalAsn)
+	} else {
+		b = binary.BigEndian.AppendUint16(b, uint16(m.PeerAsn))
+		b = binary.BigEndian.AppendUint16(b, uint16(m.LocalAsn))
+	}
+	b = binary.BigEndian.AppendUint16(b, m.Iface)
+	if m.PeerAddr.Is6() {
+		b = binary.BigEndian.AppendUint16(b, wire.AfiIPv6)
+		b = append(b, m.PeerAddr.AsSlice()...)
+		local := m.LocalAddr.As16()
+		b = append(b, local[:]...)
+	} else {
+		b = binary.BigEndian.AppendUint16(b, wire.AfiIPv4)
+		b = append(b, v4Slice(m.PeerAddr)...)
+		b = append(b, v4Slice(m.LocalAddr)...)
+	}
+	return append(b, m.Data...)
+}
+
+// v4Slice returns the bytes of an IPv4 address, 0.0.0.0 if addr is not IPv4
+func v4Slice(addr netip.Addr) []byte {
+	if !addr.Is4() {
+		addr = netip.IPv4Unspecified()
+	}
+	return addr.AsSlice()
+}
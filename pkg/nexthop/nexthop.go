@@ -77,15 +77,15 @@ func (nexthop *NextHop) Type() NextHopType {
 func (nexthop *NextHop) computeHash() {
 	h := fnv.New32a()
 	switch nexthop.t {
-	case IP:
-		binary.Write(h, binary.BigEndian, nexthop.t)
+	case IP, Invalid:
+		binary.Write(h, binary.BigEndian, int64(nexthop.t))
 		ipBytes := nexthop.ip.As16()
 		h.Write(ipBytes[:])
 	case Interface:
-		binary.Write(h, binary.BigEndian, nexthop.t)
+		binary.Write(h, binary.BigEndian, int64(nexthop.t))
 		h.Write([]byte(nexthop.iface))
 	case Discard:
-		binary.Write(h, binary.BigEndian, nexthop.t)
+		binary.Write(h, binary.BigEndian, int64(nexthop.t))
 	}
 
 	nexthop.hash = h.Sum32()
@@ -44,6 +44,18 @@ func (rq *RaQueue[R]) PopAll(neighbor string) []RouteAdv[R] {
 	return cp
 }
 
+// Clone returns a copy of the queue with the same pending advertisements
+func (rq *RaQueue[R]) Clone() *RaQueue[R] {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+
+	c := NewRaQueue[R]()
+	for k, v := range rq.queues {
+		c.queues[k] = slices.Clone(v)
+	}
+	return c
+}
+
 func (rq *RaQueue[R]) BeginTx() *Tx[R] {
 	return &Tx[R]{
 		enqueued: make(map[string][]RouteAdv[R]),
@@ -0,0 +1,83 @@
+package rib
+
+import (
+	"cmp"
+	"errors"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+const (
+	maxResolveDepth = 16
+)
+
+var (
+	ErrUnresolvable = errors.New("next hop unresolvable")
+	ErrResolveLoop  = errors.New("next hop resolution loop")
+	ErrResolveDepth = errors.New("next hop resolution depth exceeded")
+)
+
+// Resolve recursively resolves an IP next hop through the RIB.
+// Returns the set of Interface and Discard next hops it ultimately forwards to.
+// BGP routes for excluded prefixes are skipped, so a route cannot resolve through itself.
+func (rib *Rib) Resolve(ip netip.Addr, exclude ...netip.Prefix) ([]nexthop.NextHop, error) {
+	resolved := make(map[uint32]nexthop.NextHop)
+	excluded := make(map[netip.Prefix]bool)
+	for _, pfx := range exclude {
+		excluded[pfx.Masked()] = true
+	}
+	err := rib.resolve(ip, 0, excluded, make(map[netip.Prefix]bool), resolved)
+	if len(resolved) == 0 {
+		if err == nil {
+			err = ErrUnresolvable
+		}
+		return nil, err
+	}
+
+	nhs := make([]nexthop.NextHop, 0, len(resolved))
+	for _, nh := range resolved {
+		nhs = append(nhs, nh)
+	}
+	slices.SortFunc(nhs, func(a, b nexthop.NextHop) int {
+		return cmp.Compare(a.Hash(), b.Hash())
+	})
+	return nhs, nil
+}
+
+// resolve walks the RIB depth-first, collecting terminal next hops.
+// Prefixes on the current resolution chain are tracked in visiting to detect cycles.
+// Returns the last error encountered on a failed branch.
+func (rib *Rib) resolve(ip netip.Addr, depth int, excluded, visiting map[netip.Prefix]bool, resolved map[uint32]nexthop.NextHop) error {
+	if depth > maxResolveDepth {
+		return ErrResolveDepth
+	}
+
+	pfx, routes, ok := rib.lookup(ip, func(r Route) bool {
+		return r.Protocol != BGP || !excluded[r.Prefix]
+	})
+	if !ok {
+		return ErrUnresolvable
+	}
+	if visiting[pfx] {
+		return ErrResolveLoop
+	}
+	visiting[pfx] = true
+	defer delete(visiting, pfx)
+
+	var err error
+	for _, r := range routes {
+		switch r.NextHop.Type() {
+		case nexthop.Interface, nexthop.Discard:
+			resolved[r.NextHop.Hash()] = r.NextHop
+		case nexthop.IP:
+			if e := rib.resolve(r.NextHop.IP(), depth+1, excluded, visiting, resolved); e != nil {
+				err = e
+			}
+		case nexthop.Invalid:
+			err = ErrUnresolvable
+		}
+	}
+	return err
+}
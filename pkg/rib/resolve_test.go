@@ -0,0 +1,57 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+func TestResolve_Loop(t *testing.T) {
+	r := MakeRib()
+	r.AddRoute(Route{
+		Prefix:  netip.MustParsePrefix("192.0.2.0/24"),
+		NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("198.51.100.1"))),
+	})
+	r.AddRoute(Route{
+		Prefix:  netip.MustParsePrefix("198.51.100.0/24"),
+		NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("192.0.2.1"))),
+	})
+
+	if _, err := r.Resolve(netip.MustParseAddr("192.0.2.1")); err != ErrResolveLoop {
+		t.Errorf("Resolve() = %v, expected %v", err, ErrResolveLoop)
+	}
+}
+
+func TestResolve_Recursive(t *testing.T) {
+	r := MakeRib()
+	r.AddRoute(Route{
+		Prefix:   netip.MustParsePrefix("10.0.0.0/30"),
+		NextHop:  nexthop.New(nexthop.WithInterface("eth0")),
+		Protocol: Connected,
+	})
+	r.AddRoute(Route{
+		Prefix:    netip.MustParsePrefix("192.0.2.0/24"),
+		NextHop:   nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.2"))),
+		Protocol:  Static,
+		AdminCost: 1,
+	})
+	r.AddRoute(Route{
+		Prefix:    netip.MustParsePrefix("198.51.100.0/24"),
+		NextHop:   nexthop.New(nexthop.WithIP(netip.MustParseAddr("192.0.2.1"))),
+		AdminCost: 20,
+	})
+
+	nhs, err := r.Resolve(netip.MustParseAddr("198.51.100.1"))
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if len(nhs) != 1 || nhs[0].Iface() != "eth0" {
+		t.Errorf("Expected resolution to eth0, got %v", nhs)
+	}
+
+	// A route must not resolve through itself
+	if _, err := r.Resolve(netip.MustParseAddr("198.51.100.1"), netip.MustParsePrefix("198.51.100.0/24")); err != ErrUnresolvable {
+		t.Errorf("Resolve() = %v, expected %v", err, ErrUnresolvable)
+	}
+}
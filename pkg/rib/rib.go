@@ -1,7 +1,9 @@
 package rib
 
 import (
+	"cmp"
 	"net/netip"
+	"slices"
 
 	"github.com/HT4w5/bgpsim-go/pkg/rpool"
 	"github.com/gaissmai/bart"
@@ -17,10 +19,19 @@ func MakeRib() *Rib {
 	}
 }
 
+// Clone returns a deep copy of the RIB
+func (rib *Rib) Clone() *Rib {
+	c := MakeRib()
+	for _, r := range rib.GetRoutes() {
+		c.AddRoute(r)
+	}
+	return c
+}
+
 // AddRoute adds a route to the RIB
 func (rib *Rib) AddRoute(route Route) bool {
 	changed := false
-	p, ok := rib.ipt.LookupPrefix(route.Prefix)
+	p, ok := rib.ipt.Get(route.Prefix)
 	if !ok {
 		changed = true
 		p = rpool.MakeRoutePool()
@@ -32,16 +43,20 @@ func (rib *Rib) AddRoute(route Route) bool {
 
 // RemoveRoute removes a route from the RIB
 func (rib *Rib) RemoveRoute(route Route) bool {
-	p, ok := rib.ipt.LookupPrefix(route.Prefix)
+	p, ok := rib.ipt.Get(route.Prefix)
 	if !ok {
 		return false
 	}
-	return p.Remove(route)
+	changed := p.Remove(route)
+	if p.Len() == 0 {
+		rib.ipt.Delete(route.Prefix)
+	}
+	return changed
 }
 
 // GetRoutes returns all routes in the RIB
 func (rib *Rib) GetRoutes() []Route {
-	routes := make([]Route, rib.ipt.Size())
+	routes := make([]Route, 0, rib.ipt.Size())
 	for _, m := range rib.ipt.All() {
 		for r := range m.All() {
 			routes = append(routes, r.(Route))
@@ -50,6 +65,15 @@ func (rib *Rib) GetRoutes() []Route {
 	return routes
 }
 
+// Prefixes returns every prefix in the RIB in sorted order
+func (rib *Rib) Prefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, rib.ipt.Size())
+	for pfx := range rib.ipt.AllSorted() {
+		prefixes = append(prefixes, pfx)
+	}
+	return prefixes
+}
+
 // GetRoutesForPrefix returns routes for a specific prefix
 func (rib *Rib) GetRoutesForPrefix(prefix netip.Prefix) []Route {
 	routes := make([]Route, 0)
@@ -61,6 +85,24 @@ func (rib *Rib) GetRoutesForPrefix(prefix netip.Prefix) []Route {
 	return routes
 }
 
+// GetActiveRoutes returns the active routes of every prefix in the RIB
+func (rib *Rib) GetActiveRoutes() []Route {
+	routes := make([]Route, 0, rib.ipt.Size())
+	for _, m := range rib.ipt.All() {
+		routes = append(routes, activeRoutes(m, nil)...)
+	}
+	return routes
+}
+
+// GetActiveRoutesForPrefix returns the active routes of exactly one prefix
+func (rib *Rib) GetActiveRoutesForPrefix(prefix netip.Prefix) []Route {
+	m, ok := rib.ipt.Get(prefix)
+	if !ok {
+		return nil
+	}
+	return activeRoutes(m, nil)
+}
+
 // LongestPrefixMatch finds the most specific routes matching an IP
 func (rib *Rib) LongestPrefixMatch(ip netip.Addr) []Route {
 	routes := make([]Route, 0)
@@ -73,3 +115,46 @@ func (rib *Rib) LongestPrefixMatch(ip netip.Addr) []Route {
 	}
 	return routes
 }
+
+// Lookup finds the most specific prefix covering an IP that has forwarding routes.
+// Only the active routes (lowest admin cost, then lowest metric) are returned.
+func (rib *Rib) Lookup(ip netip.Addr) (netip.Prefix, []Route, bool) {
+	return rib.lookup(ip, nil)
+}
+
+// lookup is Lookup considering only routes accepted by keep, if set
+func (rib *Rib) lookup(ip netip.Addr, keep func(Route) bool) (netip.Prefix, []Route, bool) {
+	for pfx, m := range rib.ipt.Supernets(netip.PrefixFrom(ip, ip.BitLen())) {
+		active := activeRoutes(m, keep)
+		if len(active) != 0 {
+			return pfx, active, true
+		}
+	}
+	return netip.Prefix{}, nil, false
+}
+
+// activeRoutes returns the forwarding routes of a pool with the lowest admin cost and metric,
+// ordered by hash for determinism. Only routes accepted by keep are considered, if set.
+func activeRoutes(m *rpool.RoutePool, keep func(Route) bool) []Route {
+	active := make([]Route, 0)
+	for rr := range m.All() {
+		r := rr.(Route)
+		if r.NonForwarding || (keep != nil && !keep(r)) {
+			continue
+		}
+		if len(active) != 0 {
+			c := cmp.Or(cmp.Compare(r.AdminCost, active[0].AdminCost), cmp.Compare(r.Metric, active[0].Metric))
+			if c > 0 {
+				continue
+			}
+			if c < 0 {
+				active = active[:0]
+			}
+		}
+		active = append(active, r)
+	}
+	slices.SortFunc(active, func(a, b Route) int {
+		return cmp.Compare(a.Hash(), b.Hash())
+	})
+	return active
+}
@@ -4,17 +4,21 @@ import (
 	"encoding/binary"
 	"hash/fnv"
 	"net/netip"
+
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
 )
 
 type Protocol int
 
 const (
 	BGP Protocol = iota
+	Connected
+	Static
 )
 
 type Route struct {
 	Prefix        netip.Prefix
-	NextHop       netip.Addr
+	NextHop       nexthop.NextHop
 	Protocol      Protocol
 	AdminCost     int
 	Metric        uint64
@@ -27,11 +31,9 @@ func (r Route) Hash() uint32 {
 	prefixBytes, _ := r.Prefix.MarshalBinary()
 	h.Write(prefixBytes)
 
-	nextHopBytes, _ := r.NextHop.MarshalBinary()
-	h.Write(nextHopBytes)
-
-	binary.Write(h, binary.BigEndian, r.Protocol)
-	binary.Write(h, binary.BigEndian, r.AdminCost)
+	binary.Write(h, binary.BigEndian, r.NextHop.Hash())
+	binary.Write(h, binary.BigEndian, int64(r.Protocol))
+	binary.Write(h, binary.BigEndian, int64(r.AdminCost))
 	binary.Write(h, binary.BigEndian, r.Metric)
 	binary.Write(h, binary.BigEndian, r.NonForwarding)
 
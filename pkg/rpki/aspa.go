@@ -0,0 +1,216 @@
+package rpki
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// AspaState is the result of ASPA path verification
+type AspaState int
+
+const (
+	AspaUnknown AspaState = iota
+	AspaValid
+	AspaInvalid
+)
+
+func (s AspaState) String() string {
+	switch s {
+	case AspaUnknown:
+		return "unknown"
+	case AspaValid:
+		return "valid"
+	case AspaInvalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// Direction is the relationship of the neighbor a path was received from
+type Direction int
+
+const (
+	Upstream   Direction = iota // From a customer or a lateral peer
+	Downstream                  // From a provider
+)
+
+// hop results of a customer to provider pair
+type hop int
+
+const (
+	noAttestation hop = iota
+	providerPlus
+	notProviderPlus
+)
+
+// Aspa authorizes the providers of a customer AS
+type Aspa struct {
+	Customer  uint32
+	Providers []uint32
+}
+
+// AspaTable holds ASPA objects by customer AS
+type AspaTable struct {
+	providers map[uint32][]uint32
+}
+
+// Create new AspaTable
+func NewAspaTable(aspas ...Aspa) *AspaTable {
+	t := &AspaTable{
+		providers: make(map[uint32][]uint32),
+	}
+	for _, a := range aspas {
+		t.Add(a)
+	}
+	return t
+}
+
+// Add stores an ASPA, replacing any previous one of the same customer
+func (t *AspaTable) Add(a Aspa) {
+	providers := slices.Clone(a.Providers)
+	slices.Sort(providers)
+	t.providers[a.Customer] = slices.Compact(providers)
+}
+
+// Remove deletes the ASPA of a customer
+func (t *AspaTable) Remove(customer uint32) {
+	delete(t.providers, customer)
+}
+
+// Len returns the number of ASPAs
+func (t *AspaTable) Len() int {
+	return len(t.providers)
+}
+
+// Aspas returns every ASPA sorted by customer
+func (t *AspaTable) Aspas() []Aspa {
+	var aspas []Aspa
+	for _, customer := range slices.Sorted(maps.Keys(t.providers)) {
+		aspas = append(aspas, Aspa{Customer: customer, Providers: slices.Clone(t.providers[customer])})
+	}
+	return aspas
+}
+
+// hop checks whether provider is authorized by customer
+func (t *AspaTable) hop(customer, provider uint32) hop {
+	providers, ok := t.providers[customer]
+	if !ok {
+		return noAttestation
+	}
+	if _, found := slices.BinarySearch(providers, provider); found {
+		return providerPlus
+	}
+	return notProviderPlus
+}
+
+// Verify checks an AS path, neighbor first as in BgpRoute.AsPath, following the ASPA verification procedure.
+// An upstream path must climb from the origin to the neighbor through providers only. A downstream path may
+// climb, then descend, towards the neighbor. Prepending is ignored.
+func (t *AspaTable) Verify(path []uint32, dir Direction) AspaState {
+	// Origin first, without prepends
+	ases := slices.Compact(slices.Clone(path))
+	slices.Reverse(ases)
+	n := len(ases)
+	if n <= 1 || (dir == Downstream && n == 2) {
+		return AspaValid
+	}
+
+	// Ramps from the origin: hops from AS(i) up to AS(i+1)
+	maxUp, minUp := n, n
+	for i := 0; i < n-1; i++ {
+		h := t.hop(ases[i], ases[i+1])
+		if h != providerPlus && minUp == n {
+			minUp = i + 1
+		}
+		if h == notProviderPlus {
+			maxUp = i + 1
+			break
+		}
+	}
+	if dir == Upstream {
+		switch {
+		case maxUp < n:
+			return AspaInvalid
+		case minUp < n:
+			return AspaUnknown
+		}
+		return AspaValid
+	}
+
+	// Ramps from the neighbor: hops from AS(j) up to AS(j-1)
+	maxDown, minDown := n, n
+	for j := n - 1; j > 0; j-- {
+		h := t.hop(ases[j], ases[j-1])
+		if h != providerPlus && minDown == n {
+			minDown = n - j
+		}
+		if h == notProviderPlus {
+			maxDown = n - j
+			break
+		}
+	}
+	switch {
+	case maxUp+maxDown < n:
+		return AspaInvalid
+	case minUp+minDown < n:
+		return AspaUnknown
+	}
+	return AspaValid
+}
+
+// VerifyRoute verifies the AS path of a route received in the given direction, paths with an AS_SET are invalid
+func (t *AspaTable) VerifyRoute(r *route.BgpRoute, dir Direction) AspaState {
+	if len(r.AsSet()) != 0 {
+		return AspaInvalid
+	}
+	return t.Verify(r.AsPath(), dir)
+}
+
+// MatchAspa matches routes whose path verifies against t to one of states.
+// dir is the relationship of the session the policy is applied to.
+func MatchAspa(t *AspaTable, dir Direction, states ...AspaState) policy.Match {
+	return func(r *route.BgpRoute) bool {
+		return slices.Contains(states, t.VerifyRoute(r, dir))
+	}
+}
+
+type jsonAspa struct {
+	Customer     *jsonAsn  `json:"customer"`
+	CustomerAsid *jsonAsn  `json:"customer_asid"`
+	Providers    []jsonAsn `json:"providers"`
+}
+
+type jsonAspaExport struct {
+	Aspas []jsonAspa `json:"aspas"`
+}
+
+// ReadAspaJSON reads ASPAs from a JSON export of a relying party. Both the rpki-client form
+// {"customer_asid": 64496, "providers": [64497]} and the Routinator form
+// {"customer": "AS64496", "providers": ["AS64497"]} are accepted.
+func ReadAspaJSON(r io.Reader) (*AspaTable, error) {
+	var export jsonAspaExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+	t := NewAspaTable()
+	for _, a := range export.Aspas {
+		customer := a.Customer
+		if customer == nil {
+			customer = a.CustomerAsid
+		}
+		if customer == nil {
+			return nil, ErrBadAsn
+		}
+		aspa := Aspa{Customer: uint32(*customer)}
+		for _, p := range a.Providers {
+			aspa.Providers = append(aspa.Providers, uint32(p))
+		}
+		t.Add(aspa)
+	}
+	return t, nil
+}
@@ -0,0 +1,66 @@
+package rpki
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrBadAsn = errors.New("malformed AS number")
+	ErrBadVrp = errors.New("malformed VRP")
+)
+
+// jsonAsn is an AS number written as a number or as a string with optional AS prefix
+type jsonAsn uint32
+
+func (a *jsonAsn) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = strings.TrimPrefix(strings.ToUpper(unquoted), "AS")
+	}
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return ErrBadAsn
+	}
+	*a = jsonAsn(asn)
+	return nil
+}
+
+type jsonRoa struct {
+	Asn       jsonAsn `json:"asn"`
+	Prefix    string  `json:"prefix"`
+	MaxLength int     `json:"maxLength"`
+}
+
+type jsonExport struct {
+	Roas []jsonRoa `json:"roas"`
+}
+
+// ReadJSON reads VRPs from a JSON export of a relying party, as written by rpki-client or Routinator.
+// AS numbers may be plain numbers or strings like "AS64496". A missing max length defaults to the prefix length.
+func ReadJSON(r io.Reader) (*Table, error) {
+	var export jsonExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+	t := NewTable()
+	for _, roa := range export.Roas {
+		prefix, err := netip.ParsePrefix(roa.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		maxLength := roa.MaxLength
+		if maxLength == 0 {
+			maxLength = prefix.Bits()
+		}
+		if maxLength < prefix.Bits() || maxLength > prefix.Addr().BitLen() {
+			return nil, ErrBadVrp
+		}
+		t.Add(Vrp{Prefix: prefix, MaxLength: maxLength, Asn: uint32(roa.Asn)})
+	}
+	return t, nil
+}
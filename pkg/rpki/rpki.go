@@ -0,0 +1,158 @@
+package rpki
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/gaissmai/bart"
+)
+
+// State is the origin validation state of a route
+type State int
+
+const (
+	NotFound State = iota
+	Valid
+	Invalid
+)
+
+func (s State) String() string {
+	switch s {
+	case NotFound:
+		return "not-found"
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// Vrp is a validated ROA payload: asn may originate prefix and its subnets up to MaxLength
+type Vrp struct {
+	Prefix    netip.Prefix
+	MaxLength int
+	Asn       uint32
+}
+
+// Table holds VRPs indexed by prefix
+type Table struct {
+	vrps *bart.Table[[]Vrp]
+	size int
+}
+
+// Create new Table
+func NewTable(vrps ...Vrp) *Table {
+	t := &Table{
+		vrps: &bart.Table[[]Vrp]{},
+	}
+	for _, v := range vrps {
+		t.Add(v)
+	}
+	return t
+}
+
+// Add inserts a VRP, returning false if it is already present
+func (t *Table) Add(v Vrp) bool {
+	v.Prefix = v.Prefix.Masked()
+	added := false
+	t.vrps.Modify(v.Prefix, func(vrps []Vrp, _ bool) ([]Vrp, bool) {
+		if !slices.Contains(vrps, v) {
+			vrps = append(vrps, v)
+			added = true
+		}
+		return vrps, false
+	})
+	if added {
+		t.size++
+	}
+	return added
+}
+
+// Remove deletes a VRP, returning false if it is not present
+func (t *Table) Remove(v Vrp) bool {
+	v.Prefix = v.Prefix.Masked()
+	removed := false
+	t.vrps.Modify(v.Prefix, func(vrps []Vrp, ok bool) ([]Vrp, bool) {
+		if i := slices.Index(vrps, v); i >= 0 {
+			vrps = slices.Delete(slices.Clone(vrps), i, i+1)
+			removed = true
+		}
+		return vrps, len(vrps) == 0
+	})
+	if removed {
+		t.size--
+	}
+	return removed
+}
+
+// Len returns the number of VRPs
+func (t *Table) Len() int {
+	return t.size
+}
+
+// Vrps returns every VRP, sorted by prefix, max length and AS number
+func (t *Table) Vrps() []Vrp {
+	var all []Vrp
+	for _, vrps := range t.vrps.All() {
+		all = append(all, vrps...)
+	}
+	slices.SortFunc(all, compareVrp)
+	return all
+}
+
+// Validate returns the validation state of prefix originated by origin (RFC 6811).
+// A prefix is Valid if a covering VRP matches its origin and length, Invalid if it is only covered by
+// non-matching VRPs and NotFound if no VRP covers it. VRPs for AS 0 never match.
+func (t *Table) Validate(prefix netip.Prefix, origin uint32) State {
+	prefix = prefix.Masked()
+	state := NotFound
+	for _, vrps := range t.vrps.Supernets(prefix) {
+		for _, v := range vrps {
+			if v.Asn == origin && origin != 0 && prefix.Bits() <= v.MaxLength {
+				return Valid
+			}
+			state = Invalid
+		}
+	}
+	return state
+}
+
+// ValidateRoute validates a route against the last AS of its path.
+// Routes with an empty AS path originate in the local AS and are NotFound. Routes ending in an AS_SET
+// have no origin AS and match no VRP.
+func (t *Table) ValidateRoute(r *route.BgpRoute) State {
+	path := r.AsPath()
+	if len(r.AsSet()) != 0 {
+		return t.Validate(r.Prefix(), 0)
+	}
+	if len(path) == 0 {
+		return NotFound
+	}
+	return t.Validate(r.Prefix(), path[len(path)-1])
+}
+
+// MatchState matches routes whose validation state against t is one of states.
+// Policies see the table at the time they are applied: after the table changes, set import policies
+// again to revalidate received routes.
+func MatchState(t *Table, states ...State) policy.Match {
+	return func(r *route.BgpRoute) bool {
+		return slices.Contains(states, t.ValidateRoute(r))
+	}
+}
+
+func compareVrp(a, b Vrp) int {
+	if c := a.Prefix.Addr().Compare(b.Prefix.Addr()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Prefix.Bits(), b.Prefix.Bits()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.MaxLength, b.MaxLength); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Asn, b.Asn)
+}
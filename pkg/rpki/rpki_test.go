@@ -0,0 +1,274 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+func TestValidate(t *testing.T) {
+	table := NewTable(
+		Vrp{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, Asn: 64496},
+		Vrp{Prefix: netip.MustParsePrefix("198.51.100.0/22"), MaxLength: 24, Asn: 64497},
+		Vrp{Prefix: netip.MustParsePrefix("203.0.113.0/24"), MaxLength: 24, Asn: 0},
+	)
+	tests := []struct {
+		prefix string
+		origin uint32
+		want   State
+	}{
+		{"192.0.2.0/24", 64496, Valid},
+		{"192.0.2.0/24", 64497, Invalid},
+		{"192.0.2.0/25", 64496, Invalid}, // Too specific
+		{"198.51.101.0/24", 64497, Valid},
+		{"198.51.100.0/25", 64497, Invalid},
+		{"203.0.113.0/24", 0, Invalid}, // AS 0 never matches
+		{"2001:db8::/32", 64496, NotFound},
+		{"192.0.0.0/16", 64496, NotFound}, // Less specific than any VRP
+	}
+	for _, tt := range tests {
+		if got := table.Validate(netip.MustParsePrefix(tt.prefix), tt.origin); got != tt.want {
+			t.Errorf("Validate(%s, %d) = %v, expected %v", tt.prefix, tt.origin, got, tt.want)
+		}
+	}
+
+	v := Vrp{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, Asn: 64496}
+	if table.Add(v) || !table.Remove(v) || table.Remove(v) || table.Len() != 2 {
+		t.Fatalf("Unexpected Add/Remove results, %d VRPs left", table.Len())
+	}
+	if got := table.Validate(v.Prefix, 64496); got != NotFound {
+		t.Errorf("Validate() after Remove = %v, expected %v", got, NotFound)
+	}
+}
+
+func TestMatchState(t *testing.T) {
+	table := NewTable(Vrp{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, Asn: 64496})
+	dropInvalid := policy.New(
+		policy.Clause{Match: []policy.Match{MatchState(table, Invalid)}, Action: policy.Deny},
+		policy.Clause{Match: []policy.Match{policy.MatchAny()}, Action: policy.Permit},
+	)
+	prefix := netip.MustParsePrefix("192.0.2.0/24")
+	for _, tt := range []struct {
+		path []uint32
+		want bool
+	}{
+		{[]uint32{64511, 64496}, true},
+		{[]uint32{64496, 64511}, false},
+		{nil, true},
+	} {
+		r := route.New(route.WithPrefix(prefix), route.WithAsPath(tt.path))
+		if _, ok := dropInvalid.Apply(r); ok != tt.want {
+			t.Errorf("Apply() for path %v = %v, expected %v", tt.path, ok, tt.want)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	data := `{
+		"metadata": {"buildtime": "2024-01-01T00:00:00Z"},
+		"roas": [
+			{"asn": 64496, "prefix": "192.0.2.0/24", "maxLength": 24, "ta": "test"},
+			{"asn": "AS64497", "prefix": "2001:db8::/32", "maxLength": 48, "ta": "test"},
+			{"asn": "64498", "prefix": "198.51.100.0/24"}
+		]
+	}`
+	table, err := ReadJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadJSON() = %v", err)
+	}
+	want := []Vrp{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, Asn: 64496},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), MaxLength: 24, Asn: 64498},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), MaxLength: 48, Asn: 64497},
+	}
+	if got := table.Vrps(); !slices.Equal(got, want) {
+		t.Errorf("Vrps() = %v, expected %v", got, want)
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`{"roas": [{"asn": "ASx", "prefix": "192.0.2.0/24"}]}`)); err == nil {
+		t.Error("Expected an error for a malformed AS number")
+	}
+}
+
+// cache serves RTR queries over one end of a pipe
+type cache struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (c *cache) expect(typ uint8) {
+	c.t.Helper()
+	header := make([]byte, rtrHeaderLen)
+	if _, err := c.conn.Read(header); err != nil {
+		c.t.Errorf("Read() = %v", err)
+		return
+	}
+	if header[1] != typ {
+		c.t.Errorf("Expected PDU type %d, got %d", typ, header[1])
+	}
+	if n := int(binary.BigEndian.Uint32(header[4:])) - rtrHeaderLen; n > 0 {
+		c.conn.Read(make([]byte, n))
+	}
+}
+
+func (c *cache) send(typ uint8, field uint16, body []byte) {
+	b := []byte{1, typ}
+	b = binary.BigEndian.AppendUint16(b, field)
+	b = binary.BigEndian.AppendUint32(b, uint32(rtrHeaderLen+len(body)))
+	c.conn.Write(append(b, body...))
+}
+
+func (c *cache) prefix(v Vrp, announce bool) {
+	var flags uint8
+	if announce {
+		flags = flagAnnounce
+	}
+	typ := uint8(PduIPv4Prefix)
+	if v.Prefix.Addr().Is6() {
+		typ = PduIPv6Prefix
+	}
+	body := []byte{flags, uint8(v.Prefix.Bits()), uint8(v.MaxLength), 0}
+	body = append(body, v.Prefix.Addr().AsSlice()...)
+	c.send(typ, 0, binary.BigEndian.AppendUint32(body, v.Asn))
+}
+
+func (c *cache) endOfData(session uint16, serial uint32) {
+	body := binary.BigEndian.AppendUint32(nil, serial)
+	for _, s := range []uint32{600, 60, 7200} {
+		body = binary.BigEndian.AppendUint32(body, s)
+	}
+	c.send(PduEndOfData, session, body)
+}
+
+func TestClient(t *testing.T) {
+	v4 := Vrp{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, Asn: 64496}
+	v6 := Vrp{Prefix: netip.MustParsePrefix("2001:db8::/32"), MaxLength: 48, Asn: 64497}
+	other := Vrp{Prefix: netip.MustParsePrefix("198.51.100.0/24"), MaxLength: 24, Asn: 64498}
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	c := &cache{t: t, conn: remote}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.expect(PduResetQuery)
+		c.send(PduCacheResponse, 7, nil)
+		c.send(PduSerialNotify, 7, binary.BigEndian.AppendUint32(nil, 1))
+		c.prefix(v4, true)
+		c.prefix(v6, true)
+		c.endOfData(7, 1)
+
+		c.expect(PduSerialQuery)
+		c.send(PduCacheResponse, 7, nil)
+		c.prefix(v4, false)
+		c.prefix(other, true)
+		c.endOfData(7, 2)
+
+		// Cache lost its history, the client falls back to a reset
+		c.expect(PduSerialQuery)
+		c.send(PduCacheReset, 0, nil)
+		c.expect(PduResetQuery)
+		c.send(PduCacheResponse, 8, nil)
+		c.prefix(v4, true)
+		c.endOfData(8, 1)
+
+		c.expect(PduSerialQuery)
+		c.send(PduErrorReport, ErrCodeNoData, []byte{0, 0, 0, 0, 0, 0, 0, 0})
+	}()
+
+	// VRPs outside the cache are kept
+	table := NewTable(Vrp{Prefix: netip.MustParsePrefix("203.0.113.0/24"), MaxLength: 24, Asn: 64499})
+	client := NewClient(local, WithTable(table))
+	steps := []struct {
+		session uint16
+		serial  uint32
+		want    int
+	}{
+		{7, 1, 3},
+		{7, 2, 3},
+		{8, 1, 2},
+	}
+	for i, step := range steps {
+		if err := client.Sync(); err != nil {
+			t.Fatalf("Sync() #%d = %v", i, err)
+		}
+		session, serial, ok := client.Serial()
+		if !ok || session != step.session || serial != step.serial || table.Len() != step.want {
+			t.Fatalf("After Sync() #%d: session %d serial %d with %d VRPs", i, session, serial, table.Len())
+		}
+	}
+	if table.Validate(v6.Prefix, v6.Asn) != NotFound || table.Validate(other.Prefix, other.Asn) != NotFound {
+		t.Error("VRPs of the previous session must be flushed on reset")
+	}
+	if client.RefreshInterval().Seconds() != 600 {
+		t.Errorf("RefreshInterval() = %v", client.RefreshInterval())
+	}
+
+	var report *ErrorReport
+	if err := client.Sync(); !errors.As(err, &report) || report.Code != ErrCodeNoData {
+		t.Fatalf("Sync() = %v, expected error report", err)
+	}
+	<-done
+}
+
+func TestAspaTable_Verify(t *testing.T) {
+	table := NewAspaTable(
+		Aspa{Customer: 1, Providers: []uint32{2, 5}},
+		Aspa{Customer: 2, Providers: []uint32{3}},
+		Aspa{Customer: 8, Providers: []uint32{7}},
+		Aspa{Customer: 7, Providers: []uint32{9}},
+		Aspa{Customer: 11, Providers: []uint32{12}},
+	)
+	tests := []struct {
+		name string
+		path []uint32
+		dir  Direction
+		want AspaState
+	}{
+		{"customer route", []uint32{3, 2, 2, 1}, Upstream, AspaValid},
+		{"leak to provider", []uint32{1, 2}, Upstream, AspaInvalid},
+		{"unattested hop", []uint32{4, 3, 2, 1}, Upstream, AspaUnknown},
+		{"single hop", []uint32{6}, Upstream, AspaValid},
+		{"from provider", []uint32{3, 2, 1}, Downstream, AspaValid},
+		{"peak at unattested hop", []uint32{3, 7, 8}, Downstream, AspaValid},
+		{"valley", []uint32{11, 10, 2, 1}, Downstream, AspaInvalid},
+		{"unattested climb", []uint32{13, 4, 3}, Downstream, AspaUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Verify(tt.path, tt.dir); got != tt.want {
+				t.Errorf("Verify(%v) = %v, expected %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAspaJSON(t *testing.T) {
+	data := `{
+		"roas": [],
+		"aspas": [
+			{"customer_asid": 64496, "providers": [64498, 64497], "expires": 1700000000},
+			{"customer": "AS64499", "providers": ["AS64500"]}
+		]
+	}`
+	table, err := ReadAspaJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadAspaJSON() = %v", err)
+	}
+	aspas := table.Aspas()
+	if len(aspas) != 2 || !slices.Equal(aspas[0].Providers, []uint32{64497, 64498}) || aspas[1].Customer != 64499 {
+		t.Errorf("Unexpected ASPAs %v", aspas)
+	}
+
+	r := route.New(route.WithAsPath([]uint32{64499, 64496}))
+	if !MatchAspa(table, Upstream, AspaInvalid)(r) {
+		t.Error("Expected the leaked path to match invalid")
+	}
+}
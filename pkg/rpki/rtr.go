@@ -0,0 +1,376 @@
+package rpki
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/netip"
+	"time"
+)
+
+var (
+	ErrBadPdu        = errors.New("malformed RTR PDU")
+	ErrUnexpectedPdu = errors.New("unexpected RTR PDU")
+)
+
+// RTR PDU types
+const (
+	PduSerialNotify  = 0
+	PduSerialQuery   = 1
+	PduResetQuery    = 2
+	PduCacheResponse = 3
+	PduIPv4Prefix    = 4
+	PduIPv6Prefix    = 6
+	PduEndOfData     = 7
+	PduCacheReset    = 8
+	PduRouterKey     = 9
+	PduErrorReport   = 10
+)
+
+// RTR error codes
+const (
+	ErrCodeCorruptData        = 0
+	ErrCodeInternal           = 1
+	ErrCodeNoData             = 2
+	ErrCodeInvalidRequest     = 3
+	ErrCodeUnsupportedVersion = 4
+	ErrCodeUnsupportedPdu     = 5
+	ErrCodeUnknownWithdrawal  = 6
+	ErrCodeDuplicate          = 7
+)
+
+const (
+	rtrHeaderLen    = 8
+	maxPduLen       = 1 << 16 // Guards against corrupt length fields
+	flagAnnounce    = 1
+	defaultVersion  = 1
+	defaultRefresh  = time.Hour // RFC 8210 defaults, used until the cache sends its own
+	defaultRetry    = 10 * time.Minute
+	defaultExpire   = 2 * time.Hour
+	defaultDeadline = time.Minute
+)
+
+// ErrorReport is an Error Report PDU sent by the cache
+type ErrorReport struct {
+	Code uint16
+	Pdu  []byte // Erroneous PDU, if any
+	Text string
+}
+
+func (e *ErrorReport) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("rtr error %d", e.Code)
+	}
+	return fmt.Sprintf("rtr error %d: %s", e.Code, e.Text)
+}
+
+type pdu struct {
+	version uint8
+	typ     uint8
+	field   uint16 // Session ID, error code or zero depending on type
+	body    []byte
+}
+
+// Client keeps a Table in sync with an RPKI cache over the RPKI-to-Router protocol (RFC 8210).
+// It is driven by calls to Sync, typically every RefreshInterval.
+type Client struct {
+	rw      io.ReadWriter
+	version uint8
+	table   *Table
+	timeout time.Duration
+
+	vrps      map[Vrp]struct{} // VRPs learned from the cache
+	synced    bool
+	sessionID uint16
+	serial    uint32
+	refresh   time.Duration
+	retry     time.Duration
+	expire    time.Duration
+}
+
+// Create new Client speaking over rw
+func NewClient(rw io.ReadWriter, opts ...func(*Client)) *Client {
+	c := &Client{
+		rw:      rw,
+		version: defaultVersion,
+		timeout: defaultDeadline,
+		vrps:    make(map[Vrp]struct{}),
+		refresh: defaultRefresh,
+		retry:   defaultRetry,
+		expire:  defaultExpire,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.table == nil {
+		c.table = NewTable()
+	}
+	return c
+}
+
+// Dial connects to the cache at addr
+func Dial(ctx context.Context, addr string, opts ...func(*Client)) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...), nil
+}
+
+// Options
+
+// WithVersion sets the protocol version, 1 by default. Use 0 for caches only speaking RFC 6810.
+func WithVersion(v uint8) func(*Client) {
+	return func(c *Client) {
+		c.version = v
+	}
+}
+
+// WithTable keeps t in sync instead of a new table, so policies matching on it see updates
+func WithTable(t *Table) func(*Client) {
+	return func(c *Client) {
+		c.table = t
+	}
+}
+
+// WithTimeout bounds each Sync when the connection supports deadlines
+func WithTimeout(d time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// Getters
+
+func (c *Client) Table() *Table {
+	return c.table
+}
+
+// Serial returns the session ID and serial number of the data in sync, false before the first Sync
+func (c *Client) Serial() (uint16, uint32, bool) {
+	return c.sessionID, c.serial, c.synced
+}
+
+// RefreshInterval returns how long to wait before the next Sync, as advertised by the cache
+func (c *Client) RefreshInterval() time.Duration {
+	return c.refresh
+}
+
+// RetryInterval returns how long to wait before retrying a failed Sync
+func (c *Client) RetryInterval() time.Duration {
+	return c.retry
+}
+
+// ExpireInterval returns how long data may be used without a successful Sync
+func (c *Client) ExpireInterval() time.Duration {
+	return c.expire
+}
+
+// Sync fetches the changes since the last Sync, or the full data set on the first call or when the
+// cache cannot serve incremental updates. The table is only updated once the transfer is complete.
+func (c *Client) Sync() error {
+	if conn, ok := c.rw.(net.Conn); ok && c.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	if c.synced {
+		reset, err := c.transfer(true)
+		if err != nil || !reset {
+			return err
+		}
+	}
+	_, err := c.transfer(false)
+	return err
+}
+
+// Close closes the connection if it is an io.Closer
+func (c *Client) Close() error {
+	if cl, ok := c.rw.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// transfer runs a Serial or Reset Query exchange. Returns true if the cache asks for a reset instead.
+func (c *Client) transfer(incremental bool) (bool, error) {
+	query := pdu{version: c.version, typ: PduResetQuery}
+	if incremental {
+		query = pdu{version: c.version, typ: PduSerialQuery, field: c.sessionID, body: binary.BigEndian.AppendUint32(nil, c.serial)}
+	}
+	if err := c.write(query); err != nil {
+		return false, err
+	}
+
+	vrps := make(map[Vrp]struct{})
+	if incremental {
+		maps.Copy(vrps, c.vrps)
+	}
+	var sessionID uint16
+	responded := false
+	for {
+		p, err := c.read()
+		if err != nil {
+			return false, err
+		}
+		switch p.typ {
+		case PduSerialNotify:
+			continue
+		case PduCacheReset:
+			if !incremental || responded {
+				return false, ErrUnexpectedPdu
+			}
+			return true, nil
+		case PduCacheResponse:
+			if responded {
+				return false, ErrUnexpectedPdu
+			}
+			if incremental && p.field != c.sessionID {
+				// The cache restarted, its serial numbers are meaningless now
+				return true, nil
+			}
+			responded, sessionID = true, p.field
+			continue
+		}
+		if !responded {
+			return false, ErrUnexpectedPdu
+		}
+
+		switch p.typ {
+		case PduIPv4Prefix, PduIPv6Prefix:
+			v, announce, err := decodePrefixPdu(p)
+			if err != nil {
+				return false, err
+			}
+			if announce {
+				vrps[v] = struct{}{}
+			} else {
+				delete(vrps, v)
+			}
+		case PduRouterKey:
+		case PduEndOfData:
+			if err := c.endOfData(p, sessionID); err != nil {
+				return false, err
+			}
+			c.apply(vrps)
+			return false, nil
+		default:
+			return false, ErrUnexpectedPdu
+		}
+	}
+}
+
+func (c *Client) endOfData(p pdu, sessionID uint16) error {
+	if p.field != sessionID || len(p.body) < 4 {
+		return ErrBadPdu
+	}
+	c.serial = binary.BigEndian.Uint32(p.body)
+	if len(p.body) >= 16 {
+		c.refresh = time.Duration(binary.BigEndian.Uint32(p.body[4:])) * time.Second
+		c.retry = time.Duration(binary.BigEndian.Uint32(p.body[8:])) * time.Second
+		c.expire = time.Duration(binary.BigEndian.Uint32(p.body[12:])) * time.Second
+	}
+	c.sessionID = sessionID
+	c.synced = true
+	return nil
+}
+
+// apply replaces the VRPs learned from the cache in the table
+func (c *Client) apply(vrps map[Vrp]struct{}) {
+	for v := range c.vrps {
+		if _, ok := vrps[v]; !ok {
+			c.table.Remove(v)
+		}
+	}
+	for v := range vrps {
+		if _, ok := c.vrps[v]; !ok {
+			c.table.Add(v)
+		}
+	}
+	c.vrps = vrps
+}
+
+func decodePrefixPdu(p pdu) (Vrp, bool, error) {
+	addrLen := 4
+	if p.typ == PduIPv6Prefix {
+		addrLen = 16
+	}
+	b := p.body
+	if len(b) != 4+addrLen+4 {
+		return Vrp{}, false, ErrBadPdu
+	}
+	var addr netip.Addr
+	if addrLen == 4 {
+		addr = netip.AddrFrom4([4]byte(b[4:]))
+	} else {
+		addr = netip.AddrFrom16([16]byte(b[4:]))
+	}
+	bits, maxLength := int(b[1]), int(b[2])
+	if bits > addr.BitLen() || maxLength < bits || maxLength > addr.BitLen() {
+		return Vrp{}, false, ErrBadPdu
+	}
+	v := Vrp{
+		Prefix:    netip.PrefixFrom(addr, bits).Masked(),
+		MaxLength: maxLength,
+		Asn:       binary.BigEndian.Uint32(b[4+addrLen:]),
+	}
+	return v, b[0]&flagAnnounce != 0, nil
+}
+
+func (c *Client) write(p pdu) error {
+	b := []byte{p.version, p.typ}
+	b = binary.BigEndian.AppendUint16(b, p.field)
+	b = binary.BigEndian.AppendUint32(b, uint32(rtrHeaderLen+len(p.body)))
+	_, err := c.rw.Write(append(b, p.body...))
+	return err
+}
+
+// read reads the next PDU, returning Error Reports as errors
+func (c *Client) read() (pdu, error) {
+	var header [rtrHeaderLen]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return pdu{}, err
+	}
+	length := int(binary.BigEndian.Uint32(header[4:]))
+	if length < rtrHeaderLen || length > maxPduLen {
+		return pdu{}, ErrBadPdu
+	}
+	p := pdu{
+		version: header[0],
+		typ:     header[1],
+		field:   binary.BigEndian.Uint16(header[2:]),
+		body:    make([]byte, length-rtrHeaderLen),
+	}
+	if _, err := io.ReadFull(c.rw, p.body); err != nil {
+		return pdu{}, err
+	}
+	if p.typ == PduErrorReport {
+		return pdu{}, decodeErrorReport(p)
+	}
+	if p.version != c.version {
+		return pdu{}, ErrUnexpectedPdu
+	}
+	return p, nil
+}
+
+func decodeErrorReport(p pdu) error {
+	e := &ErrorReport{Code: p.field}
+	b := p.body
+	if len(b) < 4 {
+		return e
+	}
+	n := int(binary.BigEndian.Uint32(b))
+	if len(b) < 4+n+4 {
+		return e
+	}
+	e.Pdu, b = b[4:4+n], b[4+n:]
+	n = int(binary.BigEndian.Uint32(b))
+	if len(b) >= 4+n {
+		e.Text = string(b[4 : 4+n])
+	}
+	return e
+}
@@ -25,7 +25,7 @@ func (rp *RoutePool) Insert(route Route) bool {
 	h := route.Hash()
 	_, ok := rp.idxMap[h]
 	if !ok {
-		rp.idxMap[h] = len(rp.idxMap)
+		rp.idxMap[h] = len(rp.routes)
 		rp.routes = append(rp.routes, route)
 		rp.len++
 		rp.rehash()
@@ -63,7 +63,7 @@ func (rp *RoutePool) Len() int {
 
 func (rp *RoutePool) rehash() {
 	// Rehash on condition
-	if len(rp.routes)/rp.len < 2 {
+	if rp.len > 0 && len(rp.routes)/rp.len < 2 {
 		return
 	}
 
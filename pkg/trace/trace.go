@@ -0,0 +1,215 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+)
+
+const (
+	defaultTTL = 64
+)
+
+var (
+	ErrUnknownNode = errors.New("unknown node")
+)
+
+type Disposition int
+
+const (
+	Delivered  Disposition = iota // Reached a node address or left through an attached network
+	NoRoute                       // Dropped, no route or unresolvable next hop
+	NullRouted                    // Dropped by a discard route
+	Loop                          // Revisited a node
+	TTLExceeded
+)
+
+func (d Disposition) String() string {
+	switch d {
+	case Delivered:
+		return "delivered"
+	case NoRoute:
+		return "no route"
+	case NullRouted:
+		return "null routed"
+	case Loop:
+		return "loop"
+	case TTLExceeded:
+		return "ttl exceeded"
+	}
+	return "unknown"
+}
+
+// Dropped reports whether the packet was dropped without being delivered
+func (d Disposition) Dropped() bool {
+	return d == NoRoute || d == NullRouted
+}
+
+// Hop is a single forwarding decision
+type Hop struct {
+	Node   string
+	Prefix netip.Prefix // Matched prefix, invalid if no route matched
+	Iface  string       // Outgoing interface, empty on the last hop
+}
+
+// Path is one way a packet can be forwarded
+type Path struct {
+	Hops        []Hop
+	Disposition Disposition
+}
+
+// Last returns the node where the packet ended up
+func (p *Path) Last() string {
+	return p.Hops[len(p.Hops)-1].Node
+}
+
+func (p *Path) String() string {
+	var b strings.Builder
+	for i, h := range p.Hops {
+		if i != 0 {
+			b.WriteString(" -> ")
+		}
+		b.WriteString(h.Node)
+	}
+	fmt.Fprintf(&b, " (%s)", p.Disposition)
+	return b.String()
+}
+
+// Tracer simulates hop-by-hop forwarding over the RIBs of a converged topology
+type Tracer struct {
+	topology *bgp.BgpTopology
+	ttl      int
+}
+
+// Create new Tracer
+func New(topology *bgp.BgpTopology, opts ...func(*Tracer)) *Tracer {
+	t := &Tracer{
+		topology: topology,
+		ttl:      defaultTTL,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Options
+
+func WithTTL(ttl int) func(*Tracer) {
+	return func(t *Tracer) {
+		t.ttl = ttl
+	}
+}
+
+// Trace returns every path a packet from src to dst can take, following all ECMP branches
+func (t *Tracer) Trace(src string, dst netip.Addr) ([]Path, error) {
+	node, ok := t.topology.Node(src)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNode, src)
+	}
+
+	var paths []Path
+	t.walk(node, dst, t.ttl, make(map[string]bool), nil, &paths)
+	return paths, nil
+}
+
+// walk forwards a packet at node, appending finished paths
+func (t *Tracer) walk(node *bgp.BgpNode, dst netip.Addr, ttl int, visited map[string]bool, hops []Hop, paths *[]Path) {
+	name := node.Name()
+	finish := func(h Hop, d Disposition) {
+		*paths = append(*paths, Path{
+			Hops:        append(append([]Hop(nil), hops...), h),
+			Disposition: d,
+		})
+	}
+
+	if visited[name] {
+		finish(Hop{Node: name}, Loop)
+		return
+	}
+	if t.owns(name, dst) {
+		finish(Hop{Node: name}, Delivered)
+		return
+	}
+	if ttl == 0 {
+		finish(Hop{Node: name}, TTLExceeded)
+		return
+	}
+
+	pfx, routes, ok := node.Rib().Lookup(dst)
+	if !ok {
+		finish(Hop{Node: name}, NoRoute)
+		return
+	}
+
+	visited[name] = true
+	defer delete(visited, name)
+
+	for _, f := range forwardings(node.Rib(), routes) {
+		switch f.nh.Type() {
+		case nexthop.Discard:
+			finish(Hop{Node: name, Prefix: pfx}, NullRouted)
+		case nexthop.Interface:
+			hop := Hop{Node: name, Prefix: pfx, Iface: f.nh.Iface()}
+			peer, ok := t.topology.GetPeerByIface(name, f.nh.Iface())
+			// Leaves through an attached network
+			if !ok || (f.connected && peer.RemotePrefix.Addr() != dst) {
+				finish(hop, Delivered)
+				continue
+			}
+			t.walk(peer.RemoteNode, dst, ttl-1, visited, append(hops, hop), paths)
+		default:
+			finish(Hop{Node: name, Prefix: pfx}, NoRoute)
+		}
+	}
+}
+
+// owns reports whether dst is one of a node's interface addresses
+func (t *Tracer) owns(node string, dst netip.Addr) bool {
+	for _, p := range t.topology.GetPeers(node) {
+		if p.LocalPrefix.Addr() == dst {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarding is a resolved next hop
+type forwarding struct {
+	nh        nexthop.NextHop
+	connected bool // Directly attached destination
+}
+
+// forwardings resolves matched routes to distinct Interface and Discard next hops.
+// Unresolvable next hops are returned as nexthop.Invalid.
+func forwardings(r *rib.Rib, routes []rib.Route) []forwarding {
+	var fs []forwarding
+	seen := make(map[uint32]bool)
+	add := func(f forwarding) {
+		if h := f.nh.Hash(); !seen[h] {
+			seen[h] = true
+			fs = append(fs, f)
+		}
+	}
+
+	for _, route := range routes {
+		if route.NextHop.Type() != nexthop.IP {
+			add(forwarding{nh: route.NextHop, connected: route.Protocol == rib.Connected})
+			continue
+		}
+		resolved, err := r.Resolve(route.NextHop.IP())
+		if err != nil {
+			add(forwarding{nh: nexthop.New(nexthop.WithIP(route.NextHop.IP()), nexthop.WithType(nexthop.Invalid))})
+			continue
+		}
+		for _, nh := range resolved {
+			add(forwarding{nh: nh})
+		}
+	}
+	return fs
+}
@@ -0,0 +1,139 @@
+package trace
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+func link(b *bgp.BgpTopologyBuilder, local, remote *bgp.BgpNode, subnet string, localIface, remoteIface string) {
+	pfx := netip.MustParsePrefix(subnet)
+	b.AddPeer(bgp.BgpPeer{
+		LocalNode:    local,
+		RemoteNode:   remote,
+		LocalPrefix:  netip.PrefixFrom(pfx.Addr().Next(), pfx.Bits()),
+		RemotePrefix: netip.PrefixFrom(pfx.Addr().Next().Next(), pfx.Bits()),
+		LocalIface:   localIface,
+		RemoteIface:  remoteIface,
+	})
+}
+
+// Diamond a - {b, c} - d, d originates 203.0.113.0/24 from a stub interface
+func makeDiamond(t *testing.T) *bgp.BgpTopology {
+	a := bgp.NewBgpNode("a", 65001, bgp.WithMultipath(true))
+	b := bgp.NewBgpNode("b", 65002)
+	c := bgp.NewBgpNode("c", 65003)
+	d := bgp.NewBgpNode("d", 65004)
+
+	builder := &bgp.BgpTopologyBuilder{}
+	link(builder, a, b, "10.0.0.0/30", "eth0", "eth0")
+	link(builder, a, c, "10.0.0.4/30", "eth1", "eth0")
+	link(builder, b, d, "10.0.0.8/30", "eth1", "eth0")
+	link(builder, c, d, "10.0.0.12/30", "eth1", "eth1")
+
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	d.AddStaticRoute(bgp.StaticRoute{
+		Prefix:  prefix,
+		NextHop: nexthop.New(nexthop.WithInterface("stub0")),
+	})
+	d.AddNetwork(bgp.Network{Prefix: prefix})
+
+	topo := builder.Build()
+	if err := bgp.NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	return topo
+}
+
+func TestTrace_ECMP(t *testing.T) {
+	topo := makeDiamond(t)
+
+	paths, err := New(topo).Trace("a", netip.MustParseAddr("203.0.113.7"))
+	if err != nil {
+		t.Fatalf("Trace() = %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 ECMP paths, got %d: %v", len(paths), paths)
+	}
+	for _, p := range paths {
+		if p.Disposition != Delivered || p.Last() != "d" || len(p.Hops) != 3 {
+			t.Errorf("Unexpected path %s", p.String())
+		}
+	}
+}
+
+func TestTrace_Dispositions(t *testing.T) {
+	topo := makeDiamond(t)
+	tracer := New(topo)
+
+	tests := []struct {
+		name     string
+		src      string
+		dst      string
+		tracer   *Tracer
+		expected Disposition
+	}{
+		{
+			name:     "node address",
+			src:      "a",
+			dst:      "10.0.0.2",
+			tracer:   tracer,
+			expected: Delivered,
+		},
+		{
+			name:     "no route",
+			src:      "a",
+			dst:      "198.51.100.1",
+			tracer:   tracer,
+			expected: NoRoute,
+		},
+		{
+			name:     "ttl exceeded",
+			src:      "a",
+			dst:      "203.0.113.7",
+			tracer:   New(topo, WithTTL(1)),
+			expected: TTLExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := tt.tracer.Trace(tt.src, netip.MustParseAddr(tt.dst))
+			if err != nil {
+				t.Fatalf("Trace() = %v", err)
+			}
+			for _, p := range paths {
+				if p.Disposition != tt.expected {
+					t.Errorf("Trace() = %s, expected %s", p.String(), tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestTrace_LoopAndDiscard(t *testing.T) {
+	topo := makeDiamond(t)
+	a, _ := topo.Node("a")
+	b, _ := topo.Node("b")
+
+	loop := netip.MustParsePrefix("198.51.100.0/24")
+	a.AddStaticRoute(bgp.StaticRoute{Prefix: loop, NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.2")))})
+	b.AddStaticRoute(bgp.StaticRoute{Prefix: loop, NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.1")))})
+	b.AddStaticRoute(bgp.StaticRoute{Prefix: netip.MustParsePrefix("192.0.2.0/24"), NextHop: nexthop.New(nexthop.WithDiscard())})
+	a.AddStaticRoute(bgp.StaticRoute{Prefix: netip.MustParsePrefix("192.0.2.0/24"), NextHop: nexthop.New(nexthop.WithInterface("eth0"))})
+
+	tracer := New(topo)
+	paths, _ := tracer.Trace("a", netip.MustParseAddr("198.51.100.1"))
+	if len(paths) != 1 || paths[0].Disposition != Loop {
+		t.Errorf("Expected loop, got %v", paths)
+	}
+	paths, _ = tracer.Trace("a", netip.MustParseAddr("192.0.2.1"))
+	if len(paths) != 1 || paths[0].Disposition != NullRouted || paths[0].Last() != "b" {
+		t.Errorf("Expected null route at b, got %v", paths)
+	}
+	if _, err := tracer.Trace("z", netip.MustParseAddr("192.0.2.1")); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
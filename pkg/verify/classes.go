@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"net/netip"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/gaissmai/bart"
+)
+
+// Class is a set of destination addresses forwarded identically by every node.
+// It holds the addresses whose longest match among all RIB prefixes is Prefix.
+type Class struct {
+	Prefix         netip.Prefix
+	Representative netip.Addr // Example packet destination
+}
+
+// EquivalenceClasses partitions the destination space covered by the RIBs of all nodes.
+// Interface addresses get a class of their own since forwarding treats them specially.
+func EquivalenceClasses(topology *bgp.BgpTopology) []Class {
+	union := &bart.Table[struct{}]{}
+	for _, n := range topology.Nodes() {
+		for _, pfx := range n.Rib().Prefixes() {
+			union.Insert(pfx, struct{}{})
+		}
+		for _, p := range topology.GetPeers(n.Name()) {
+			addr := p.LocalPrefix.Addr()
+			union.Insert(netip.PrefixFrom(addr, addr.BitLen()), struct{}{})
+		}
+	}
+
+	var classes []Class
+	for pfx := range union.AllSorted() {
+		if rep, ok := representative(union, pfx); ok {
+			classes = append(classes, Class{Prefix: pfx, Representative: rep})
+		}
+	}
+	return classes
+}
+
+// representative finds the first address of pfx not covered by a more specific prefix in the table
+func representative(t *bart.Table[struct{}], pfx netip.Prefix) (netip.Addr, bool) {
+	candidate := pfx.Addr()
+	for sub := range t.Subnets(pfx) {
+		if sub == pfx {
+			continue
+		}
+		if sub.Addr().Compare(candidate) > 0 {
+			break // Gap before sub
+		}
+		if !sub.Contains(candidate) {
+			continue // Nested in a subnet already skipped
+		}
+		next := lastAddr(sub).Next()
+		if !next.IsValid() || !pfx.Contains(next) {
+			return netip.Addr{}, false
+		}
+		candidate = next
+	}
+	return candidate, true
+}
+
+// lastAddr returns the highest address of a prefix
+func lastAddr(pfx netip.Prefix) netip.Addr {
+	pfx = pfx.Masked()
+	if pfx.Addr().Is4() {
+		b := pfx.Addr().As4()
+		for i := range b {
+			b[i] |= hostMask(pfx.Bits(), i)
+		}
+		return netip.AddrFrom4(b)
+	}
+	b := pfx.Addr().As16()
+	for i := range b {
+		b[i] |= hostMask(pfx.Bits(), i)
+	}
+	return netip.AddrFrom16(b)
+}
+
+// hostMask returns the host bits of byte i for a prefix length
+func hostMask(bits int, i int) byte {
+	n := bits - i*8
+	switch {
+	case n <= 0:
+		return 0xff
+	case n >= 8:
+		return 0
+	}
+	return 0xff >> n
+}
@@ -0,0 +1,91 @@
+package verify
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+// PropagatedPath is the chain of nodes a selected route travelled from its origin
+type PropagatedPath struct {
+	Node          string
+	Prefix        netip.Prefix
+	Nodes         []string           // From the origin to Node
+	Relationships []bgp.Relationship // Of each sender to its receiver along Nodes
+}
+
+// ValleyFree reports whether the path climbs customer to provider links, crosses at most one peering
+// and then only descends provider to customer links
+func (p *PropagatedPath) ValleyFree() bool {
+	return ValleyFree(p.Relationships)
+}
+
+// Through reports whether the route was propagated by a node, excluding its origin and Node itself
+func (p *PropagatedPath) Through(node string) bool {
+	return len(p.Nodes) > 2 && slices.Contains(p.Nodes[1:len(p.Nodes)-1], node)
+}
+
+// ValleyFree checks a sequence of sender relationships in propagation order.
+// Sibling and Unspecified links, such as those inside an AS, are ignored.
+func ValleyFree(relationships []bgp.Relationship) bool {
+	descending := false
+	for _, r := range relationships {
+		switch r {
+		case bgp.Customer:
+			if descending {
+				return false
+			}
+		case bgp.Peer:
+			if descending {
+				return false
+			}
+			descending = true
+		case bgp.Provider:
+			descending = true
+		}
+	}
+	return true
+}
+
+// PropagatedPaths follows the best path of every node and prefix back to its origin.
+// Paths that do not lead back to an origin are left out. Results are ordered by node, then prefix.
+func PropagatedPaths(topology *bgp.BgpTopology) []PropagatedPath {
+	var paths []PropagatedPath
+	for _, n := range topology.Nodes() {
+		for _, prefix := range n.LocRibPrefixes() {
+			if p, ok := propagatedPath(topology, n, prefix); ok {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+func propagatedPath(topology *bgp.BgpTopology, n *bgp.BgpNode, prefix netip.Prefix) (PropagatedPath, bool) {
+	p := PropagatedPath{Node: n.Name(), Prefix: prefix}
+	visited := make(map[string]bool)
+	for !visited[n.Name()] {
+		visited[n.Name()] = true
+		p.Nodes = append(p.Nodes, n.Name())
+
+		rs, ok := n.LocRib(prefix)
+		if !ok {
+			return PropagatedPath{}, false
+		}
+		rx := rs.BestPath().ReceivedFrom()
+		if rx.Type() == route.Local {
+			slices.Reverse(p.Nodes)
+			slices.Reverse(p.Relationships)
+			return p, true
+		}
+		peer, ok := topology.GetPeerByRemoteAddr(n.Name(), rx.LinkLocalIP())
+		if !ok {
+			return PropagatedPath{}, false
+		}
+		p.Relationships = append(p.Relationships, peer.Relationship)
+		n = peer.RemoteNode
+	}
+	return PropagatedPath{}, false
+}
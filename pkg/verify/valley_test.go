@@ -0,0 +1,95 @@
+package verify
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+)
+
+func TestValleyFree(t *testing.T) {
+	const (
+		up   = bgp.Customer
+		down = bgp.Provider
+		flat = bgp.Peer
+		none = bgp.Unspecified
+	)
+	tests := []struct {
+		name     string
+		rels     []bgp.Relationship
+		expected bool
+	}{
+		{name: "empty", rels: nil, expected: true},
+		{name: "up down", rels: []bgp.Relationship{up, up, down, down}, expected: true},
+		{name: "up peer down", rels: []bgp.Relationship{up, flat, down}, expected: true},
+		{name: "internal links", rels: []bgp.Relationship{up, none, flat, none, down}, expected: true},
+		{name: "down up", rels: []bgp.Relationship{down, up}, expected: false},
+		{name: "two peerings", rels: []bgp.Relationship{flat, flat}, expected: false},
+		{name: "peer up", rels: []bgp.Relationship{flat, up}, expected: false},
+		{name: "down peer", rels: []bgp.Relationship{down, flat}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValleyFree(tt.rels); got != tt.expected {
+				t.Errorf("ValleyFree(%v) = %t, expected %t", tt.rels, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPropagatedPaths(t *testing.T) {
+	// p is the provider of both a and b, b originates the prefix
+	p := bgp.NewBgpNode("p", 65000)
+	a := bgp.NewBgpNode("a", 65001)
+	b := bgp.NewBgpNode("b", 65002)
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    p,
+		RemoteNode:   a,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+		Relationship: bgp.Customer,
+	})
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    p,
+		RemoteNode:   b,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.5/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.6/30"),
+		LocalIface:   "eth1",
+		RemoteIface:  "eth0",
+		Relationship: bgp.Customer,
+	})
+	prefix := netip.MustParsePrefix("203.0.113.0/24")
+	b.Originate(route.New(route.WithPrefix(prefix)))
+
+	topo := builder.Build()
+	if err := bgp.NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var found bool
+	for _, path := range PropagatedPaths(topo) {
+		if path.Prefix != prefix {
+			continue
+		}
+		if !path.ValleyFree() {
+			t.Errorf("Expected %v to be valley-free", path)
+		}
+		if path.Node != "a" {
+			continue
+		}
+		found = true
+		if len(path.Nodes) != 3 || path.Nodes[0] != "b" || path.Nodes[2] != "a" || !path.Through("p") {
+			t.Errorf("Expected a path b -> p -> a, got %v", path.Nodes)
+		}
+		if path.Relationships[0] != bgp.Customer || path.Relationships[1] != bgp.Provider {
+			t.Errorf("Expected an up then down path, got %v", path.Relationships)
+		}
+	}
+	if !found {
+		t.Error("Expected a path for a")
+	}
+}
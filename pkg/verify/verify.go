@@ -0,0 +1,153 @@
+package verify
+
+import (
+	"net/netip"
+	"runtime"
+	"sync"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/trace"
+)
+
+// Violation is a forwarding path that does not deliver the packet
+type Violation struct {
+	Source string
+	Class  netip.Prefix
+	Packet netip.Addr // Example destination
+	Path   trace.Path
+}
+
+// Loop reports whether the violation is a forwarding loop.
+// Paths exceeding the TTL are treated as loops.
+func (v *Violation) Loop() bool {
+	return v.Path.Disposition == trace.Loop || v.Path.Disposition == trace.TTLExceeded
+}
+
+// BlackHole reports whether the packet was dropped
+func (v *Violation) BlackHole() bool {
+	return v.Path.Disposition.Dropped()
+}
+
+// Report holds the result of a verification pass
+type Report struct {
+	Classes    int
+	Violations []Violation
+}
+
+// Loops returns the loop violations
+func (r *Report) Loops() []Violation {
+	return r.filter((*Violation).Loop)
+}
+
+// BlackHoles returns the black hole violations
+func (r *Report) BlackHoles() []Violation {
+	return r.filter((*Violation).BlackHole)
+}
+
+func (r *Report) filter(f func(*Violation) bool) []Violation {
+	var vs []Violation
+	for i := range r.Violations {
+		if f(&r.Violations[i]) {
+			vs = append(vs, r.Violations[i])
+		}
+	}
+	return vs
+}
+
+// Verifier checks every source node against every destination equivalence class
+type Verifier struct {
+	topology     *bgp.BgpTopology
+	tracer       *trace.Tracer
+	destinations []netip.Prefix
+	workers      int
+}
+
+// Create new Verifier
+func New(topology *bgp.BgpTopology, opts ...func(*Verifier)) *Verifier {
+	v := &Verifier{
+		topology: topology,
+		tracer:   trace.New(topology),
+		workers:  runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Options
+
+// WithDestinations restricts verification to classes overlapping the given prefixes
+func WithDestinations(prefixes ...netip.Prefix) func(*Verifier) {
+	return func(v *Verifier) {
+		v.destinations = prefixes
+	}
+}
+
+func WithTracer(tracer *trace.Tracer) func(*Verifier) {
+	return func(v *Verifier) {
+		v.tracer = tracer
+	}
+}
+
+func WithWorkers(workers int) func(*Verifier) {
+	return func(v *Verifier) {
+		v.workers = max(workers, 1)
+	}
+}
+
+// Verify traces a representative packet of every class from every node.
+// Violations are ordered by class, then source.
+func (v *Verifier) Verify() *Report {
+	classes := v.classes()
+	nodes := v.topology.Nodes()
+	results := make([][]Violation, len(classes))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, v.workers)
+	for i, c := range classes {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			for _, n := range nodes {
+				paths, _ := v.tracer.Trace(n.Name(), c.Representative)
+				for _, p := range paths {
+					if p.Disposition == trace.Delivered {
+						continue
+					}
+					results[i] = append(results[i], Violation{
+						Source: n.Name(),
+						Class:  c.Prefix,
+						Packet: c.Representative,
+						Path:   p,
+					})
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	report := &Report{Classes: len(classes)}
+	for _, vs := range results {
+		report.Violations = append(report.Violations, vs...)
+	}
+	return report
+}
+
+func (v *Verifier) classes() []Class {
+	classes := EquivalenceClasses(v.topology)
+	if len(v.destinations) == 0 {
+		return classes
+	}
+
+	var filtered []Class
+	for _, c := range classes {
+		for _, d := range v.destinations {
+			if d.Overlaps(c.Prefix) {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
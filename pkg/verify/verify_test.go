@@ -0,0 +1,97 @@
+package verify
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/gaissmai/bart"
+)
+
+func TestRepresentative(t *testing.T) {
+	table := &bart.Table[struct{}]{}
+	for _, p := range []string{"10.0.0.0/8", "10.0.0.0/9", "10.0.0.0/16", "10.128.0.0/9", "192.0.2.0/24", "192.0.2.0/25"} {
+		table.Insert(netip.MustParsePrefix(p), struct{}{})
+	}
+
+	tests := []struct {
+		prefix   string
+		expected string // Empty if fully covered
+	}{
+		{prefix: "10.0.0.0/8", expected: ""},
+		{prefix: "10.0.0.0/9", expected: "10.1.0.0"},
+		{prefix: "10.0.0.0/16", expected: "10.0.0.0"},
+		{prefix: "192.0.2.0/24", expected: "192.0.2.128"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			rep, ok := representative(table, netip.MustParsePrefix(tt.prefix))
+			if tt.expected == "" {
+				if ok {
+					t.Errorf("representative() = %s, expected empty class", rep)
+				}
+				return
+			}
+			if !ok || rep != netip.MustParseAddr(tt.expected) {
+				t.Errorf("representative() = %s, %t, expected %s", rep, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	a := bgp.NewBgpNode("a", 65001)
+	b := bgp.NewBgpNode("b", 65002)
+	builder := &bgp.BgpTopologyBuilder{}
+	builder.AddPeer(bgp.BgpPeer{
+		LocalNode:    a,
+		RemoteNode:   b,
+		LocalPrefix:  netip.MustParsePrefix("10.0.0.1/30"),
+		RemotePrefix: netip.MustParsePrefix("10.0.0.2/30"),
+		LocalIface:   "eth0",
+		RemoteIface:  "eth0",
+	})
+
+	good := netip.MustParsePrefix("203.0.113.0/24")
+	b.AddStaticRoute(bgp.StaticRoute{Prefix: good, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+	b.AddNetwork(bgp.Network{Prefix: good})
+
+	loop := netip.MustParsePrefix("198.51.100.0/24")
+	a.AddStaticRoute(bgp.StaticRoute{Prefix: loop, NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.2")))})
+	b.AddStaticRoute(bgp.StaticRoute{Prefix: loop, NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.1")))})
+
+	hole := netip.MustParsePrefix("192.0.2.0/24")
+	a.AddStaticRoute(bgp.StaticRoute{Prefix: hole, NextHop: nexthop.New(nexthop.WithIP(netip.MustParseAddr("10.0.0.2")))})
+
+	topo := builder.Build()
+	if err := bgp.NewSimulation(topo).Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	report := New(topo, WithDestinations(good, loop, hole)).Verify()
+	if report.Classes != 3 {
+		t.Errorf("Expected 3 classes, got %d", report.Classes)
+	}
+
+	loops := report.Loops()
+	if len(loops) != 2 {
+		t.Errorf("Expected a loop from both nodes, got %v", loops)
+	}
+	for _, v := range loops {
+		if v.Class != loop {
+			t.Errorf("Unexpected loop for %s", v.Class)
+		}
+	}
+
+	holes := report.BlackHoles()
+	if len(holes) != 2 {
+		t.Fatalf("Expected a black hole from both nodes, got %v", holes)
+	}
+	for _, v := range holes {
+		if v.Class != hole || v.Path.Last() != "b" || !hole.Contains(v.Packet) {
+			t.Errorf("Unexpected black hole %s from %s: %s", v.Class, v.Source, v.Path.String())
+		}
+	}
+}
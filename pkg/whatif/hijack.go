@@ -0,0 +1,215 @@
+package whatif
+
+import (
+	"errors"
+	"maps"
+	"net/netip"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/diff"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/trace"
+)
+
+var (
+	ErrUnknownNode = errors.New("unknown node")
+	ErrNoOrigin    = errors.New("no legitimate origin covers the hijacked prefix")
+)
+
+// Hijack announces a prefix from a node that does not own it
+type Hijack struct {
+	Node   string
+	Prefix netip.Prefix // Exact prefix or a more-specific of a legitimately originated prefix
+	AsPath []uint32     // Forged path behind the hijacker's own AS, e.g. the victim AS
+}
+
+// Verdict tells where a node's traffic for the hijacked prefix ends up
+type Verdict int
+
+const (
+	Legitimate  Verdict = iota // Reaches a legitimate origin
+	Hijacked                   // Reaches the hijacker
+	Split                      // Some paths reach each
+	Unreachable                // Reaches neither
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Legitimate:
+		return "legitimate"
+	case Hijacked:
+		return "hijacked"
+	case Split:
+		return "split"
+	case Unreachable:
+		return "unreachable"
+	}
+	return "unknown"
+}
+
+// HijackResult holds the network after a hijack and the verdict of every other node
+type HijackResult struct {
+	Result
+	Covering netip.Prefix // Legitimate prefix containing the hijacked one
+	Origins  []string     // Legitimate origins of Covering
+	Verdicts map[string]Verdict
+}
+
+// Nodes returns the nodes with a verdict, ordered by name
+func (r *HijackResult) Nodes(v Verdict) []string {
+	var nodes []string
+	for _, name := range slices.Sorted(maps.Keys(r.Verdicts)) {
+		if r.Verdicts[name] == v {
+			nodes = append(nodes, name)
+		}
+	}
+	return nodes
+}
+
+// HijackedFraction returns the fraction of nodes whose traffic reaches the hijacker only.
+// The legitimate origins are not counted, like the hijacker they hold their own route.
+func (r *HijackResult) HijackedFraction() float64 {
+	var judged, hijacked int
+	for name, v := range r.Verdicts {
+		if slices.Contains(r.Origins, name) {
+			continue
+		}
+		judged++
+		if v == Hijacked {
+			hijacked++
+		}
+	}
+	if judged == 0 {
+		return 0
+	}
+	return float64(hijacked) / float64(judged)
+}
+
+// RunHijack injects a hijack into a copy of a converged simulation and reconverges.
+// Exact-prefix hijacks are judged by following best paths, more-specifics by tracing packets
+// since nodes keep selecting the legitimate covering prefix.
+func RunHijack(baseline *bgp.Simulation, h Hijack) (*HijackResult, error) {
+	covering, origins := legitimateOrigins(baseline.Topology(), h.Prefix)
+	if len(origins) == 0 {
+		return nil, ErrNoOrigin
+	}
+
+	sim := baseline.Clone()
+	attacker, ok := sim.Topology().Node(h.Node)
+	if !ok {
+		return nil, ErrUnknownNode
+	}
+	// Traffic attracted by the hijacker is dropped there
+	attacker.AddStaticRoute(bgp.StaticRoute{Prefix: h.Prefix, NextHop: nexthop.New(nexthop.WithDiscard())})
+	attacker.Originate(route.New(
+		route.WithPrefix(h.Prefix),
+		route.WithAsPath(slices.Clone(h.AsPath)),
+	))
+	if err := sim.Run(); err != nil {
+		return nil, err
+	}
+
+	result := &HijackResult{
+		Result: Result{
+			Simulation: sim,
+			Diffs:      diff.Compare(baseline.Topology(), sim.Topology()),
+		},
+		Covering: covering,
+		Origins:  origins,
+		Verdicts: make(map[string]Verdict),
+	}
+
+	tracer := trace.New(sim.Topology())
+	for _, n := range sim.Topology().Nodes() {
+		if n.Name() == h.Node {
+			continue
+		}
+		var ends []string
+		if covering == h.Prefix {
+			ends = followBestPath(sim.Topology(), n, h.Prefix)
+		} else {
+			paths, err := tracer.Trace(n.Name(), h.Prefix.Addr())
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range paths {
+				if p.Disposition == trace.Delivered || p.Last() == h.Node {
+					ends = append(ends, p.Last())
+				}
+			}
+		}
+		result.Verdicts[n.Name()] = judge(ends, h.Node, origins)
+	}
+	return result, nil
+}
+
+// legitimateOrigins returns the longest prefix containing prefix that is originated in the topology,
+// and the nodes originating it
+func legitimateOrigins(topo *bgp.BgpTopology, prefix netip.Prefix) (netip.Prefix, []string) {
+	var covering netip.Prefix
+	var origins []string
+	for _, n := range topo.Nodes() {
+		for _, p := range n.LocRibPrefixes() {
+			if p.Bits() > prefix.Bits() || !p.Contains(prefix.Addr()) {
+				continue
+			}
+			rs, _ := n.LocRib(p)
+			if rx := rs.BestPath().ReceivedFrom(); rx.Type() != route.Local {
+				continue
+			}
+			switch {
+			case !covering.IsValid() || p.Bits() > covering.Bits():
+				covering, origins = p, []string{n.Name()}
+			case p == covering:
+				origins = append(origins, n.Name())
+			}
+		}
+	}
+	return covering, origins
+}
+
+// followBestPath walks best paths from a node to the node originating them.
+// Returns nil if the walk ends without reaching an origin.
+func followBestPath(topo *bgp.BgpTopology, n *bgp.BgpNode, prefix netip.Prefix) []string {
+	visited := make(map[string]bool)
+	for !visited[n.Name()] {
+		visited[n.Name()] = true
+		rs, ok := n.LocRib(prefix)
+		if !ok {
+			return nil
+		}
+		rx := rs.BestPath().ReceivedFrom()
+		if rx.Type() == route.Local {
+			return []string{n.Name()}
+		}
+		peer, ok := topo.GetPeerByRemoteAddr(n.Name(), rx.LinkLocalIP())
+		if !ok {
+			return nil
+		}
+		n = peer.RemoteNode
+	}
+	return nil
+}
+
+func judge(ends []string, attacker string, origins []string) Verdict {
+	var legitimate, hijacked bool
+	for _, e := range ends {
+		switch {
+		case e == attacker:
+			hijacked = true
+		case slices.Contains(origins, e):
+			legitimate = true
+		}
+	}
+	switch {
+	case legitimate && hijacked:
+		return Split
+	case hijacked:
+		return Hijacked
+	case legitimate:
+		return Legitimate
+	}
+	return Unreachable
+}
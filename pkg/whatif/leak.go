@@ -0,0 +1,63 @@
+package whatif
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/diff"
+	"github.com/HT4w5/bgpsim-go/pkg/verify"
+)
+
+// LeakResult holds the network after a node started leaking and the paths carrying the leak
+type LeakResult struct {
+	Result
+	Leaker string
+	Leaked []verify.PropagatedPath // Best paths through the leaker that are not valley-free
+}
+
+// Affected returns the nodes selecting at least one leaked path, ordered by name
+func (r *LeakResult) Affected() []string {
+	nodes := make(map[string]struct{})
+	for _, p := range r.Leaked {
+		nodes[p.Node] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(nodes))
+}
+
+// AffectedFraction returns the fraction of the other nodes selecting at least one leaked path
+func (r *LeakResult) AffectedFraction() float64 {
+	others := len(r.Simulation.Topology().Nodes()) - 1
+	if others <= 0 {
+		return 0
+	}
+	return float64(len(r.Affected())) / float64(others)
+}
+
+// RunLeak turns a node of a copy of a converged simulation into a leaker, exporting provider and peer
+// routes to other providers and peers, and reconverges
+func RunLeak(baseline *bgp.Simulation, leaker string) (*LeakResult, error) {
+	sim := baseline.Clone()
+	n, ok := sim.Topology().Node(leaker)
+	if !ok {
+		return nil, ErrUnknownNode
+	}
+	n.SetLeaker(true)
+	if err := sim.Run(); err != nil {
+		return nil, err
+	}
+
+	result := &LeakResult{
+		Result: Result{
+			Simulation: sim,
+			Diffs:      diff.Compare(baseline.Topology(), sim.Topology()),
+		},
+		Leaker: leaker,
+	}
+	for _, p := range verify.PropagatedPaths(sim.Topology()) {
+		if p.Through(leaker) && !p.ValleyFree() {
+			result.Leaked = append(result.Leaked, p)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,278 @@
+package whatif
+
+import (
+	"net/netip"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+	"github.com/HT4w5/bgpsim-go/pkg/rib"
+	"github.com/HT4w5/bgpsim-go/pkg/trace"
+)
+
+// Predicate reports whether a converged network satisfies a property
+type Predicate func(*bgp.Simulation) bool
+
+// ReachableFrom requires every forwarding path from each node towards dst to be delivered
+func ReachableFrom(dst netip.Addr, nodes ...string) Predicate {
+	return func(sim *bgp.Simulation) bool {
+		tracer := trace.New(sim.Topology())
+		for _, n := range nodes {
+			paths, err := tracer.Trace(n, dst)
+			if err != nil || len(paths) == 0 {
+				return false
+			}
+			for _, p := range paths {
+				if p.Disposition != trace.Delivered {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// Outcome is a failure combination violating the predicate
+type Outcome struct {
+	Links        []bgp.LinkID
+	EquivalentTo []bgp.LinkID // Evaluated combination this one was pruned to, nil if simulated
+}
+
+// ResilienceReport holds the result of a k-failure analysis
+type ResilienceReport struct {
+	Evaluated  int // Combinations simulated
+	Pruned     int // Combinations skipped as equivalent to a smaller one
+	Violations []Outcome
+}
+
+// Resilience enumerates every combination of up to k link failures
+type Resilience struct {
+	k         int
+	predicate Predicate
+	workers   int
+	pruning   bool
+}
+
+// Create new Resilience
+func NewResilience(k int, predicate Predicate, opts ...func(*Resilience)) *Resilience {
+	r := &Resilience{
+		k:         k,
+		predicate: predicate,
+		workers:   runtime.GOMAXPROCS(0),
+		pruning:   true,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Options
+
+func WithWorkers(workers int) func(*Resilience) {
+	return func(r *Resilience) {
+		r.workers = max(workers, 1)
+	}
+}
+
+// WithPruning toggles pruning of equivalent failures.
+// A combination is pruned when one of its links carries nothing in the converged state of the remaining links:
+// no selected route was learned over it, no forwarding entry resolves through it and its subnet is not advertised.
+// Predicates must then not depend on the connected routes of the failed link itself.
+func WithPruning(pruning bool) func(*Resilience) {
+	return func(r *Resilience) {
+		r.pruning = pruning
+	}
+}
+
+// failureState is the outcome of a failure combination
+type failureState struct {
+	violated   bool
+	used       []bool // Per link, whether the converged state depends on it
+	equivalent []int  // Evaluated combination, nil if simulated
+}
+
+// Run evaluates every combination of 0 to k link failures on copies of a converged simulation.
+// Combinations of the same size are simulated in parallel.
+// Returns ErrNonDeterministic unless every node is deterministic, like Run.
+func (r *Resilience) Run(baseline *bgp.Simulation) (*ResilienceReport, error) {
+	links := baseline.Topology().Links()
+	ids := make([]bgp.LinkID, len(links))
+	for i := range links {
+		ids[i] = links[i].ID()
+	}
+
+	report := &ResilienceReport{}
+	states := make(map[string]*failureState)
+	states[comboKey(nil)] = r.inspect(baseline, links)
+
+	for size := 1; size <= r.k && size <= len(links); size++ {
+		var pending [][]int
+		for _, c := range combinations(len(links), size) {
+			if s, ok := r.prune(c, states); ok {
+				states[comboKey(c)] = s
+				report.Pruned++
+				continue
+			}
+			pending = append(pending, c)
+		}
+
+		results := make([]*failureState, len(pending))
+		errs := make([]error, len(pending))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, r.workers)
+		for i, c := range pending {
+			sem <- struct{}{}
+			wg.Go(func() {
+				defer func() { <-sem }()
+				sim, err := apply(baseline, Scenario{Links: pick(ids, c)})
+				if errs[i] = err; err == nil {
+					results[i] = r.inspect(sim, links)
+				}
+			})
+		}
+		wg.Wait()
+
+		for i, c := range pending {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			states[comboKey(c)] = results[i]
+		}
+		report.Evaluated += len(pending)
+	}
+
+	for size := 0; size <= r.k && size <= len(links); size++ {
+		for _, c := range combinations(len(links), size) {
+			s := states[comboKey(c)]
+			if !s.violated {
+				continue
+			}
+			o := Outcome{Links: pick(ids, c)}
+			if s.equivalent != nil {
+				o.EquivalentTo = pick(ids, s.equivalent)
+			}
+			report.Violations = append(report.Violations, o)
+		}
+	}
+	return report, nil
+}
+
+// prune finds a smaller evaluated combination with the same converged state
+func (r *Resilience) prune(c []int, states map[string]*failureState) (*failureState, bool) {
+	if !r.pruning {
+		return nil, false
+	}
+	for i, l := range c {
+		sub := append(append([]int(nil), c[:i]...), c[i+1:]...)
+		s, ok := states[comboKey(sub)]
+		if !ok || s.used[l] {
+			continue
+		}
+		equivalent := s.equivalent
+		if equivalent == nil {
+			equivalent = sub
+		}
+		return &failureState{violated: s.violated, used: s.used, equivalent: equivalent}, true
+	}
+	return nil, false
+}
+
+// inspect evaluates the predicate and the links a converged simulation depends on
+func (r *Resilience) inspect(sim *bgp.Simulation, links []bgp.BgpPeer) *failureState {
+	index := make(map[bgp.LinkID]int)
+	subnets := make(map[netip.Prefix]int)
+	for i := range links {
+		index[links[i].ID()] = i
+		index[bgp.LinkID{Node: links[i].RemoteNode.Name(), Iface: links[i].RemoteIface}] = i
+		subnets[links[i].LocalPrefix.Masked()] = i
+	}
+
+	used := make([]bool, len(links))
+	mark := func(id bgp.LinkID) {
+		if i, ok := index[id]; ok {
+			used[i] = true
+		}
+	}
+
+	topo := sim.Topology()
+	for _, n := range topo.Nodes() {
+		for _, p := range n.LocRibPrefixes() {
+			if i, ok := subnets[p]; ok {
+				used[i] = true
+			}
+			rs, _ := n.LocRib(p)
+			for _, br := range append(rs.MultipathSet(), rs.BestPath()) {
+				rx := br.ReceivedFrom()
+				if rx.Type() != route.IP {
+					continue
+				}
+				if peer, ok := topo.GetPeerByRemoteAddr(n.Name(), rx.LinkLocalIP()); ok {
+					mark(peer.ID())
+				}
+			}
+		}
+
+		for _, rr := range n.Rib().GetActiveRoutes() {
+			if rr.Protocol == rib.Connected {
+				continue
+			}
+			nhs := []nexthop.NextHop{rr.NextHop}
+			if rr.NextHop.Type() == nexthop.IP {
+				nhs, _ = n.Rib().Resolve(rr.NextHop.IP())
+			}
+			for _, nh := range nhs {
+				if nh.Type() == nexthop.Interface {
+					mark(bgp.LinkID{Node: n.Name(), Iface: nh.Iface()})
+				}
+			}
+		}
+	}
+
+	return &failureState{
+		violated: !r.predicate(sim),
+		used:     used,
+	}
+}
+
+// combinations returns all size-element subsets of [0, n) in lexicographic order
+func combinations(n, size int) [][]int {
+	var combos [][]int
+	c := make([]int, size)
+	var rec func(start, depth int)
+	rec = func(start, depth int) {
+		if depth == size {
+			combos = append(combos, append([]int(nil), c...))
+			return
+		}
+		for i := start; i <= n-(size-depth); i++ {
+			c[depth] = i
+			rec(i+1, depth+1)
+		}
+	}
+	rec(0, 0)
+	return combos
+}
+
+func comboKey(c []int) string {
+	var b strings.Builder
+	for i, l := range c {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(l))
+	}
+	return b.String()
+}
+
+func pick(ids []bgp.LinkID, c []int) []bgp.LinkID {
+	picked := make([]bgp.LinkID, len(c))
+	for i, l := range c {
+		picked[i] = ids[l]
+	}
+	return picked
+}
@@ -0,0 +1,362 @@
+package whatif
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/HT4w5/bgpsim-go/pkg/bgp"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/gaorexford"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/policy"
+	"github.com/HT4w5/bgpsim-go/pkg/bgp/route"
+	"github.com/HT4w5/bgpsim-go/pkg/diff"
+	"github.com/HT4w5/bgpsim-go/pkg/nexthop"
+)
+
+var target = netip.MustParsePrefix("203.0.113.0/24")
+
+func link(b *bgp.BgpTopologyBuilder, local, remote *bgp.BgpNode, subnet string, localIface, remoteIface string) {
+	pfx := netip.MustParsePrefix(subnet)
+	b.AddPeer(bgp.BgpPeer{
+		LocalNode:    local,
+		RemoteNode:   remote,
+		LocalPrefix:  netip.PrefixFrom(pfx.Addr().Next(), pfx.Bits()),
+		RemotePrefix: netip.PrefixFrom(pfx.Addr().Next().Next(), pfx.Bits()),
+		LocalIface:   localIface,
+		RemoteIface:  remoteIface,
+	})
+}
+
+// Diamond a - {b, c} - d, d originates 203.0.113.0/24. Nodes are deterministic unless opts say otherwise.
+func makeBaseline(t *testing.T, opts ...func(*bgp.BgpNode)) *bgp.Simulation {
+	opts = append([]func(*bgp.BgpNode){bgp.WithDeterministic(true)}, opts...)
+	a := bgp.NewBgpNode("a", 65001, opts...)
+	b := bgp.NewBgpNode("b", 65002, opts...)
+	c := bgp.NewBgpNode("c", 65003, opts...)
+	d := bgp.NewBgpNode("d", 65004, opts...)
+
+	builder := &bgp.BgpTopologyBuilder{}
+	link(builder, a, b, "10.0.0.0/30", "eth0", "eth0")
+	link(builder, a, c, "10.0.0.4/30", "eth1", "eth0")
+	link(builder, b, d, "10.0.0.8/30", "eth1", "eth0")
+	link(builder, c, d, "10.0.0.12/30", "eth1", "eth1")
+
+	d.AddStaticRoute(bgp.StaticRoute{Prefix: target, NextHop: nexthop.New(nexthop.WithInterface("stub0"))})
+	d.AddNetwork(bgp.Network{Prefix: target})
+
+	sim := bgp.NewSimulation(builder.Build())
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	return sim
+}
+
+func TestRun_NonDeterministic(t *testing.T) {
+	baseline := makeBaseline(t, bgp.WithDeterministic(false))
+	if _, err := Run(baseline, Scenario{}); err != ErrNonDeterministic {
+		t.Errorf("Expected ErrNonDeterministic, got %v", err)
+	}
+}
+
+func TestRun_NoFailure(t *testing.T) {
+	baseline := makeBaseline(t)
+	result, err := Run(baseline, Scenario{})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if len(result.Diffs) != 0 {
+		t.Errorf("Expected no differences, got %d", len(result.Diffs))
+	}
+}
+
+func TestRun_LinkFailure(t *testing.T) {
+	baseline := makeBaseline(t)
+	a, _ := baseline.Topology().Node("a")
+	rs, _ := a.LocRib(target)
+	before := rs.BestPath().AsPath()[0]
+
+	// Fail the link towards the preferred neighbor of a
+	failed := bgp.LinkID{Node: "d", Iface: "eth0"}
+	if before == 65003 {
+		failed = bgp.LinkID{Node: "d", Iface: "eth1"}
+	}
+	result, err := Run(baseline, Scenario{Links: []bgp.LinkID{failed}})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var found bool
+	for _, d := range result.Diffs {
+		if d.Node != "a" || d.Prefix != target {
+			continue
+		}
+		found = true
+		if !d.BestChanged() || !d.FibChanged() || d.Unreachable() {
+			t.Errorf("Expected a to fail over, got %+v", d)
+		}
+		if d.BestAfter.AsPath()[0] == before {
+			t.Errorf("Expected a to switch away from AS %d", before)
+		}
+	}
+	if !found {
+		t.Error("Expected a diff for a")
+	}
+
+	// Baseline is untouched
+	if rs, _ := a.LocRib(target); rs.BestPath().AsPath()[0] != before {
+		t.Error("Expected baseline to be unchanged")
+	}
+}
+
+func TestRun_Unreachable(t *testing.T) {
+	baseline := makeBaseline(t)
+	result, err := Run(baseline, Scenario{Nodes: []string{"b", "c"}})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	unreachable := result.Unreachable()
+	var found bool
+	for _, d := range unreachable {
+		if d.Node == "a" && d.Prefix == target {
+			found = true
+		}
+		if d.Node == "b" || d.Node == "c" {
+			t.Errorf("Unexpected diff for failed node %s", d.Node)
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s to be unreachable from a, got %v", target, unreachable)
+	}
+}
+
+func TestRun_MatchesFromScratch(t *testing.T) {
+	baseline := makeBaseline(t, bgp.WithMultipath(true))
+	var scenarios []Scenario
+	for _, l := range baseline.Topology().Links() {
+		scenarios = append(scenarios, Scenario{Links: []bgp.LinkID{l.ID()}})
+	}
+	scenarios = append(scenarios,
+		Scenario{Nodes: []string{"b"}},
+		Scenario{Nodes: []string{"d"}},
+		Scenario{Links: []bgp.LinkID{{Node: "a", Iface: "eth0"}}, Nodes: []string{"c"}},
+	)
+
+	for _, sc := range scenarios {
+		incremental, err := Run(baseline, sc)
+		if err != nil {
+			t.Fatalf("Run(%v) = %v", sc, err)
+		}
+		scratch, err := RunFromScratch(baseline, sc)
+		if err != nil {
+			t.Fatalf("RunFromScratch(%v) = %v", sc, err)
+		}
+		if d := diff.Compare(scratch.Simulation.Topology(), incremental.Simulation.Topology()); len(d) != 0 {
+			t.Errorf("Scenario %v: incremental result differs from scratch: %+v", sc, d)
+		}
+	}
+}
+
+func TestRun_LinkRestored(t *testing.T) {
+	baseline := makeBaseline(t)
+	sim := baseline.Clone()
+	removed, _ := sim.Topology().GetPeerByIface("b", "eth1")
+	if !sim.RemoveLink(removed.ID()) {
+		t.Fatal("Expected link to be removed")
+	}
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	sim.AddLink(removed)
+	if err := sim.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if d := diff.Compare(baseline.Topology(), sim.Topology()); len(d) != 0 {
+		t.Errorf("Expected baseline to be restored, got %+v", d)
+	}
+}
+
+func TestResilience(t *testing.T) {
+	baseline := makeBaseline(t)
+	predicate := ReachableFrom(netip.MustParseAddr("203.0.113.7"), "a")
+
+	cuts := func(report *ResilienceReport) map[string]bool {
+		m := make(map[string]bool)
+		for _, o := range report.Violations {
+			var key string
+			for _, l := range o.Links {
+				key += l.Node + "/" + l.Iface + " "
+			}
+			m[key] = true
+		}
+		return m
+	}
+
+	full, err := NewResilience(2, predicate, WithPruning(false)).Run(baseline)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if full.Evaluated != 10 || full.Pruned != 0 {
+		t.Errorf("Expected 10 evaluated combinations, got %d evaluated and %d pruned", full.Evaluated, full.Pruned)
+	}
+	// Both paths must be cut
+	if len(full.Violations) != 4 {
+		t.Errorf("Expected 4 violating combinations, got %v", full.Violations)
+	}
+
+	pruned, err := NewResilience(2, predicate, WithWorkers(2)).Run(baseline)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if pruned.Pruned == 0 || pruned.Evaluated+pruned.Pruned != 10 {
+		t.Errorf("Expected pruning, got %d evaluated and %d pruned", pruned.Evaluated, pruned.Pruned)
+	}
+	want, got := cuts(full), cuts(pruned)
+	if len(want) != len(got) {
+		t.Fatalf("Pruning changed violations: %v vs %v", want, got)
+	}
+	for k := range want {
+		if !got[k] {
+			t.Errorf("Pruning lost violation %s", k)
+		}
+	}
+}
+
+func TestRunHijack_ExactPrefix(t *testing.T) {
+	baseline := makeBaseline(t)
+	result, err := RunHijack(baseline, Hijack{Node: "b", Prefix: target})
+	if err != nil {
+		t.Fatalf("RunHijack() = %v", err)
+	}
+	if result.Covering != target || len(result.Origins) != 1 || result.Origins[0] != "d" {
+		t.Errorf("Expected d to originate %s, got %v %v", target, result.Covering, result.Origins)
+	}
+	// a prefers the shorter path through b, c stays with d
+	want := map[string]Verdict{"a": Hijacked, "c": Legitimate, "d": Legitimate}
+	for node, v := range want {
+		if got := result.Verdicts[node]; got != v {
+			t.Errorf("Node %s: expected %s, got %s", node, v, got)
+		}
+	}
+	if _, ok := result.Verdicts["b"]; ok {
+		t.Error("Expected no verdict for the hijacker")
+	}
+	// Neither the hijacker b nor the origin d count
+	if f := result.HijackedFraction(); f != 0.5 {
+		t.Errorf("Expected half of the nodes hijacked, got %f", f)
+	}
+}
+
+func TestRunHijack_MoreSpecific(t *testing.T) {
+	baseline := makeBaseline(t)
+	sub := netip.MustParsePrefix("203.0.113.0/25")
+	result, err := RunHijack(baseline, Hijack{Node: "c", Prefix: sub, AsPath: []uint32{65004}})
+	if err != nil {
+		t.Fatalf("RunHijack() = %v", err)
+	}
+	if result.Covering != target {
+		t.Errorf("Expected covering prefix %s, got %s", target, result.Covering)
+	}
+	if got := result.Nodes(Hijacked); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("Expected a and b hijacked, got %v", got)
+	}
+	// The forged origin makes d drop the announcement as a loop
+	if d, _ := result.Simulation.Topology().Node("d"); len(d.Routes(sub)) != 0 || result.Verdicts["d"] != Legitimate {
+		t.Error("Expected d to reject the forged path")
+	}
+}
+
+func TestRunHijack_NoOrigin(t *testing.T) {
+	baseline := makeBaseline(t)
+	if _, err := RunHijack(baseline, Hijack{Node: "a", Prefix: netip.MustParsePrefix("198.51.100.0/24")}); err != ErrNoOrigin {
+		t.Errorf("Expected ErrNoOrigin, got %v", err)
+	}
+}
+
+func TestRunLeak(t *testing.T) {
+	// x is a customer of p1 and p2, p2 has customer c, p1 originates the prefix
+	p1 := bgp.NewBgpNode("p1", 65001)
+	p2 := bgp.NewBgpNode("p2", 65002)
+	x := bgp.NewBgpNode("x", 65003)
+	c := bgp.NewBgpNode("c", 65004)
+	builder := &bgp.BgpTopologyBuilder{}
+	for _, l := range []struct {
+		provider, customer *bgp.BgpNode
+		subnet             string
+	}{{p1, x, "10.0.0.0/30"}, {p2, x, "10.0.0.4/30"}, {p2, c, "10.0.0.8/30"}} {
+		pfx := netip.MustParsePrefix(l.subnet)
+		builder.AddPeer(bgp.BgpPeer{
+			LocalNode:    l.provider,
+			RemoteNode:   l.customer,
+			LocalPrefix:  netip.PrefixFrom(pfx.Addr().Next(), pfx.Bits()),
+			RemotePrefix: netip.PrefixFrom(pfx.Addr().Next().Next(), pfx.Bits()),
+			LocalIface:   "to-" + l.customer.Name(),
+			RemoteIface:  "to-" + l.provider.Name(),
+			Relationship: bgp.Customer,
+		})
+	}
+	other := netip.MustParsePrefix("198.51.100.0/24")
+	p1.Originate(route.New(route.WithPrefix(target)))
+	p1.Originate(route.New(route.WithPrefix(other)))
+
+	topo := builder.Build()
+	gaorexford.Apply(topo)
+	baseline := bgp.NewSimulation(topo)
+	if err := baseline.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	// x does not give transit between its providers
+	if _, ok := p2.LocRib(target); ok {
+		t.Fatal("Expected p2 to have no route before the leak")
+	}
+
+	result, err := RunLeak(baseline, "x")
+	if err != nil {
+		t.Fatalf("RunLeak() = %v", err)
+	}
+	if got := result.Affected(); !slices.Equal(got, []string{"c", "p2"}) {
+		t.Errorf("Expected the leak to reach p2 and c, got %v", got)
+	}
+	if f := result.AffectedFraction(); f != 2.0/3 {
+		t.Errorf("Expected two thirds of the nodes affected, got %f", f)
+	}
+	if _, ok := p2.LocRib(target); ok {
+		t.Error("Expected baseline to be unchanged")
+	}
+	if trips := result.MaxPrefixTrips(); len(trips) != 0 {
+		t.Errorf("Expected no maximum-prefix trips, got %v", trips)
+	}
+
+	// A limit on the session of p2 to x stops the leak
+	baseline.SetMaxPrefix(bgp.LinkID{Node: "p2", Iface: "to-x"}, bgp.MaxPrefix{Limit: 1})
+	result, err = RunLeak(baseline, "x")
+	if err != nil {
+		t.Fatalf("RunLeak() = %v", err)
+	}
+	trips := result.MaxPrefixTrips()
+	if len(trips) != 1 || trips[0].Peer.LocalNode.Name() != "p2" || trips[0].Prefixes != 2 {
+		t.Fatalf("Expected the session of p2 to x to trip with 2 prefixes, got %v", trips)
+	}
+	if got := result.Affected(); len(got) != 0 {
+		t.Errorf("Expected the leak to be contained, got %v", got)
+	}
+
+	// The leaker still applies its own export policy, only one prefix leaks and stays below the limit
+	x.SetExportPolicy("to-p2", policy.New(
+		policy.Clause{Match: []policy.Match{policy.MatchPrefix(other)}, Action: policy.Deny},
+		policy.Clause{Match: []policy.Match{policy.MatchTag(gaorexford.PeerTag, gaorexford.ProviderTag)}, Action: policy.Deny, ValleyFree: true},
+		policy.Clause{Match: []policy.Match{policy.MatchAny()}},
+	))
+	result, err = RunLeak(baseline, "x")
+	if err != nil {
+		t.Fatalf("RunLeak() = %v", err)
+	}
+	if got := result.Affected(); !slices.Equal(got, []string{"c", "p2"}) {
+		t.Errorf("Expected the leak to reach p2 and c, got %v", got)
+	}
+	leaked, _ := result.Simulation.Topology().Node("p2")
+	if _, ok := leaked.LocRib(other); ok {
+		t.Error("Expected the export policy of x to keep the other prefix from p2")
+	}
+}